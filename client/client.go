@@ -2,10 +2,11 @@ package client
 
 import (
 	"context"
-	"github.com/binus-thesis-team/product-service/internal/model"
+	"errors"
 
+	"github.com/binus-thesis-team/product-service/internal/model"
 	"github.com/sirupsen/logrus"
-	"google.golang.org/grpc/codes"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/status"
 )
 
@@ -15,7 +16,7 @@ type ProductServiceClient interface {
 	FindProductIDsByQuery(ctx context.Context, query string) (ids []int64, count int64, err error)
 }
 
-// convertErrorGRPCToErrorGeneral mapping error from GRPC Error to general Error
+// convertErrorGRPCToErrorGeneral mapping error from GRPC Error to a typed *Error with the details sent by the service
 func convertErrorGRPCToErrorGeneral(err error) error {
 	if err == nil {
 		return nil
@@ -26,11 +27,28 @@ func convertErrorGRPCToErrorGeneral(err error) error {
 		return err
 	}
 
-	switch grpcError.Code() {
-	case codes.NotFound:
-		return ErrNotFound
-	default:
+	typedErr := &Error{
+		Code:    grpcError.Code(),
+		Message: grpcError.Message(),
+	}
+	for _, detail := range grpcError.Details() {
+		switch d := detail.(type) {
+		case *errdetails.ErrorInfo:
+			typedErr.Reason = model.ErrorReason(d.GetReason())
+			typedErr.Metadata = d.GetMetadata()
+		case *errdetails.BadRequest:
+			for _, violation := range d.GetFieldViolations() {
+				typedErr.FieldViolations = append(typedErr.FieldViolations, FieldViolation{
+					Field:       violation.GetField(),
+					Description: violation.GetDescription(),
+				})
+			}
+		}
+	}
+
+	if errors.Is(typedErr, ErrInternalServerError) {
 		logrus.Error(err)
-		return ErrInternalServerError
 	}
+
+	return typedErr
 }
@@ -1,8 +1,81 @@
 package client
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/binus-thesis-team/product-service/internal/model"
+	"google.golang.org/grpc/codes"
+)
 
 var (
 	ErrNotFound            = errors.New("error not found")
 	ErrInternalServerError = errors.New("error internal server")
+	ErrInvalidArgument     = errors.New("error invalid argument")
+	ErrAlreadyExists       = errors.New("error already exists")
+	ErrPermissionDenied    = errors.New("error permission denied")
+	ErrVersionConflict     = errors.New("error version conflict")
+	ErrRequestInFlight     = errors.New("error request with the idempotency key in flight")
+	ErrTimeout             = errors.New("error timeout")
+	ErrImportQueueFull     = errors.New("error too many import jobs queued")
 )
+
+// FieldViolation is a field of the request failing the validation
+type FieldViolation struct {
+	Field       string
+	Description string
+}
+
+// Error is an error returned by the product service with its details,
+// errors.Is matches it with the sentinel error of its reason, e.g. ErrNotFound
+type Error struct {
+	Code            codes.Code
+	Reason          model.ErrorReason
+	Message         string
+	Metadata        map[string]string
+	FieldViolations []FieldViolation
+}
+
+// Error :nodoc:
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the sentinel error of the reason, or of the code when the reason is unknown
+func (e *Error) Unwrap() error {
+	switch e.Reason {
+	case model.ErrorReasonNotFound:
+		return ErrNotFound
+	case model.ErrorReasonDuplicateProduct:
+		return ErrAlreadyExists
+	case model.ErrorReasonPermissionDenied:
+		return ErrPermissionDenied
+	case model.ErrorReasonValidationFailed, model.ErrorReasonInvalidImportFile, model.ErrorReasonInvalidImportMode,
+		model.ErrorReasonIdempotencyKeyReused:
+		return ErrInvalidArgument
+	case model.ErrorReasonVersionConflict:
+		return ErrVersionConflict
+	case model.ErrorReasonIdempotencyKeyInFlight:
+		return ErrRequestInFlight
+	case model.ErrorReasonTimeout:
+		return ErrTimeout
+	case model.ErrorReasonImportQueueFull:
+		return ErrImportQueueFull
+	}
+
+	switch e.Code {
+	case codes.NotFound:
+		return ErrNotFound
+	case codes.AlreadyExists:
+		return ErrAlreadyExists
+	case codes.PermissionDenied:
+		return ErrPermissionDenied
+	case codes.InvalidArgument:
+		return ErrInvalidArgument
+	case codes.DeadlineExceeded:
+		return ErrTimeout
+	case codes.Aborted:
+		return ErrVersionConflict
+	default:
+		return ErrInternalServerError
+	}
+}
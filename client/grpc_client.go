@@ -39,7 +39,7 @@ func (g *grpcClient) FindByProductID(ctx context.Context, id int64) (*model.Prod
 	cli := pb.NewProductServiceClient(conn.ClientConn)
 	out, err := cli.FindByProductID(ctx, &pb.FindByIDRequest{Id: id}, g.opts...)
 	if err != nil {
-		return nil, err
+		return nil, convertErrorGRPCToErrorGeneral(err)
 	}
 	return model.NewProductFromProto(out), nil
 }
@@ -57,7 +57,7 @@ func (g *grpcClient) FindProductIDsByQuery(ctx context.Context, query string) (i
 		Query: query,
 	})
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, convertErrorGRPCToErrorGeneral(err)
 	}
 
 	return out.GetIds(), out.GetCount(), nil
@@ -0,0 +1,33 @@
+-- +migrate Up notransaction
+CREATE TABLE product_import_jobs (
+	id BIGSERIAL NOT NULL,
+	filename text NOT NULL,
+	status text NOT NULL,
+	total_rows int8 NOT NULL DEFAULT 0,
+	processed_rows int8 NOT NULL DEFAULT 0,
+	created_rows int8 NOT NULL DEFAULT 0,
+	failed_rows int8 NOT NULL DEFAULT 0,
+	error_message text NOT NULL DEFAULT '',
+	created_by int8 NOT NULL,
+	started_at timestamptz NULL,
+	finished_at timestamptz NULL,
+	created_at timestamptz NOT NULL,
+	updated_at timestamptz NOT NULL,
+	CONSTRAINT product_import_jobs_pkey PRIMARY KEY (id)
+);
+
+CREATE TABLE product_import_job_errors (
+	id BIGSERIAL NOT NULL,
+	job_id int8 NOT NULL,
+	row_number int8 NOT NULL,
+	column_name text NOT NULL,
+	reason text NOT NULL,
+	CONSTRAINT product_import_job_errors_pkey PRIMARY KEY (id),
+	CONSTRAINT product_import_job_errors_job_id_fkey FOREIGN KEY (job_id) REFERENCES product_import_jobs (id) ON DELETE CASCADE
+);
+
+CREATE INDEX product_import_job_errors_job_id_idx ON product_import_job_errors (job_id, row_number);
+
+-- +migrate Down
+DROP TABLE product_import_job_errors;
+DROP TABLE product_import_jobs;
@@ -0,0 +1,15 @@
+-- +migrate Up notransaction
+ALTER TABLE products ADD COLUMN external_id text NULL;
+
+CREATE UNIQUE INDEX CONCURRENTLY products_external_id_key ON products (external_id) WHERE external_id IS NOT NULL;
+
+ALTER TABLE product_import_jobs ADD COLUMN mode text NOT NULL DEFAULT 'create-only';
+ALTER TABLE product_import_jobs ADD COLUMN updated_rows int8 NOT NULL DEFAULT 0;
+
+-- +migrate Down
+ALTER TABLE product_import_jobs DROP COLUMN updated_rows;
+ALTER TABLE product_import_jobs DROP COLUMN mode;
+
+DROP INDEX products_external_id_key;
+
+ALTER TABLE products DROP COLUMN external_id;
@@ -0,0 +1,5 @@
+-- +migrate Up notransaction
+ALTER TABLE product_import_jobs ADD COLUMN atomic boolean NOT NULL DEFAULT false;
+
+-- +migrate Down
+ALTER TABLE product_import_jobs DROP COLUMN atomic;
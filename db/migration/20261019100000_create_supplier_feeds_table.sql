@@ -0,0 +1,44 @@
+-- +migrate Up notransaction
+CREATE TABLE supplier_feeds (
+	id BIGSERIAL NOT NULL,
+	"name" text NOT NULL,
+	url text NOT NULL,
+	format text NOT NULL,
+	column_mapping jsonb NOT NULL DEFAULT '{}',
+	schedule text NOT NULL,
+	upsert_key text NOT NULL DEFAULT '',
+	enabled boolean NOT NULL DEFAULT true,
+	next_run_at timestamptz NOT NULL,
+	last_run_at timestamptz NULL,
+	created_by int8 NOT NULL,
+	created_at timestamptz NOT NULL,
+	updated_at timestamptz NOT NULL,
+	deleted_at timestamptz NULL,
+	CONSTRAINT supplier_feeds_pkey PRIMARY KEY (id)
+);
+
+CREATE INDEX supplier_feeds_next_run_at_idx ON supplier_feeds (next_run_at) WHERE enabled AND deleted_at IS NULL;
+
+CREATE TABLE supplier_feed_runs (
+	id BIGSERIAL NOT NULL,
+	feed_id int8 NOT NULL,
+	import_job_id int8 NULL,
+	status text NOT NULL,
+	http_status int4 NOT NULL DEFAULT 0,
+	total_rows int8 NOT NULL DEFAULT 0,
+	created_rows int8 NOT NULL DEFAULT 0,
+	updated_rows int8 NOT NULL DEFAULT 0,
+	failed_rows int8 NOT NULL DEFAULT 0,
+	error_message text NOT NULL DEFAULT '',
+	started_at timestamptz NOT NULL,
+	finished_at timestamptz NULL,
+	CONSTRAINT supplier_feed_runs_pkey PRIMARY KEY (id),
+	CONSTRAINT supplier_feed_runs_feed_id_fkey FOREIGN KEY (feed_id) REFERENCES supplier_feeds (id) ON DELETE CASCADE,
+	CONSTRAINT supplier_feed_runs_import_job_id_fkey FOREIGN KEY (import_job_id) REFERENCES product_import_jobs (id) ON DELETE SET NULL
+);
+
+CREATE INDEX supplier_feed_runs_feed_id_idx ON supplier_feed_runs (feed_id, started_at DESC);
+
+-- +migrate Down
+DROP TABLE supplier_feed_runs;
+DROP TABLE supplier_feeds;
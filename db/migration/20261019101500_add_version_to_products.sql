@@ -0,0 +1,5 @@
+-- +migrate Up notransaction
+ALTER TABLE products ADD COLUMN version int8 NOT NULL DEFAULT 1;
+
+-- +migrate Down
+ALTER TABLE products DROP COLUMN version;
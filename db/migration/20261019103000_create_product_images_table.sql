@@ -0,0 +1,24 @@
+-- +migrate Up
+CREATE TABLE product_images (
+	id BIGSERIAL NOT NULL,
+	product_id int8 NOT NULL,
+	image_url text NOT NULL,
+	alt_text text NOT NULL DEFAULT '',
+	"position" int4 NOT NULL,
+	is_primary boolean NOT NULL DEFAULT false,
+	created_at timestamptz NOT NULL,
+	updated_at timestamptz NOT NULL,
+	CONSTRAINT product_images_pkey PRIMARY KEY (id),
+	CONSTRAINT product_images_product_id_fkey FOREIGN KEY (product_id) REFERENCES products (id) ON DELETE CASCADE
+);
+
+CREATE INDEX product_images_product_id_idx ON product_images (product_id, "position");
+CREATE UNIQUE INDEX product_images_product_id_image_url_key ON product_images (product_id, image_url);
+CREATE UNIQUE INDEX product_images_primary_key ON product_images (product_id) WHERE is_primary;
+
+-- the current image of every product becomes its primary image
+INSERT INTO product_images (product_id, image_url, "position", is_primary, created_at, updated_at)
+SELECT id, image_url, 0, true, now(), now() FROM products WHERE image_url <> '';
+
+-- +migrate Down
+DROP TABLE product_images;
@@ -0,0 +1,8 @@
+-- +migrate Up notransaction
+-- looked up by the image served under /media when the signed urls are enabled
+CREATE INDEX CONCURRENTLY products_image_url_idx ON products (image_url) WHERE deleted_at IS NULL;
+CREATE INDEX CONCURRENTLY product_images_image_url_idx ON product_images (image_url);
+
+-- +migrate Down
+DROP INDEX product_images_image_url_idx;
+DROP INDEX products_image_url_idx;
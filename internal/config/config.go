@@ -41,6 +41,20 @@ func GRPCPort() string {
 	return viper.GetString("ports.grpc")
 }
 
+// GRPCReflectionEnabled tells whether the grpc server registers the reflection service, default to true
+func GRPCReflectionEnabled() bool {
+	if !viper.IsSet("grpc.reflection_enabled") {
+		return true
+	}
+	return viper.GetBool("grpc.reflection_enabled")
+}
+
+// GRPCHealthCheckInterval is the interval between two checks of the dependencies reported by the health service
+func GRPCHealthCheckInterval() time.Duration {
+	cfg := viper.GetString("grpc.health_check_interval")
+	return parseDuration(cfg, DefaultGRPCHealthCheckInterval)
+}
+
 // DatabaseDSN :nodoc:
 func DatabaseDSN() string {
 	return fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=%s",
@@ -159,6 +173,14 @@ func RedisLockHost() string {
 	return viper.GetString("redis.lock_host")
 }
 
+// RedisIdempotencyHost is the redis of the idempotency keys, the cache one when it's not set
+func RedisIdempotencyHost() string {
+	if host := viper.GetString("redis.idempotency_host"); host != "" {
+		return host
+	}
+	return RedisCacheHost()
+}
+
 // DisableCaching :nodoc:
 func DisableCaching() bool {
 	return viper.GetBool("disable_caching")
@@ -204,6 +226,47 @@ func CacheTTL() time.Duration {
 	return parseDuration(cfg, DefaultRedisCacheTTL)
 }
 
+// LocalCacheEnabled :nodoc:
+func LocalCacheEnabled() bool {
+	return viper.GetBool("local_cache.enabled")
+}
+
+// LocalCacheSize :nodoc:
+func LocalCacheSize() int {
+	if viper.GetInt("local_cache.size") > 0 {
+		return viper.GetInt("local_cache.size")
+	}
+	return DefaultLocalCacheSize
+}
+
+// LocalCacheTTL :nodoc:
+func LocalCacheTTL() time.Duration {
+	cfg := viper.GetString("local_cache.ttl")
+	return parseDuration(cfg, DefaultLocalCacheTTL)
+}
+
+// LocalCacheInvalidationChannel :nodoc:
+func LocalCacheInvalidationChannel() string {
+	if viper.GetString("local_cache.invalidation_channel") != "" {
+		return viper.GetString("local_cache.invalidation_channel")
+	}
+	return DefaultLocalCacheInvalidationChannel
+}
+
+// RequestCounterFlushInterval :nodoc:
+func RequestCounterFlushInterval() time.Duration {
+	cfg := viper.GetString("request_counter.flush_interval")
+	return parseDuration(cfg, DefaultRequestCounterFlushInterval)
+}
+
+// CacheWarmLimit :nodoc:
+func CacheWarmLimit() int64 {
+	if viper.GetInt64("cache_warm.limit") > 0 {
+		return viper.GetInt64("cache_warm.limit")
+	}
+	return DefaultCacheWarmLimit
+}
+
 func parseDuration(in string, defaultDuration time.Duration) time.Duration {
 	dur, err := time.ParseDuration(in)
 	if err != nil {
@@ -235,6 +298,250 @@ func WorkerConcurrency() int {
 	return DefaultWorkerConcurrency
 }
 
+// ImportMaxConcurrentJobs :nodoc:
+func ImportMaxConcurrentJobs() int {
+	if viper.GetInt("import.max_concurrent_jobs") > 0 {
+		return viper.GetInt("import.max_concurrent_jobs")
+	}
+
+	return DefaultImportMaxConcurrentJobs
+}
+
+// ImportMaxQueuedJobs is the max import jobs waiting for a turn, their files are held in memory meanwhile
+func ImportMaxQueuedJobs() int {
+	if viper.GetInt("import.max_queued_jobs") > 0 {
+		return viper.GetInt("import.max_queued_jobs")
+	}
+	return DefaultImportMaxQueuedJobs
+}
+
+// ImportJobStaleAfter is how long a queued or running import job goes unsaved before it's failed as
+// orphaned, e.g. by a crash. It must be well above the interval the live jobs are saved at.
+func ImportJobStaleAfter() time.Duration {
+	cfg := viper.GetString("import.job_stale_after")
+	return parseDuration(cfg, DefaultImportJobStaleAfter)
+}
+
+// ImportShutdownTimeout is how long the server waits for the running import jobs on shutdown
+func ImportShutdownTimeout() time.Duration {
+	cfg := viper.GetString("import.shutdown_timeout")
+	return parseDuration(cfg, DefaultImportShutdownTimeout)
+}
+
+// SupplierFeedRunInServer tells whether the server process runs the supplier feed scheduler,
+// the worker command always does
+func SupplierFeedRunInServer() bool {
+	if !viper.IsSet("supplier_feed.run_in_server") {
+		return true
+	}
+	return viper.GetBool("supplier_feed.run_in_server")
+}
+
+// ProductBulkMaxOperations is the max operations of a bulk mutation
+func ProductBulkMaxOperations() int {
+	if viper.GetInt("product_bulk.max_operations") > 0 {
+		return viper.GetInt("product_bulk.max_operations")
+	}
+	return DefaultProductBulkMaxOperations
+}
+
+// ImageUploadMaxSizeBytes is the max size of an uploaded image
+func ImageUploadMaxSizeBytes() int64 {
+	if viper.GetInt64("image_upload.max_size_bytes") > 0 {
+		return viper.GetInt64("image_upload.max_size_bytes")
+	}
+	return DefaultImageUploadMaxSizeBytes
+}
+
+// ImageUploadMaxWidth is the max width in pixels of an uploaded image
+func ImageUploadMaxWidth() int {
+	if viper.GetInt("image_upload.max_width") > 0 {
+		return viper.GetInt("image_upload.max_width")
+	}
+	return DefaultImageUploadMaxWidth
+}
+
+// ImageUploadMaxHeight is the max height in pixels of an uploaded image
+func ImageUploadMaxHeight() int {
+	if viper.GetInt("image_upload.max_height") > 0 {
+		return viper.GetInt("image_upload.max_height")
+	}
+	return DefaultImageUploadMaxHeight
+}
+
+// ImageDerivativeSizes are the sizes in pixels of the derivatives generated for an uploaded image,
+// the longest side of a derivative is at most its size
+func ImageDerivativeSizes() []int {
+	var sizes []int
+	for _, size := range viper.GetIntSlice("image_upload.derivative_sizes") {
+		if size > 0 {
+			sizes = append(sizes, size)
+		}
+	}
+	if len(sizes) > 0 {
+		return sizes
+	}
+	return []int{DefaultImageDerivativeSmall, DefaultImageDerivativeMedium, DefaultImageDerivativeLarge}
+}
+
+// ProductImagesMaxPerProduct is the max images of the gallery of a product
+func ProductImagesMaxPerProduct() int {
+	if viper.GetInt("product_images.max_per_product") > 0 {
+		return viper.GetInt("product_images.max_per_product")
+	}
+	return DefaultProductImagesMaxPerProduct
+}
+
+// ImageStoreDriver is where the product images are stored, local or s3
+func ImageStoreDriver() string {
+	if viper.GetString("image_store.driver") != "" {
+		return viper.GetString("image_store.driver")
+	}
+	return DefaultImageStoreDriver
+}
+
+// ImageStoreBaseURL is the public url the image keys are appended to
+func ImageStoreBaseURL() string {
+	if viper.GetString("image_store.base_url") != "" {
+		return viper.GetString("image_store.base_url")
+	}
+	return DefaultImageStoreBaseURL
+}
+
+// ImageStoreKeyPrefix is the prefix of the keys of the uploaded images
+func ImageStoreKeyPrefix() string {
+	if viper.GetString("image_store.key_prefix") != "" {
+		return viper.GetString("image_store.key_prefix")
+	}
+	return DefaultImageStoreKeyPrefix
+}
+
+// ImageStoreLocalDir is the directory of the local image store
+func ImageStoreLocalDir() string {
+	if viper.GetString("image_store.local.dir") != "" {
+		return viper.GetString("image_store.local.dir")
+	}
+	return DefaultImageStoreLocalDir
+}
+
+// ImageStoreS3Endpoint is the host of the S3 compatible service without the scheme, e.g. localhost:9000
+func ImageStoreS3Endpoint() string {
+	return viper.GetString("image_store.s3.endpoint")
+}
+
+// ImageStoreS3Region :nodoc:
+func ImageStoreS3Region() string {
+	return viper.GetString("image_store.s3.region")
+}
+
+// ImageStoreS3Bucket :nodoc:
+func ImageStoreS3Bucket() string {
+	return viper.GetString("image_store.s3.bucket")
+}
+
+// ImageStoreS3AccessKeyID :nodoc:
+func ImageStoreS3AccessKeyID() string {
+	return viper.GetString("image_store.s3.access_key_id")
+}
+
+// ImageStoreS3SecretAccessKey :nodoc:
+func ImageStoreS3SecretAccessKey() string {
+	return viper.GetString("image_store.s3.secret_access_key")
+}
+
+// ImageStoreS3UseSSL :nodoc:
+func ImageStoreS3UseSSL() bool {
+	return viper.GetBool("image_store.s3.use_ssl")
+}
+
+// MediaCacheMaxAge is the max-age of the images served publicly under /media, a shared cache keeps serving
+// the image of a product deleted meanwhile until it expires
+func MediaCacheMaxAge() time.Duration {
+	cfg := viper.GetString("media.cache_max_age")
+	return parseDuration(cfg, DefaultMediaCacheMaxAge)
+}
+
+// MediaSignedURLsEnabled tells whether an image of no published product is only served through a signed url
+func MediaSignedURLsEnabled() bool {
+	return viper.GetBool("media.signed_urls.enabled")
+}
+
+// MediaSigningKey is the HMAC key of the signed urls
+func MediaSigningKey() string {
+	return viper.GetString("media.signed_urls.signing_key")
+}
+
+// MediaSignedURLTTL is how long a signed url is valid
+func MediaSignedURLTTL() time.Duration {
+	cfg := viper.GetString("media.signed_urls.ttl")
+	return parseDuration(cfg, DefaultMediaSignedURLTTL)
+}
+
+// IdempotencyEnabled :nodoc:
+func IdempotencyEnabled() bool {
+	return viper.GetBool("idempotency.enabled")
+}
+
+// IdempotencyTTL is how long the response of an idempotency key is replayed
+func IdempotencyTTL() time.Duration {
+	cfg := viper.GetString("idempotency.ttl")
+	return parseDuration(cfg, DefaultIdempotencyTTL)
+}
+
+// IdempotencyInFlightTTL bounds how long an idempotency key stays locked by a request that never completes
+func IdempotencyInFlightTTL() time.Duration {
+	cfg := viper.GetString("idempotency.in_flight_ttl")
+	return parseDuration(cfg, DefaultIdempotencyInFlightTTL)
+}
+
+// SupplierFeedPollInterval :nodoc:
+func SupplierFeedPollInterval() time.Duration {
+	cfg := viper.GetString("supplier_feed.poll_interval")
+	return parseDuration(cfg, DefaultSupplierFeedPollInterval)
+}
+
+// SupplierFeedFetchTimeout :nodoc:
+func SupplierFeedFetchTimeout() time.Duration {
+	cfg := viper.GetString("supplier_feed.fetch_timeout")
+	return parseDuration(cfg, DefaultSupplierFeedFetchTimeout)
+}
+
+// SupplierFeedMaxSizeBytes :nodoc:
+func SupplierFeedMaxSizeBytes() int64 {
+	if viper.GetInt64("supplier_feed.max_size_bytes") > 0 {
+		return viper.GetInt64("supplier_feed.max_size_bytes")
+	}
+	return DefaultSupplierFeedMaxSizeBytes
+}
+
+// ProductFeedTitle :nodoc:
+func ProductFeedTitle() string {
+	return viper.GetString("product_feed.title")
+}
+
+// ProductFeedLink is the url of the shop
+func ProductFeedLink() string {
+	return viper.GetString("product_feed.link")
+}
+
+// ProductFeedDescription :nodoc:
+func ProductFeedDescription() string {
+	return viper.GetString("product_feed.description")
+}
+
+// ProductFeedCurrency is the ISO 4217 currency of the product prices
+func ProductFeedCurrency() string {
+	if viper.GetString("product_feed.currency") != "" {
+		return viper.GetString("product_feed.currency")
+	}
+	return DefaultProductFeedCurrency
+}
+
+// ProductFeedProductLinkFormat is the product page url with a %d for the product id
+func ProductFeedProductLinkFormat() string {
+	return viper.GetString("product_feed.product_link_format")
+}
+
 func GRPCIAMTarget() string {
 	return viper.GetString("services.grpc.iam_target")
 }
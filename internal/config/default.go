@@ -18,6 +18,13 @@ const (
 
 	DefaultRedisCacheTTL = 15 * time.Minute
 
+	DefaultLocalCacheSize                = 1000
+	DefaultLocalCacheTTL                 = 1 * time.Minute
+	DefaultLocalCacheInvalidationChannel = "product-service:cache:invalidation"
+
+	DefaultRequestCounterFlushInterval = 10 * time.Second
+	DefaultCacheWarmLimit              = 1000
+
 	DefaultAccessTokenDuration  = 1 * time.Hour
 	DefaultRefreshTokenDuration = 24 * time.Hour * 365 // 1 year
 
@@ -29,5 +36,41 @@ const (
 	DefaultLoginLockTTL       = 5 * time.Minute
 
 	DefaultMaxSizePerRequest = 25
-	DefaultWorkerConcurrency   = 10
+	DefaultWorkerConcurrency = 10
+
+	DefaultImportMaxConcurrentJobs = 2
+	DefaultImportMaxQueuedJobs     = 10
+	DefaultImportJobStaleAfter     = 5 * time.Minute
+	DefaultImportShutdownTimeout   = 30 * time.Second
+
+	DefaultSupplierFeedPollInterval = 1 * time.Minute
+	DefaultSupplierFeedFetchTimeout = 2 * time.Minute
+	DefaultSupplierFeedMaxSizeBytes = 50 << 20 // 50 MB
+
+	DefaultProductFeedCurrency = "IDR"
+
+	DefaultGRPCHealthCheckInterval = 10 * time.Second
+
+	DefaultProductBulkMaxOperations = 1000
+
+	DefaultIdempotencyTTL         = 24 * time.Hour
+	DefaultIdempotencyInFlightTTL = 5 * time.Minute
+
+	DefaultImageUploadMaxSizeBytes = 5 << 20 // 5 MB
+	DefaultImageUploadMaxWidth     = 8000
+	DefaultImageUploadMaxHeight    = 8000
+
+	DefaultImageDerivativeSmall  = 150
+	DefaultImageDerivativeMedium = 400
+	DefaultImageDerivativeLarge  = 1024
+
+	DefaultProductImagesMaxPerProduct = 10
+
+	DefaultImageStoreDriver    = "local"
+	DefaultImageStoreBaseURL   = "http://localhost:3002/media/"
+	DefaultImageStoreKeyPrefix = "products/"
+	DefaultImageStoreLocalDir  = "assets"
+
+	DefaultMediaCacheMaxAge  = 24 * time.Hour
+	DefaultMediaSignedURLTTL = 15 * time.Minute
 )
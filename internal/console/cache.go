@@ -0,0 +1,204 @@
+package console
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/binus-thesis-team/cacher"
+	"github.com/binus-thesis-team/product-service/internal/config"
+	"github.com/binus-thesis-team/product-service/internal/db"
+	"github.com/binus-thesis-team/product-service/internal/helper"
+	"github.com/binus-thesis-team/product-service/internal/repository"
+	redigo "github.com/gomodule/redigo/redis"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "manage product cache",
+	Long:  `This subcommand used to warm up, inspect and evict the product cache`,
+}
+
+var cacheWarmCmd = &cobra.Command{
+	Use:   "warm",
+	Short: "warm up product cache",
+	Long:  `Preload the most recently updated or the most requested products into the cache`,
+	Args:  cobra.NoArgs,
+	Run:   processCacheWarm,
+}
+
+var cacheInspectCmd = &cobra.Command{
+	Use:   "inspect [id]",
+	Short: "inspect cached product",
+	Long:  `Print the cache key, TTL and cached value of a product`,
+	Args:  cobra.ExactArgs(1),
+	Run:   processCacheInspect,
+}
+
+var cacheEvictCmd = &cobra.Command{
+	Use:   "evict [id|pattern]",
+	Short: "evict cached products",
+	Long: `Evict a product by its id, or every product matching a pattern.
+A pattern without the "cache:" prefix is matched against the product id, e.g. "12*"`,
+	Args: cobra.ExactArgs(1),
+	Run:  processCacheEvict,
+}
+
+func init() {
+	cacheWarmCmd.Flags().Int64("limit", 0, "maximum products to preload, default to cache_warm.limit config")
+	cacheWarmCmd.Flags().String("by", "updated", "products selection, either updated or requested")
+	cacheWarmCmd.Flags().Int("concurrency", 0, "maximum concurrent loads, default to worker.concurrency config")
+
+	cacheCmd.AddCommand(cacheWarmCmd, cacheInspectCmd, cacheEvictCmd)
+	RootCmd.AddCommand(cacheCmd)
+}
+
+func processCacheWarm(cmd *cobra.Command, args []string) {
+	limit, _ := cmd.Flags().GetInt64("limit")
+	if limit <= 0 {
+		limit = config.CacheWarmLimit()
+	}
+	concurrency, _ := cmd.Flags().GetInt("concurrency")
+	if concurrency <= 0 {
+		concurrency = config.WorkerConcurrency()
+	}
+	by, _ := cmd.Flags().GetString("by")
+
+	db.InitializePostgresConn()
+	generalCacher, redisConn, closeConn := newGeneralCacheManager()
+	defer closeConn()
+
+	// no request counter here, warming must not count as product requests
+	productRepository := repository.NewProductRepository(db.PostgreSQL, generalCacher, nil)
+
+	ctx := context.Background()
+
+	var ids []int64
+	var err error
+	switch by {
+	case "updated":
+		ids, err = productRepository.FindIDsByLastUpdated(ctx, limit)
+	case "requested":
+		ids, err = repository.NewRequestCounter(redisConn).FindMostRequestedIDs(limit)
+	default:
+		log.WithField("by", by).Fatal("unknown products selection, use updated or requested")
+	}
+	continueOrFatal(err)
+
+	var warmed, failed int64
+	semaphore := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	for _, id := range ids {
+		wg.Add(1)
+
+		// Acquire semaphore
+		semaphore <- struct{}{}
+
+		go func(id int64) {
+			defer wg.Done()
+			defer func() { <-semaphore }() // Release semaphore
+
+			product, err := productRepository.FindByID(ctx, id)
+			if err != nil || product == nil {
+				log.WithField("id", id).Error("failed to warm product cache: ", err)
+				atomic.AddInt64(&failed, 1)
+				return
+			}
+			atomic.AddInt64(&warmed, 1)
+		}(id)
+	}
+
+	// Wait for all goroutines to finish
+	wg.Wait()
+
+	log.Infof("Warmed %d products, %d failed", warmed, failed)
+}
+
+func processCacheInspect(cmd *cobra.Command, args []string) {
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		log.WithField("id", args[0]).Fatal("Failed to parse id to int: ", err)
+	}
+
+	generalCacher, _, closeConn := newGeneralCacheManager()
+	defer closeConn()
+
+	key := repository.NewProductCacheKeyByID(id)
+	reply, err := generalCacher.Get(key)
+	continueOrFatal(err)
+
+	fmt.Println("key:", key)
+	if reply == nil {
+		fmt.Println("status: miss")
+		return
+	}
+
+	ttl, err := generalCacher.GetTTL(key)
+	continueOrFatal(err)
+
+	fmt.Println("status: hit")
+	fmt.Println("ttl:", time.Duration(ttl)*time.Second)
+
+	data, _ := reply.([]byte)
+	var value bytes.Buffer
+	if err := json.Indent(&value, data, "", "  "); err != nil {
+		fmt.Println("value:", string(data))
+		return
+	}
+	fmt.Println("value:", value.String())
+}
+
+func processCacheEvict(cmd *cobra.Command, args []string) {
+	generalCacher, redisConn, closeConn := newGeneralCacheManager()
+	defer closeConn()
+
+	channel := config.LocalCacheInvalidationChannel()
+
+	if id, err := strconv.ParseInt(args[0], 10, 64); err == nil {
+		key := repository.NewProductCacheKeyByID(id)
+		continueOrFatal(generalCacher.DeleteByKeys([]string{key}))
+		continueOrFatal(repository.PublishLocalCacheInvalidation(redisConn, channel, key))
+
+		log.Infof("Evicted %s", key)
+		return
+	}
+
+	pattern := args[0]
+	if !strings.HasPrefix(pattern, "cache:") {
+		pattern = repository.NewProductCacheKeyPattern(pattern)
+	}
+
+	continueOrFatal(generalCacher.Purge(pattern))
+	continueOrFatal(repository.PublishLocalCacheInvalidation(redisConn, channel, pattern))
+
+	log.Infof("Evicted %s", pattern)
+}
+
+// newGeneralCacheManager connects the product cache manager regardless of the disable_caching config,
+// the returned func closes every connection pool
+func newGeneralCacheManager() (cacher.CacheManager, *redigo.Pool, func()) {
+	redisConn, err := db.NewRedigoRedisConnectionPool(config.RedisCacheHost(), redisOpts)
+	continueOrFatal(err)
+
+	redisLockConn, err := db.NewRedigoRedisConnectionPool(config.RedisLockHost(), redisOpts)
+	continueOrFatal(err)
+
+	generalCacher := cacher.NewCacheManager()
+	generalCacher.SetConnectionPool(redisConn)
+	generalCacher.SetLockConnectionPool(redisLockConn)
+	generalCacher.SetDefaultTTL(config.CacheTTL())
+
+	return generalCacher, redisConn, func() {
+		helper.WrapCloser(redisConn.Close)
+		helper.WrapCloser(redisLockConn.Close)
+	}
+}
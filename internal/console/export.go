@@ -0,0 +1,92 @@
+package console
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/binus-thesis-team/product-service/internal/db"
+	"github.com/binus-thesis-team/product-service/internal/model"
+	"github.com/binus-thesis-team/product-service/internal/repository"
+	"github.com/binus-thesis-team/product-service/internal/usecase"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "export products to a file",
+	Long: `Export the whole catalog, or the products matching a query, to a csv, jsonl or xlsx file
+in the column layout accepted by the product import`,
+	Args: cobra.NoArgs,
+	Run:  processExport,
+}
+
+func init() {
+	exportCmd.Flags().String("format", "csv", "file format, either csv, jsonl or xlsx")
+	exportCmd.Flags().String("output", "", "output file, default to products-<timestamp>.<format>")
+	exportCmd.Flags().String("query", "", "export only the products whose name or description matches")
+
+	RootCmd.AddCommand(exportCmd)
+}
+
+func processExport(cmd *cobra.Command, args []string) {
+	formatStr, _ := cmd.Flags().GetString("format")
+	output, _ := cmd.Flags().GetString("output")
+	query, _ := cmd.Flags().GetString("query")
+
+	format := model.ProductExportFormat(formatStr)
+	if !format.IsValid() {
+		log.WithField("format", format).Fatal("unknown export format, use csv, jsonl or xlsx")
+	}
+	if output == "" {
+		output = fmt.Sprintf("products-%s.%s", time.Now().Format("20060102150405"), format)
+	}
+
+	db.InitializePostgresConn()
+
+	// the export reads straight from the database, no cache is involved
+	productRepository := repository.NewProductRepository(db.PostgreSQL, nil, nil)
+	productImportJobRepository := repository.NewProductImportJobRepository(db.PostgreSQL)
+	productUsecase := usecase.NewProductUsecase(productRepository, productImportJobRepository, nil, nil)
+
+	// written next to the output then renamed, a failed export never leaves a partial backup behind
+	file, err := os.CreateTemp(filepath.Dir(output), filepath.Base(output)+".*.tmp")
+	continueOrFatal(err)
+
+	exported, err := exportProducts(productUsecase, file, format, query)
+	if err == nil {
+		err = os.Rename(file.Name(), output)
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+		log.Fatal(err)
+	}
+
+	log.Infof("Exported %d products to %s", exported, output)
+}
+
+func exportProducts(productUsecase model.ProductUsecase, file *os.File, format model.ProductExportFormat, query string) (exported int64, err error) {
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	w, err := model.NewProductExportWriter(file, format)
+	if err != nil {
+		return 0, err
+	}
+
+	err = productUsecase.ExportWithoutSession(context.Background(), model.ProductExportCriteria{Query: query}, func(product *model.Product) error {
+		exported++
+		return w.Write(product)
+	})
+	if err != nil {
+		return exported, err
+	}
+
+	return exported, w.Flush()
+}
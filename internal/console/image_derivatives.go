@@ -0,0 +1,49 @@
+package console
+
+import (
+	"context"
+
+	"github.com/binus-thesis-team/product-service/internal/db"
+	"github.com/binus-thesis-team/product-service/internal/repository"
+	"github.com/binus-thesis-team/product-service/internal/usecase"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var imageDerivativesCmd = &cobra.Command{
+	Use:   "image-derivatives",
+	Short: "regenerate the derivatives of the product images",
+	Long: `Generate again the derivatives of every uploaded product image in the sizes of the
+image_upload.derivative_sizes config, e.g. after the sizes are changed`,
+	Args: cobra.NoArgs,
+	Run:  processImageDerivatives,
+}
+
+func init() {
+	RootCmd.AddCommand(imageDerivativesCmd)
+}
+
+func processImageDerivatives(_ *cobra.Command, _ []string) {
+	db.InitializePostgresConn()
+
+	ctx := context.Background()
+	imageStore, err := newImageStore(ctx)
+	continueOrFatal(err)
+
+	// the image urls are read straight from the database, no cache is involved
+	productRepository := repository.NewProductRepository(db.PostgreSQL, nil, nil)
+	productImportJobRepository := repository.NewProductImportJobRepository(db.PostgreSQL)
+	productUsecase := usecase.NewProductUsecase(productRepository, productImportJobRepository, imageStore, nil)
+
+	report, err := productUsecase.RegenerateImageDerivatives(ctx)
+	continueOrFatal(err)
+
+	log.WithFields(log.Fields{
+		"regenerated": report.Regenerated,
+		"failed":      report.Failed,
+		"skipped":     report.Skipped,
+	}).Info("regenerated the image derivatives")
+	if report.Failed > 0 {
+		log.Fatal("failed to regenerate the derivatives of some images, see the errors above")
+	}
+}
@@ -0,0 +1,35 @@
+package console
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/binus-thesis-team/product-service/internal/config"
+	"github.com/binus-thesis-team/product-service/internal/model"
+	"github.com/binus-thesis-team/product-service/internal/repository"
+)
+
+const (
+	imageStoreDriverLocal = "local"
+	imageStoreDriverS3    = "s3"
+)
+
+// newImageStore returns the image store of the image_store.driver config
+func newImageStore(ctx context.Context) (model.ImageStore, error) {
+	switch config.ImageStoreDriver() {
+	case imageStoreDriverLocal:
+		return repository.NewLocalImageStore(config.ImageStoreLocalDir(), config.ImageStoreBaseURL()), nil
+	case imageStoreDriverS3:
+		return repository.NewS3ImageStore(ctx, repository.S3ImageStoreOptions{
+			Endpoint:        config.ImageStoreS3Endpoint(),
+			Region:          config.ImageStoreS3Region(),
+			Bucket:          config.ImageStoreS3Bucket(),
+			AccessKeyID:     config.ImageStoreS3AccessKeyID(),
+			SecretAccessKey: config.ImageStoreS3SecretAccessKey(),
+			UseSSL:          config.ImageStoreS3UseSSL(),
+			BaseURL:         config.ImageStoreBaseURL(),
+		})
+	default:
+		return nil, fmt.Errorf("unknown image store driver %q", config.ImageStoreDriver())
+	}
+}
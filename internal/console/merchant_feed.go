@@ -0,0 +1,111 @@
+package console
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/binus-thesis-team/product-service/internal/db"
+	"github.com/binus-thesis-team/product-service/internal/model"
+	"github.com/binus-thesis-team/product-service/internal/repository"
+	"github.com/binus-thesis-team/product-service/internal/usecase"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var merchantFeedCmd = &cobra.Command{
+	Use:   "merchant-feed",
+	Short: "generate the merchant product feed",
+	Long: `Render the published products as a Google Merchant Center feed, RSS 2.0 xml or tsv.
+The feed is regenerated from the state file of the previous run, reading only the products changed since`,
+	Args: cobra.NoArgs,
+	Run:  processMerchantFeed,
+}
+
+func init() {
+	merchantFeedCmd.Flags().String("format", "xml", "feed format, either xml or tsv")
+	merchantFeedCmd.Flags().String("output", "", "output file, default to merchant-feed.<format>")
+	merchantFeedCmd.Flags().String("state", "", "state file of the incremental regeneration, default to <output>.state.json")
+	merchantFeedCmd.Flags().Bool("full", false, "ignore the state file and read the whole catalog")
+
+	RootCmd.AddCommand(merchantFeedCmd)
+}
+
+func processMerchantFeed(cmd *cobra.Command, args []string) {
+	formatStr, _ := cmd.Flags().GetString("format")
+	output, _ := cmd.Flags().GetString("output")
+	statePath, _ := cmd.Flags().GetString("state")
+	full, _ := cmd.Flags().GetBool("full")
+
+	format := model.ProductFeedFormat(formatStr)
+	if !format.IsValid() {
+		log.WithField("format", format).Fatal("unknown feed format, use xml or tsv")
+	}
+	if output == "" {
+		output = fmt.Sprintf("merchant-feed.%s", format)
+	}
+	if statePath == "" {
+		statePath = output + ".state.json"
+	}
+
+	var previous *model.ProductFeedSnapshot
+	if !full {
+		var err error
+		previous, err = readProductFeedSnapshot(statePath)
+		continueOrFatal(err)
+	}
+
+	db.InitializePostgresConn()
+
+	productRepository := repository.NewProductRepository(db.PostgreSQL, nil, nil)
+	productImportJobRepository := repository.NewProductImportJobRepository(db.PostgreSQL)
+	productUsecase := usecase.NewProductUsecase(productRepository, productImportJobRepository, nil, nil)
+
+	// written next to the output then renamed, the feed being fetched is never a partial one
+	file, err := os.CreateTemp(filepath.Dir(output), filepath.Base(output)+".*.tmp")
+	continueOrFatal(err)
+
+	snapshot, err := productUsecase.GenerateFeed(context.Background(), previous, file, format)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), output)
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+		log.Fatal(err)
+	}
+
+	continueOrFatal(writeProductFeedSnapshot(statePath, snapshot))
+
+	log.Infof("Generated the feed of %d products to %s", len(snapshot.Items), output)
+}
+
+// readProductFeedSnapshot returns nil when there is no state file yet
+func readProductFeedSnapshot(path string) (*model.ProductFeedSnapshot, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &model.ProductFeedSnapshot{}
+	if err := json.Unmarshal(content, snapshot); err != nil {
+		return nil, fmt.Errorf("invalid state file %s, run with --full: %w", path, err)
+	}
+	return snapshot, nil
+}
+
+func writeProductFeedSnapshot(path string, snapshot *model.ProductFeedSnapshot) error {
+	content, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0o644)
+}
@@ -7,6 +7,7 @@ import (
 	runtime "github.com/banzaicloud/logrus-runtime-formatter"
 	"github.com/binus-thesis-team/product-service/internal/config"
 	"github.com/binus-thesis-team/product-service/internal/db"
+	logrushook "github.com/binus-thesis-team/product-service/pkg/logrus_hook"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -56,6 +57,7 @@ func setupLogger() {
 
 	log.SetFormatter(&formatter)
 	log.SetOutput(os.Stdout)
+	log.AddHook(&logrushook.Trace{})
 
 	//logLevel, err := log.ParseLevel(config.LogLevel())
 	//if err != nil {
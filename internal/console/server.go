@@ -19,12 +19,14 @@ import (
 	"github.com/binus-thesis-team/product-service/internal/delivery/grpcsvc"
 	"github.com/binus-thesis-team/product-service/internal/delivery/httpsvc"
 	"github.com/binus-thesis-team/product-service/internal/helper"
+	"github.com/binus-thesis-team/product-service/internal/model"
 	"github.com/binus-thesis-team/product-service/internal/repository"
 	"github.com/binus-thesis-team/product-service/internal/usecase"
 	pb "github.com/binus-thesis-team/product-service/pb/product_service"
 	"github.com/binus-thesis-team/product-service/pkg/utils"
 	"github.com/binus-thesis-team/product-service/pkg/utils/grpcutils"
 	productGrpcUtils "github.com/binus-thesis-team/product-service/pkg/utils/grpcutils"
+	redigo "github.com/gomodule/redigo/redis"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/labstack/gommon/log"
@@ -32,6 +34,9 @@ import (
 	"github.com/spf13/cobra"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 )
 
 var runServerCmd = &cobra.Command{
@@ -73,6 +78,12 @@ func runServer(cmd *cobra.Command, args []string) {
 
 	generalCacher.SetDisableCaching(config.DisableCaching())
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var localCache *repository.LocalCache
+	var requestCounter *repository.RequestCounter
+	redisPools := []*redigo.Pool{authRedisConn}
 	if !config.DisableCaching() {
 		redisConn, err := db.NewRedigoRedisConnectionPool(config.RedisCacheHost(), redisOpts)
 		continueOrFatal(err)
@@ -82,9 +93,28 @@ func runServer(cmd *cobra.Command, args []string) {
 		continueOrFatal(err)
 		defer helper.WrapCloser(redisLockConn.Close)
 
+		redisPools = append(redisPools, redisConn)
 		generalCacher.SetConnectionPool(redisConn)
 		generalCacher.SetLockConnectionPool(redisLockConn)
 		generalCacher.SetDefaultTTL(config.CacheTTL())
+
+		if config.LocalCacheEnabled() {
+			localCache = repository.NewLocalCache(redisConn, config.LocalCacheInvalidationChannel(), config.LocalCacheSize(), config.LocalCacheTTL())
+			go localCache.Subscribe(ctx)
+		}
+
+		requestCounter = repository.NewRequestCounter(redisConn)
+		go requestCounter.Run(ctx, config.RequestCounterFlushInterval())
+	}
+
+	var idempotencyRepository model.IdempotencyRepository
+	if config.IdempotencyEnabled() {
+		idempotencyConn, err := db.NewRedigoRedisConnectionPool(config.RedisIdempotencyHost(), redisOpts)
+		continueOrFatal(err)
+		defer helper.WrapCloser(idempotencyConn.Close)
+
+		redisPools = append(redisPools, idempotencyConn)
+		idempotencyRepository = repository.NewIdempotencyRepository(idempotencyConn, config.IdempotencyTTL(), config.IdempotencyInFlightTTL())
 	}
 
 	location, locErr := utils.SetTimeLocation("Asia/Jakarta")
@@ -94,25 +124,73 @@ func runServer(cmd *cobra.Command, args []string) {
 
 	time.Local = location
 
-	productRepository := repository.NewProductRepository(db.PostgreSQL, generalCacher)
-	productUsecase := usecase.NewProductUsecase(productRepository)
+	imageStore, err := newImageStore(ctx)
+	continueOrFatal(err)
+	if config.MediaSignedURLsEnabled() && config.MediaSigningKey() == "" {
+		logrus.Fatal("media.signed_urls.signing_key is required when the signed urls are enabled")
+	}
+
+	productRepository := repository.NewProductRepository(db.PostgreSQL, generalCacher, localCache)
+	productImportJobRepository := repository.NewProductImportJobRepository(db.PostgreSQL)
+	productUsecase := usecase.NewProductUsecase(productRepository, productImportJobRepository, imageStore, requestCounter)
+	supplierFeedRepository := repository.NewSupplierFeedRepository(db.PostgreSQL)
+	supplierFeedUsecase := usecase.NewSupplierFeedUsecase(supplierFeedRepository, productRepository, productImportJobRepository, nil)
+	go runImportJobReaper(ctx, productUsecase, supplierFeedUsecase, config.ImportJobStaleAfter())
 	iamAuthAdapter := auth.NewIAMServiceAdapter(newIAMClient)
 	authMiddleware := auth.NewAuthenticationMiddleware(iamAuthAdapter, authenticationCacher)
 	grpcAuthMD := auth.NewGRPCMiddleware(iamAuthAdapter, authenticationCacher)
 
-	grpcSvc := grpc.NewServer(grpc.ChainUnaryInterceptor(
-		serverInterceptor,
-		grpcAuthMD.Authenticate(),
-	))
+	grpcSvc := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(
+			grpcsvc.RequestIDUnaryInterceptor(),
+			grpcsvc.AccessLogUnaryInterceptor(),
+			grpcsvc.RecoveryUnaryInterceptor(),
+			serverInterceptor,
+			grpcAuthMD.Authenticate(),
+			grpcsvc.IdempotencyUnaryInterceptor(idempotencyRepository,
+				pb.ProductService_CreateProduct_FullMethodName,
+				pb.ProductService_UploadProducts_FullMethodName,
+				pb.ProductService_UpdateProduct_FullMethodName,
+				pb.ProductService_PatchProduct_FullMethodName,
+				pb.ProductService_BulkMutateProducts_FullMethodName,
+			),
+		),
+		grpc.ChainStreamInterceptor(
+			grpcsvc.RequestIDStreamInterceptor(),
+			grpcsvc.AccessLogStreamInterceptor(),
+			grpcsvc.RecoveryStreamInterceptor(),
+			grpcsvc.StreamInterceptorFromUnary(grpcAuthMD.Authenticate()),
+		),
+	)
+
+	healthSvc := health.NewServer()
+	go grpcsvc.RunHealthChecks(ctx, healthSvc, config.GRPCHealthCheckInterval(), map[string]grpcsvc.HealthCheck{
+		"postgres": pingPostgres,
+		"redis": func(ctx context.Context) error {
+			return pingRedis(ctx, redisPools...)
+		},
+	})
 
 	httpServer := echo.New()
+	httpServer.HTTPErrorHandler = httpsvc.HTTPErrorHandler
 	httpServer.Pre(middleware.AddTrailingSlash())
+	httpServer.Use(middleware.RequestIDWithConfig(middleware.RequestIDConfig{
+		RequestIDHandler: httpsvc.RequestIDHandler,
+	}))
 	httpServer.Use(middleware.Logger())
 	httpServer.Use(middleware.Recover())
-	httpServer.Use(middleware.CORS())
+	httpServer.Use(middleware.CORSWithConfig(middleware.CORSConfig{
+		// the ETag is sent back as If-Match by the admin dashboard
+		ExposeHeaders: []string{"ETag", "Idempotent-Replayed"},
+	}))
 
+	httpsvc.RouteMedia(httpServer.Group("/media"), productUsecase)
 	apiGroup := httpServer.Group("/api")
-	httpsvc.RouteService(apiGroup, productUsecase, authMiddleware)
+	httpsvc.RouteService(apiGroup, productUsecase, supplierFeedUsecase, authMiddleware, idempotencyRepository)
+
+	if config.SupplierFeedRunInServer() {
+		go runSupplierFeedScheduler(ctx, supplierFeedUsecase, config.SupplierFeedPollInterval())
+	}
 
 	sigCh := make(chan os.Signal, 1)
 	errCh := make(chan error, 1)
@@ -123,11 +201,13 @@ func runServer(cmd *cobra.Command, args []string) {
 		for {
 			select {
 			case <-sigCh:
-				gracefulShutdown(grpcSvc, httpServer)
+				healthSvc.Shutdown()
+				gracefulShutdown(grpcSvc, httpServer, productUsecase)
 				quitCh <- true
 			case e := <-errCh:
 				log.Error(e)
-				gracefulShutdown(grpcSvc, httpServer)
+				healthSvc.Shutdown()
+				gracefulShutdown(grpcSvc, httpServer, productUsecase)
 				quitCh <- true
 			}
 		}
@@ -149,6 +229,10 @@ func runServer(cmd *cobra.Command, args []string) {
 		svc.RegisterCacheManager(generalCacher)
 
 		pb.RegisterProductServiceServer(grpcSvc, svc)
+		healthpb.RegisterHealthServer(grpcSvc, healthSvc)
+		if config.GRPCReflectionEnabled() {
+			reflection.Register(grpcSvc)
+		}
 
 		// Start gRPC server
 		lis, err := net.Listen("tcp", fmt.Sprintf(":%s", config.GRPCPort()))
@@ -165,7 +249,7 @@ func runServer(cmd *cobra.Command, args []string) {
 	log.Info("exiting")
 }
 
-func gracefulShutdown(grpcSvr *grpc.Server, httpSvr *echo.Echo) {
+func gracefulShutdown(grpcSvr *grpc.Server, httpSvr *echo.Echo, productUsecase model.ProductUsecase) {
 	db.StopTickerCh <- true
 
 	if grpcSvr != nil {
@@ -179,6 +263,43 @@ func gracefulShutdown(grpcSvr *grpc.Server, httpSvr *echo.Echo) {
 			httpSvr.Logger.Fatal(err)
 		}
 	}
+
+	// no import is queued once the servers are stopped
+	ctx, cancel := context.WithTimeout(context.Background(), config.ImportShutdownTimeout())
+	defer cancel()
+	if err := productUsecase.DrainImportJobs(ctx); err != nil {
+		logrus.WithError(err).Error("import jobs still running on shutdown")
+	}
+}
+
+// runImportJobReaper fails the stale import jobs and supplier feed runs, e.g. of the previous process,
+// at once then every interval until ctx is done
+func runImportJobReaper(ctx context.Context, productUsecase model.ProductUsecase, supplierFeedUsecase model.SupplierFeedUsecase, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		count, err := productUsecase.FailStaleImportJobs(ctx)
+		if err != nil {
+			logrus.WithError(err).Error("failed to fail the stale import jobs")
+		} else if count > 0 {
+			logrus.Warnf("Failed %d stale import jobs", count)
+		}
+
+		// after the jobs, a run is stale once its job is
+		count, err = supplierFeedUsecase.FailStaleRuns(ctx)
+		if err != nil {
+			logrus.WithError(err).Error("failed to fail the stale supplier feed runs")
+		} else if count > 0 {
+			logrus.Warnf("Failed %d stale supplier feed runs", count)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
 }
 
 func clientInterceptor() grpc.UnaryClientInterceptor {
@@ -215,6 +336,30 @@ func continueOrFatal(err error) {
 	}
 }
 
+func pingPostgres(ctx context.Context) error {
+	// the connection is replaced on reconnect, it's taken again on every check
+	pgDB, err := db.PostgreSQL.DB()
+	if err != nil {
+		return err
+	}
+	return pgDB.PingContext(ctx)
+}
+
+func pingRedis(ctx context.Context, pools ...*redigo.Pool) error {
+	for _, pool := range pools {
+		conn, err := pool.GetContext(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = conn.Do("PING")
+		_ = conn.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func serverInterceptor(ctx context.Context,
 	req interface{},
 	_ *grpc.UnaryServerInfo,
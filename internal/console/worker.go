@@ -0,0 +1,100 @@
+package console
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/binus-thesis-team/cacher"
+	"github.com/binus-thesis-team/product-service/internal/config"
+	"github.com/binus-thesis-team/product-service/internal/db"
+	"github.com/binus-thesis-team/product-service/internal/helper"
+	"github.com/binus-thesis-team/product-service/internal/model"
+	"github.com/binus-thesis-team/product-service/internal/repository"
+	"github.com/binus-thesis-team/product-service/internal/usecase"
+	redigo "github.com/gomodule/redigo/redis"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var workerCmd = &cobra.Command{
+	Use:   "worker",
+	Short: "run supplier feed worker",
+	Long: `Fetch and import the supplier feeds on their schedule, without serving any request.
+Set supplier_feed.run_in_server to false when the feeds are run by this worker only`,
+	Args: cobra.NoArgs,
+	Run:  runWorker,
+}
+
+func init() {
+	workerCmd.Flags().Bool("once", false, "run the due feeds once then exit")
+
+	RootCmd.AddCommand(workerCmd)
+}
+
+func runWorker(cmd *cobra.Command, args []string) {
+	once, _ := cmd.Flags().GetBool("once")
+
+	db.InitializePostgresConn()
+
+	pgDB, err := db.PostgreSQL.DB()
+	continueOrFatal(err)
+	defer helper.WrapCloser(pgDB.Close)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	// the feed imports invalidate the products they write, in redis and in the local caches of the server replicas
+	generalCacher := cacher.NewCacheManager()
+	generalCacher.SetDisableCaching(config.DisableCaching())
+	var localCache *repository.LocalCache
+	if !config.DisableCaching() {
+		var redisConn *redigo.Pool
+		var closeConn func()
+		generalCacher, redisConn, closeConn = newGeneralCacheManager()
+		defer closeConn()
+
+		if config.LocalCacheEnabled() {
+			localCache = repository.NewLocalCache(redisConn, config.LocalCacheInvalidationChannel(), config.LocalCacheSize(), config.LocalCacheTTL())
+			go localCache.Subscribe(ctx)
+		}
+	}
+
+	productRepository := repository.NewProductRepository(db.PostgreSQL, generalCacher, localCache)
+	productImportJobRepository := repository.NewProductImportJobRepository(db.PostgreSQL)
+	supplierFeedRepository := repository.NewSupplierFeedRepository(db.PostgreSQL)
+	supplierFeedUsecase := usecase.NewSupplierFeedUsecase(supplierFeedRepository, productRepository, productImportJobRepository, nil)
+
+	if once {
+		count, err := supplierFeedUsecase.RunDueFeeds(ctx)
+		continueOrFatal(err)
+
+		log.Infof("Ran %d supplier feeds", count)
+		return
+	}
+
+	runSupplierFeedScheduler(ctx, supplierFeedUsecase, config.SupplierFeedPollInterval())
+	log.Info("exiting")
+}
+
+// runSupplierFeedScheduler runs the due feeds every interval until ctx is done
+func runSupplierFeedScheduler(ctx context.Context, supplierFeedUsecase model.SupplierFeedUsecase, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		count, err := supplierFeedUsecase.RunDueFeeds(ctx)
+		if err != nil {
+			log.WithError(err).Error("failed to run supplier feeds")
+		} else if count > 0 {
+			log.Infof("Ran %d supplier feeds", count)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
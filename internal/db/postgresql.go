@@ -96,7 +96,8 @@ func reconnectPostgresConn() {
 
 func openPostgresConn(dsn string) (*gorm.DB, error) {
 	dialector := postgres.Open(dsn)
-	db, err := gorm.Open(dialector, &gorm.Config{})
+	// TranslateError maps constraint violations to gorm errors, e.g. gorm.ErrDuplicatedKey
+	db, err := gorm.Open(dialector, &gorm.Config{TranslateError: true})
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,125 @@
+package grpcsvc
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/binus-thesis-team/product-service/internal/model"
+	"github.com/binus-thesis-team/product-service/internal/usecase"
+	"github.com/binus-thesis-team/product-service/pkg/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"gorm.io/gorm"
+)
+
+// newGRPCError maps a usecase error to a grpc status carrying an ErrorInfo with the reason,
+// plus the field violations of a validation failure. An unexpected error is logged along with req.
+func newGRPCError(ctx context.Context, req any, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	var fieldErr *model.FieldError
+	var validationErrs validator.ValidationErrors
+	var headerErr *model.ImportHeaderError
+	switch {
+	case errors.As(err, &fieldErr):
+		return newValidationStatus(fieldErr.Message, &errdetails.BadRequest_FieldViolation{
+			Field:       fieldErr.Field,
+			Description: fieldErr.Message,
+		})
+	case errors.As(err, &validationErrs):
+		violations := make([]*errdetails.BadRequest_FieldViolation, 0, len(validationErrs))
+		for _, validationErr := range validationErrs {
+			violations = append(violations, &errdetails.BadRequest_FieldViolation{
+				Field:       validationErr.Field(),
+				Description: validationErr.Error(),
+			})
+		}
+		return newValidationStatus(validationErrs.Error(), violations...)
+	case errors.As(err, &headerErr):
+		violations := make([]*errdetails.BadRequest_FieldViolation, 0)
+		for _, column := range headerErr.MissingColumns {
+			violations = append(violations, &errdetails.BadRequest_FieldViolation{Field: column, Description: "missing column"})
+		}
+		for _, column := range headerErr.UnknownColumns {
+			violations = append(violations, &errdetails.BadRequest_FieldViolation{Field: column, Description: "unknown column"})
+		}
+		for _, column := range headerErr.DuplicateColumns {
+			violations = append(violations, &errdetails.BadRequest_FieldViolation{Field: column, Description: "duplicate column"})
+		}
+		return newStatus(codes.InvalidArgument, model.ErrorReasonInvalidImportFile, headerErr.Error(), nil, &errdetails.BadRequest{FieldViolations: violations})
+	case errors.Is(err, usecase.ErrInvalidImportFile):
+		return newStatus(codes.InvalidArgument, model.ErrorReasonInvalidImportFile, err.Error(), nil)
+	case errors.Is(err, usecase.ErrInvalidImportMode):
+		return newStatus(codes.InvalidArgument, model.ErrorReasonInvalidImportMode, err.Error(), nil)
+	case errors.Is(err, usecase.ErrImportQueueFull):
+		return newStatus(codes.ResourceExhausted, model.ErrorReasonImportQueueFull, err.Error(), nil)
+	case errors.Is(err, usecase.ErrNotFound):
+		return newStatus(codes.NotFound, model.ErrorReasonNotFound, "not found", nil)
+	case errors.Is(err, usecase.ErrDuplicateProduct), errors.Is(err, gorm.ErrDuplicatedKey):
+		return newStatus(codes.AlreadyExists, model.ErrorReasonDuplicateProduct, usecase.ErrDuplicateProduct.Error(), nil)
+	case errors.Is(err, usecase.ErrPermissionDenied):
+		return newStatus(codes.PermissionDenied, model.ErrorReasonPermissionDenied, err.Error(), nil)
+	case errors.Is(err, usecase.ErrVersionConflict):
+		return newStatus(codes.Aborted, model.ErrorReasonVersionConflict, err.Error(), nil)
+	case errors.Is(err, context.DeadlineExceeded):
+		return newStatus(codes.DeadlineExceeded, model.ErrorReasonTimeout, err.Error(), nil)
+	case errors.Is(err, context.Canceled):
+		return newStatus(codes.Canceled, model.ErrorReasonCanceled, err.Error(), nil)
+	default:
+		logrus.WithContext(ctx).WithFields(logrus.Fields{
+			"ctx": utils.DumpIncomingContext(ctx),
+			"req": utils.Dump(req),
+		}).Error(err)
+		return newStatus(codes.Internal, model.ErrorReasonInternal, "something wrong", nil)
+	}
+}
+
+// errorReason returns the reason and the message of the status newGRPCError maps the error to,
+// for the errors reported within a response
+func errorReason(ctx context.Context, req any, err error) (model.ErrorReason, string) {
+	st := status.Convert(newGRPCError(ctx, req, err))
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return model.ErrorReason(info.GetReason()), st.Message()
+		}
+	}
+	return model.ErrorReasonInternal, st.Message()
+}
+
+func newValidationStatus(message string, violations ...*errdetails.BadRequest_FieldViolation) error {
+	fields := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		fields = append(fields, violation.GetField())
+	}
+
+	return newStatus(codes.InvalidArgument, model.ErrorReasonValidationFailed, message,
+		map[string]string{"fields": strings.Join(fields, ",")},
+		&errdetails.BadRequest{FieldViolations: violations},
+	)
+}
+
+// newStatus returns a status carrying an ErrorInfo with the reason and the metadata, followed by the details
+func newStatus(code codes.Code, reason model.ErrorReason, message string, metadata map[string]string, details ...protoadapt.MessageV1) error {
+	details = append([]protoadapt.MessageV1{&errdetails.ErrorInfo{
+		Reason:   string(reason),
+		Domain:   model.ErrorDomain,
+		Metadata: metadata,
+	}}, details...)
+
+	st, err := status.New(code, message).WithDetails(details...)
+	if err != nil {
+		// the details are well known messages, it shouldn't happen
+		return status.Error(code, message)
+	}
+	return st.Err()
+}
@@ -0,0 +1,50 @@
+package grpcsvc
+
+import (
+	"context"
+	"time"
+
+	pb "github.com/binus-thesis-team/product-service/pb/product_service"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// healthCheckTimeout bounds a single dependency check
+const healthCheckTimeout = 2 * time.Second
+
+// HealthCheck returns an error when the dependency is unreachable
+type HealthCheck func(ctx context.Context) error
+
+// RunHealthChecks runs the checks every interval until ctx is done. Each check is reported under its
+// own service name, e.g. postgres, while the server ("") and the product service are SERVING only
+// when every check passes.
+func RunHealthChecks(ctx context.Context, server *health.Server, interval time.Duration, checks map[string]HealthCheck) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		overall := healthpb.HealthCheckResponse_SERVING
+		for name, check := range checks {
+			checkCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+			err := check(checkCtx)
+			cancel()
+
+			if err != nil {
+				logrus.WithField("check", name).WithError(err).Warn("health check failed")
+				overall = healthpb.HealthCheckResponse_NOT_SERVING
+				server.SetServingStatus(name, healthpb.HealthCheckResponse_NOT_SERVING)
+				continue
+			}
+			server.SetServingStatus(name, healthpb.HealthCheckResponse_SERVING)
+		}
+		server.SetServingStatus("", overall)
+		server.SetServingStatus(pb.ProductService_ServiceDesc.ServiceName, overall)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
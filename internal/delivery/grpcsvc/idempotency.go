@@ -0,0 +1,127 @@
+package grpcsvc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/binus-thesis-team/product-service/internal/model"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const (
+	// IdempotencyKeyMetadataKey is the metadata key of the idempotency key of a request
+	IdempotencyKeyMetadataKey = "idempotency-key"
+	// IdempotentReplayedMetadataKey is set in the response header of a replayed response
+	IdempotentReplayedMetadataKey = "idempotent-replayed"
+
+	maxIdempotencyKeyLength = 255
+)
+
+// IdempotencyUnaryInterceptor replays the response of the methods retried with the same idempotency-key metadata,
+// only the successful responses are kept so a failed request can be retried with its key.
+// It must run after the authentication, the keys are scoped to the user. A nil repo disables it.
+func IdempotencyUnaryInterceptor(repo model.IdempotencyRepository, methods ...string) grpc.UnaryServerInterceptor {
+	idempotentMethods := make(map[string]bool, len(methods))
+	for _, method := range methods {
+		idempotentMethods[method] = true
+	}
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		msg, ok := req.(proto.Message)
+		if repo == nil || !ok || !idempotentMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		clientKey := ""
+		if values := metadata.ValueFromIncomingContext(ctx, IdempotencyKeyMetadataKey); len(values) > 0 {
+			clientKey = values[0]
+		}
+		if clientKey == "" {
+			return handler(ctx, req)
+		}
+		if len(clientKey) > maxIdempotencyKeyLength {
+			return nil, newStatus(codes.InvalidArgument, model.ErrorReasonInvalidArgument,
+				fmt.Sprintf("%s must be at most %d characters", IdempotencyKeyMetadataKey, maxIdempotencyKeyLength), nil)
+		}
+
+		payload, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+		if err != nil {
+			return nil, newGRPCError(ctx, req, err)
+		}
+		sum := sha256.Sum256(payload)
+		fingerprint := hex.EncodeToString(sum[:])
+
+		user := model.GetUserFromCtx(ctx)
+		key := model.NewIdempotencyKey(user.GetUserID(), info.FullMethod, clientKey)
+		record, err := repo.Begin(ctx, key, fingerprint)
+		if err != nil {
+			return nil, newGRPCError(ctx, req, err)
+		}
+		if record != nil {
+			return replayIdempotentResponse(ctx, req, record, fingerprint)
+		}
+
+		// the key is saved even when the client is gone, it's the request most likely retried
+		saveCtx := context.WithoutCancel(ctx)
+		resp, err := handler(ctx, req)
+		if err != nil {
+			_ = repo.Release(saveCtx, key)
+			return nil, err
+		}
+
+		if err := completeIdempotentResponse(saveCtx, repo, key, fingerprint, resp); err != nil {
+			logrus.WithContext(ctx).WithField("method", info.FullMethod).Error(err)
+		}
+		return resp, nil
+	}
+}
+
+func replayIdempotentResponse(ctx context.Context, req any, record *model.IdempotencyRecord, fingerprint string) (any, error) {
+	switch {
+	case record.Fingerprint != fingerprint:
+		return nil, newStatus(codes.InvalidArgument, model.ErrorReasonIdempotencyKeyReused,
+			"the idempotency key was used by a request with another payload", nil)
+	case !record.Completed:
+		return nil, newStatus(codes.Aborted, model.ErrorReasonIdempotencyKeyInFlight,
+			"a request with the idempotency key is in progress", nil)
+	}
+
+	stored := &anypb.Any{}
+	if err := proto.Unmarshal(record.Body, stored); err != nil {
+		return nil, newGRPCError(ctx, req, err)
+	}
+	resp, err := stored.UnmarshalNew()
+	if err != nil {
+		return nil, newGRPCError(ctx, req, err)
+	}
+
+	_ = grpc.SetHeader(ctx, metadata.Pairs(IdempotentReplayedMetadataKey, "true"))
+	return resp, nil
+}
+
+func completeIdempotentResponse(ctx context.Context, repo model.IdempotencyRepository, key, fingerprint string, resp any) error {
+	msg, ok := resp.(proto.Message)
+	if !ok {
+		return repo.Release(ctx, key)
+	}
+
+	stored, err := anypb.New(msg)
+	if err != nil {
+		_ = repo.Release(ctx, key)
+		return err
+	}
+	body, err := proto.Marshal(stored)
+	if err != nil {
+		_ = repo.Release(ctx, key)
+		return err
+	}
+
+	return repo.Complete(ctx, key, &model.IdempotencyRecord{Fingerprint: fingerprint, Body: body})
+}
@@ -0,0 +1,154 @@
+package grpcsvc
+
+import (
+	"context"
+	"runtime/debug"
+	"time"
+
+	"github.com/binus-thesis-team/product-service/pkg/trace"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RequestIDMetadataKey is the metadata key carrying the request id, both incoming and outgoing
+const RequestIDMetadataKey = "x-request-id"
+
+// RequestIDFromContext returns the request id set by the request id interceptors, empty when there is none
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(trace.Key).(string)
+	return requestID
+}
+
+// withRequestID takes the request id of the caller, or generates one, and puts it in the context
+// for the logs, in the response header and in the outgoing metadata of the calls made by the handler
+func withRequestID(ctx context.Context) context.Context {
+	var requestID string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(RequestIDMetadataKey); len(values) > 0 {
+			requestID = values[0]
+		}
+	}
+	if requestID == "" {
+		requestID = uuid.New().String()
+	}
+
+	_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDMetadataKey, requestID))
+	ctx = metadata.AppendToOutgoingContext(ctx, RequestIDMetadataKey, requestID)
+	return context.WithValue(ctx, trace.Key, requestID)
+}
+
+// RequestIDUnaryInterceptor :nodoc:
+func RequestIDUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		return handler(withRequestID(ctx), req)
+	}
+}
+
+// RequestIDStreamInterceptor :nodoc:
+func RequestIDStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &contextServerStream{ServerStream: ss, ctx: withRequestID(ss.Context())})
+	}
+}
+
+// AccessLogUnaryInterceptor logs every call with its method, latency and code
+func AccessLogUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logAccess(ctx, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// AccessLogStreamInterceptor :nodoc:
+func AccessLogStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		logAccess(ss.Context(), info.FullMethod, start, err)
+		return err
+	}
+}
+
+func logAccess(ctx context.Context, method string, start time.Time, err error) {
+	code := status.Code(err)
+	entry := logrus.WithContext(ctx).WithFields(logrus.Fields{
+		"method":  method,
+		"latency": time.Since(start).String(),
+		"code":    code.String(),
+	})
+
+	switch code {
+	case codes.OK:
+		entry.Info("grpc call")
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable, codes.DeadlineExceeded:
+		entry.WithError(err).Error("grpc call")
+	default:
+		entry.WithError(err).Warn("grpc call")
+	}
+}
+
+// RecoveryUnaryInterceptor turns a panic of the handler into an Internal error instead of crashing the process
+func RecoveryUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recoverPanic(ctx, info.FullMethod, r)
+			}
+		}()
+
+		return handler(ctx, req)
+	}
+}
+
+// RecoveryStreamInterceptor :nodoc:
+func RecoveryStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recoverPanic(ss.Context(), info.FullMethod, r)
+			}
+		}()
+
+		return handler(srv, ss)
+	}
+}
+
+// StreamInterceptorFromUnary runs a unary interceptor, e.g. the authentication of the iam service, at the start
+// of every stream. The stream is handled with the context the interceptor passes on, and not at all when
+// the interceptor fails.
+func StreamInterceptorFromUnary(interceptor grpc.UnaryServerInterceptor) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		unaryInfo := &grpc.UnaryServerInfo{Server: srv, FullMethod: info.FullMethod}
+		_, err := interceptor(ss.Context(), nil, unaryInfo, func(ctx context.Context, _ any) (any, error) {
+			return nil, handler(srv, &contextServerStream{ServerStream: ss, ctx: ctx})
+		})
+		return err
+	}
+}
+
+func recoverPanic(ctx context.Context, method string, r any) error {
+	logrus.WithContext(ctx).WithFields(logrus.Fields{
+		"method": method,
+		"panic":  r,
+		"stack":  string(debug.Stack()),
+	}).Error("recovered from panic")
+
+	return status.Error(codes.Internal, "something wrong")
+}
+
+// contextServerStream overrides the context of a server stream
+type contextServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context :nodoc:
+func (s *contextServerStream) Context() context.Context {
+	return s.ctx
+}
@@ -2,7 +2,9 @@ package grpcsvc
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"strings"
 
 	"github.com/binus-thesis-team/product-service/internal/config"
@@ -10,51 +12,32 @@ import (
 	"github.com/binus-thesis-team/product-service/internal/usecase"
 	pb "github.com/binus-thesis-team/product-service/pb/product_service"
 	"github.com/binus-thesis-team/product-service/pkg/utils"
-	"github.com/sirupsen/logrus"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
 // FindAllProductsByIDs :nodoc:
 func (s *Service) FindAllProductsByIDs(ctx context.Context, in *pb.FindByIDsRequest) (out *pb.Products, err error) {
 	products, err := s.productUsecase.FindByProductIDs(ctx, in.GetIds())
-	switch err {
-	case nil:
-		protoProducts := pb.Products{}
-
-		for _, item := range products {
-			protoProducts.Products = append(protoProducts.Products, item.ToProto())
-		}
+	if err != nil {
+		return nil, newGRPCError(ctx, in, err)
+	}
 
-		return &protoProducts, nil
-	case usecase.ErrNotFound:
-		return nil, status.Error(codes.NotFound, "not found")
-	default:
-		logrus.WithFields(logrus.Fields{
-			"ctx": utils.DumpIncomingContext(ctx),
-			"req": utils.Dump(in),
-		}).Error(err)
-		return nil, status.Error(codes.Internal, "something wrong")
+	protoProducts := pb.Products{}
+	for _, item := range products {
+		protoProducts.Products = append(protoProducts.Products, item.ToProto())
 	}
+
+	return &protoProducts, nil
 }
 
 // FindByProductId :nodoc:
 func (s *Service) FindByProductID(ctx context.Context, in *pb.FindByIDRequest) (out *pb.Product, err error) {
 	product, err := s.productUsecase.FindByID(ctx, in.GetId())
-	switch err {
-	case nil:
-		out = product.ToProto()
-
-		return out, nil
-	case usecase.ErrNotFound:
-		return nil, status.Error(codes.NotFound, "not found")
-	default:
-		logrus.WithFields(logrus.Fields{
-			"ctx": utils.DumpIncomingContext(ctx),
-			"req": utils.Dump(in),
-		}).Error(err)
-		return nil, status.Error(codes.Internal, "something wrong")
+	if err != nil {
+		return nil, newGRPCError(ctx, in, err)
 	}
+
+	return product.ToProto(), nil
 }
 
 func (s *Service) SearchAllProducts(ctx context.Context, req *pb.ProductSearchRequest) (out *pb.SearchResponse, err error) {
@@ -67,7 +50,7 @@ func (s *Service) SearchAllProducts(ctx context.Context, req *pb.ProductSearchRe
 
 	ids, count, err := s.productUsecase.SearchByPage(ctx, param)
 	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, newGRPCError(ctx, req, err)
 	}
 
 	return &pb.SearchResponse{
@@ -79,7 +62,7 @@ func (s *Service) SearchAllProducts(ctx context.Context, req *pb.ProductSearchRe
 func (s *Service) FindProductIDsByQuery(ctx context.Context, req *pb.FindByQueryRequest) (out *pb.SearchResponse, err error) {
 	ids, count, err := s.productUsecase.FindIDsByQuery(ctx, req.GetQuery())
 	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, newGRPCError(ctx, req, err)
 	}
 
 	return &pb.SearchResponse{
@@ -89,15 +72,331 @@ func (s *Service) FindProductIDsByQuery(ctx context.Context, req *pb.FindByQuery
 }
 
 func (s *Service) UploadProducts(ctx context.Context, req *pb.UploadProductsRequest) (out *pb.UploadProductsResponse, err error) {
-	err = s.productUsecase.UploadFileWithoutSession(ctx, model.UploadFileProductRequest{
-		ProductFile: req.GetContent(),
-	})
+	input := model.UploadFileProductRequest{
+		Filename:      req.GetFilename(),
+		ProductFile:   req.GetContent(),
+		ColumnMapping: req.GetColumnMapping(),
+		Mode:          model.ProductImportMode(req.GetMode()),
+		Atomic:        req.GetAtomic(),
+	}
+
+	if req.GetDryRun() {
+		report, err := s.productUsecase.DryRunUploadFileWithoutSession(ctx, input)
+		if err != nil {
+			return nil, newGRPCError(ctx, req.GetFilename(), err)
+		}
+
+		return &pb.UploadProductsResponse{
+			Success: true,
+			Message: fmt.Sprintf("Success validate file %s", req.GetFilename()),
+			Report:  report.ToProto(),
+		}, nil
+	}
+
+	job, err := s.productUsecase.UploadFileWithoutSession(ctx, input)
 	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, newGRPCError(ctx, req.GetFilename(), err)
 	}
 
 	return &pb.UploadProductsResponse{
 		Success: true,
-		Message: fmt.Sprintf("Success upload file %s", req.GetFilename()),
+		Message: fmt.Sprintf("Success queue file %s", req.GetFilename()),
+		JobId:   job.ID,
 	}, nil
 }
+
+// UploadProductsStream imports the csv chunks or the products while they are streamed,
+// the first message must be the header
+func (s *Service) UploadProductsStream(stream pb.ProductService_UploadProductsStreamServer) error {
+	ctx := stream.Context()
+
+	first, err := stream.Recv()
+	if err != nil {
+		return newGRPCError(ctx, nil, fmt.Errorf("%w: the first message must be the header", usecase.ErrInvalidImportFile))
+	}
+	header := first.GetHeader()
+	if header == nil {
+		return newGRPCError(ctx, nil, fmt.Errorf("%w: the first message must be the header", usecase.ErrInvalidImportFile))
+	}
+
+	reader := &uploadStreamReader{stream: stream}
+	input := model.UploadStreamProductRequest{
+		Filename:      header.GetFilename(),
+		ColumnMapping: header.GetColumnMapping(),
+		Mode:          model.ProductImportMode(header.GetMode()),
+		Content:       reader,
+	}
+
+	// the second message tells the kind of stream
+	if err := reader.peek(); err != nil {
+		return newGRPCError(ctx, header, err)
+	}
+	if reader.pending != nil && reader.pending.GetProduct() != nil {
+		input.Content = nil
+		input.NextProduct = reader.nextProduct
+	}
+
+	report, err := s.productUsecase.UploadStreamWithoutSession(ctx, input)
+	if err != nil {
+		return newGRPCError(ctx, header, err)
+	}
+
+	return stream.SendAndClose(&pb.UploadProductsStreamResponse{
+		Success: true,
+		Message: fmt.Sprintf("Success import file %s", header.GetFilename()),
+		Report:  report.ToProto(),
+	})
+}
+
+// uploadStreamReader reads the messages of an UploadProductsStream call only when the import asks for
+// the next row, so the grpc flow control holds the client back while a batch is saved
+type uploadStreamReader struct {
+	stream  pb.ProductService_UploadProductsStreamServer
+	pending *pb.UploadProductsStreamRequest
+	chunk   []byte
+}
+
+// peek receives the next message without consuming it, pending stays nil at the end of the stream
+func (r *uploadStreamReader) peek() error {
+	if r.pending != nil {
+		return nil
+	}
+
+	msg, err := r.stream.Recv()
+	switch {
+	case err == io.EOF:
+		return nil
+	case err != nil:
+		return err
+	case msg.GetHeader() != nil:
+		return fmt.Errorf("%w: the header must be sent only once", usecase.ErrInvalidImportFile)
+	}
+
+	r.pending = msg
+	return nil
+}
+
+func (r *uploadStreamReader) next() (*pb.UploadProductsStreamRequest, error) {
+	if err := r.peek(); err != nil {
+		return nil, err
+	}
+	if r.pending == nil {
+		return nil, io.EOF
+	}
+
+	msg := r.pending
+	r.pending = nil
+	return msg, nil
+}
+
+// Read reads the csv chunks
+func (r *uploadStreamReader) Read(p []byte) (int, error) {
+	for len(r.chunk) == 0 {
+		msg, err := r.next()
+		if err != nil {
+			return 0, err
+		}
+		if msg.GetProduct() != nil {
+			return 0, fmt.Errorf("%w: chunks and products can't be mixed", usecase.ErrInvalidImportFile)
+		}
+		r.chunk = msg.GetChunk()
+	}
+
+	n := copy(p, r.chunk)
+	r.chunk = r.chunk[n:]
+	return n, nil
+}
+
+func (r *uploadStreamReader) nextProduct() (*model.Product, error) {
+	msg, err := r.next()
+	if err != nil {
+		return nil, err
+	}
+	if msg.GetProduct() == nil {
+		return nil, fmt.Errorf("%w: chunks and products can't be mixed", usecase.ErrInvalidImportFile)
+	}
+
+	return model.NewProductFromProto(msg.GetProduct()), nil
+}
+
+// CreateProduct :nodoc:
+func (s *Service) CreateProduct(ctx context.Context, req *pb.CreateProductRequest) (out *pb.Product, err error) {
+	product, err := s.productUsecase.Create(ctx, model.GetUserFromCtx(ctx), model.CreateProductRequest{
+		ExternalID:  req.GetExternalId(),
+		Name:        req.GetName(),
+		Price:       req.GetPrice(),
+		Stock:       req.GetStock(),
+		Description: req.GetDescription(),
+		ImageUrl:    req.GetImageUrl(),
+	})
+	if err != nil {
+		return nil, newGRPCError(ctx, req, err)
+	}
+
+	return product.ToProto(), nil
+}
+
+// UpdateProduct :nodoc:
+func (s *Service) UpdateProduct(ctx context.Context, req *pb.UpdateProductRequest) (out *pb.Product, err error) {
+	product, err := s.productUsecase.Update(ctx, model.GetUserFromCtx(ctx), model.UpdateProductRequest{
+		ID:              req.GetId(),
+		Name:            req.GetName(),
+		Price:           req.GetPrice(),
+		Stock:           req.GetStock(),
+		Description:     req.GetDescription(),
+		ImageUrl:        req.GetImageUrl(),
+		ExpectedVersion: req.GetExpectedVersion(),
+	})
+	if err != nil {
+		return nil, newGRPCError(ctx, req, err)
+	}
+
+	return product.ToProto(), nil
+}
+
+// PatchProduct updates the fields of the update mask only, an empty mask updates the non zero fields
+func (s *Service) PatchProduct(ctx context.Context, req *pb.PatchProductRequest) (out *pb.Product, err error) {
+	input, err := newPatchProductRequest(req)
+	if err != nil {
+		return nil, newGRPCError(ctx, req, err)
+	}
+
+	product, err := s.productUsecase.Patch(ctx, model.GetUserFromCtx(ctx), input)
+	if err != nil {
+		return nil, newGRPCError(ctx, req, err)
+	}
+
+	return product.ToProto(), nil
+}
+
+func newPatchProductRequest(req *pb.PatchProductRequest) (model.PatchProductRequest, error) {
+	input := model.PatchProductRequest{ID: req.GetId(), ExpectedVersion: req.GetExpectedVersion()}
+	product := req.GetProduct()
+
+	paths := req.GetUpdateMask().GetPaths()
+	if len(paths) == 0 {
+		paths = populatedProductPaths(product)
+	}
+
+	for _, path := range paths {
+		switch path {
+		case "external_id":
+			input.ExternalID = proto.String(product.GetExternalId())
+		case "name":
+			input.Name = proto.String(product.GetName())
+		case "price":
+			input.Price = proto.Float64(product.GetPrice())
+		case "stock":
+			input.Stock = proto.Int64(product.GetStock())
+		case "description":
+			input.Description = proto.String(product.GetDescription())
+		case "image_url":
+			input.ImageUrl = proto.String(product.GetImageUrl())
+		default:
+			return input, &model.FieldError{Field: "update_mask", Message: fmt.Sprintf("unknown field %q", path)}
+		}
+	}
+
+	return input, nil
+}
+
+// populatedProductPaths is the implied update mask of a request without one
+func populatedProductPaths(product *pb.Product) (paths []string) {
+	if product.GetExternalId() != "" {
+		paths = append(paths, "external_id")
+	}
+	if product.GetName() != "" {
+		paths = append(paths, "name")
+	}
+	if product.GetPrice() != 0 {
+		paths = append(paths, "price")
+	}
+	if product.GetStock() != 0 {
+		paths = append(paths, "stock")
+	}
+	if product.GetDescription() != "" {
+		paths = append(paths, "description")
+	}
+	if product.GetImageUrl() != "" {
+		paths = append(paths, "image_url")
+	}
+	return paths
+}
+
+// BulkMutateProducts applies the operations, the response holds the result of every operation at its index
+func (s *Service) BulkMutateProducts(ctx context.Context, req *pb.BulkMutateProductsRequest) (out *pb.BulkMutateProductsResponse, err error) {
+	input := model.BulkMutateProductsRequest{StopOnError: req.GetStopOnError()}
+	for i, operation := range req.GetOperations() {
+		bulkOperation := model.ProductBulkOperation{
+			Type:            model.ProductBulkOperationType(operation.GetType()),
+			ProductID:       operation.GetProductId(),
+			ExpectedVersion: operation.GetExpectedVersion(),
+			PricePercent:    operation.GetPricePercent(),
+			Stock:           operation.GetStock(),
+		}
+
+		if bulkOperation.Type == model.ProductBulkOperationUpdate {
+			bulkOperation.Fields, err = newPatchProductRequest(&pb.PatchProductRequest{
+				Id:         operation.GetProductId(),
+				Product:    operation.GetProduct(),
+				UpdateMask: operation.GetUpdateMask(),
+			})
+			var fieldErr *model.FieldError
+			if errors.As(err, &fieldErr) {
+				return nil, newGRPCError(ctx, req, &model.FieldError{
+					Field:   fmt.Sprintf("operations[%d].%s", i, fieldErr.Field),
+					Message: fieldErr.Message,
+				})
+			}
+			if err != nil {
+				return nil, newGRPCError(ctx, req, err)
+			}
+		}
+
+		input.Operations = append(input.Operations, bulkOperation)
+	}
+
+	results, err := s.productUsecase.BulkMutate(ctx, model.GetUserFromCtx(ctx), input)
+	if err != nil {
+		return nil, newGRPCError(ctx, req, err)
+	}
+
+	out = &pb.BulkMutateProductsResponse{}
+	for _, result := range results {
+		pbResult := &pb.ProductBulkResult{
+			Index:     int64(result.Index),
+			ProductId: result.ProductID,
+			Status:    string(result.Status),
+		}
+		if result.Err != nil {
+			reason, message := errorReason(ctx, req, result.Err)
+			pbResult.Reason = string(reason)
+			pbResult.Message = message
+		}
+		if result.Product != nil {
+			pbResult.Product = result.Product.ToProto()
+		}
+		out.Results = append(out.Results, pbResult)
+	}
+
+	return out, nil
+}
+
+// DeleteProduct soft deletes the product, it can be restored with RestoreProduct
+func (s *Service) DeleteProduct(ctx context.Context, req *pb.DeleteByIDRequest) (out *pb.Empty, err error) {
+	if err := s.productUsecase.DeleteByProductID(ctx, model.GetUserFromCtx(ctx), req.GetObjectId(), req.GetExpectedVersion()); err != nil {
+		return nil, newGRPCError(ctx, req, err)
+	}
+
+	return &pb.Empty{}, nil
+}
+
+// RestoreProduct :nodoc:
+func (s *Service) RestoreProduct(ctx context.Context, req *pb.MutateByIDRequest) (out *pb.Product, err error) {
+	product, err := s.productUsecase.RestoreByProductID(ctx, model.GetUserFromCtx(ctx), req.GetObjectId(), req.GetExpectedVersion())
+	if err != nil {
+		return nil, newGRPCError(ctx, req, err)
+	}
+
+	return product.ToProto(), nil
+}
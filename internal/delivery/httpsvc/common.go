@@ -2,6 +2,7 @@ package httpsvc
 
 import (
 	"github.com/binus-thesis-team/iam-service/utils"
+	"github.com/binus-thesis-team/product-service/internal/model"
 	"github.com/go-playground/validator"
 	"sync"
 )
@@ -66,14 +67,11 @@ func setSuccessResponse(data any) successResponse {
 	}
 }
 
+// errorResponse is rendered by HTTPErrorHandler
 type errorResponse struct {
-	Success bool `json:"success"`
-	Message any  `json:"message"`
-}
-
-func setErrorMessage(msg any) errorResponse {
-	return errorResponse{
-		Success: false,
-		Message: msg,
-	}
+	Success   bool              `json:"success"`
+	Code      model.ErrorReason `json:"code"`
+	Message   string            `json:"message"`
+	Details   any               `json:"details,omitempty"`
+	RequestID string            `json:"request_id,omitempty"`
 }
@@ -1,35 +1,210 @@
 package httpsvc
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"net/http"
 
-	"github.com/go-playground/validator"
+	"github.com/binus-thesis-team/product-service/internal/model"
+	"github.com/binus-thesis-team/product-service/internal/usecase"
+	"github.com/binus-thesis-team/product-service/pkg/trace"
+	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 )
 
+// Error is an error rendered by HTTPErrorHandler, Code is stable and the same reason sent by the grpc service
+type Error struct {
+	Status  int
+	Code    model.ErrorReason
+	Message string
+	// Details is rendered as is, e.g. the message of every invalid field
+	Details any
+}
+
+// Error :nodoc:
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// WithDetails returns a copy of the error with the details
+func (e *Error) WithDetails(details any) *Error {
+	copied := *e
+	copied.Details = details
+	return &copied
+}
+
+// WithMessage returns a copy of the error with the message
+func (e *Error) WithMessage(message string) *Error {
+	copied := *e
+	copied.Message = message
+	return &copied
+}
+
+func newError(status int, code model.ErrorReason, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
 var (
-	ErrInvalidArgument     = echo.NewHTTPError(http.StatusBadRequest, setErrorMessage("invalid argument"))
-	ErrInternal            = echo.NewHTTPError(http.StatusInternalServerError, setErrorMessage("internal system error"))
-	ErrUnauthenticated     = echo.NewHTTPError(http.StatusUnauthorized, setErrorMessage("unauthenticated"))
-	ErrNotFound            = echo.NewHTTPError(http.StatusNotFound, setErrorMessage("record not found"))
-	ErrProductAlreadyExist = echo.NewHTTPError(http.StatusBadRequest, setErrorMessage("product already exist on product"))
-	ErrPermissionDenied    = echo.NewHTTPError(http.StatusForbidden, setErrorMessage("permission denied"))
+	ErrInvalidArgument        = newError(http.StatusBadRequest, model.ErrorReasonInvalidArgument, "invalid argument")
+	ErrValidationFailed       = newError(http.StatusUnprocessableEntity, model.ErrorReasonValidationFailed, "validation failed")
+	ErrInternal               = newError(http.StatusInternalServerError, model.ErrorReasonInternal, "internal system error")
+	ErrUnauthenticated        = newError(http.StatusUnauthorized, model.ErrorReasonUnauthenticated, "unauthenticated")
+	ErrNotFound               = newError(http.StatusNotFound, model.ErrorReasonNotFound, "record not found")
+	ErrProductAlreadyExist    = newError(http.StatusConflict, model.ErrorReasonDuplicateProduct, "product already exist on product")
+	ErrPermissionDenied       = newError(http.StatusForbidden, model.ErrorReasonPermissionDenied, "permission denied")
+	ErrVersionConflict        = newError(http.StatusPreconditionFailed, model.ErrorReasonVersionConflict, "the product has been changed, If-Match doesn't match its ETag")
+	ErrInvalidImportFile      = newError(http.StatusUnprocessableEntity, model.ErrorReasonInvalidImportFile, "invalid import file")
+	ErrInvalidImportMode      = newError(http.StatusUnprocessableEntity, model.ErrorReasonInvalidImportMode, "invalid import mode")
+	ErrImportQueueFull        = newError(http.StatusTooManyRequests, model.ErrorReasonImportQueueFull, "too many import jobs are queued, retry later")
+	ErrFeedDisabled           = newError(http.StatusConflict, model.ErrorReasonSupplierFeedDisabled, "supplier feed is disabled")
+	ErrImageInUse             = newError(http.StatusConflict, model.ErrorReasonImageInUse, "the image is used by a product, deleted ones included, remove it from them first")
+	ErrIdempotencyKeyReused   = newError(http.StatusUnprocessableEntity, model.ErrorReasonIdempotencyKeyReused, "the Idempotency-Key was used by a request with another payload")
+	ErrIdempotencyKeyInFlight = newError(http.StatusConflict, model.ErrorReasonIdempotencyKeyInFlight, "a request with the Idempotency-Key is in progress")
+	ErrUnsupportedMediaType   = newError(http.StatusUnsupportedMediaType, model.ErrorReasonUnsupportedMediaType, "unsupported media type")
+	ErrInvalidSignature       = newError(http.StatusForbidden, model.ErrorReasonInvalidSignature, "the signed url is invalid or has expired")
 )
 
+// toHTTPError maps a usecase error to an *Error, an unexpected error is logged and hidden behind ErrInternal
+func toHTTPError(ctx context.Context, err error) error {
+	var httpErr *Error
+	var headerErr *model.ImportHeaderError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &httpErr):
+		return httpErr
+	case errors.As(err, &headerErr):
+		return ErrInvalidImportFile.WithMessage(headerErr.Error()).WithDetails(headerErr)
+	case errors.Is(err, usecase.ErrInvalidImportFile):
+		return ErrInvalidImportFile.WithMessage(err.Error())
+	case errors.Is(err, usecase.ErrInvalidImportMode):
+		return ErrInvalidImportMode.WithMessage(err.Error())
+	case errors.Is(err, usecase.ErrImportQueueFull):
+		return ErrImportQueueFull
+	case errors.Is(err, usecase.ErrNotFound):
+		return ErrNotFound
+	case errors.Is(err, usecase.ErrDuplicateProduct), errors.Is(err, gorm.ErrDuplicatedKey):
+		return ErrProductAlreadyExist
+	case errors.Is(err, usecase.ErrPermissionDenied):
+		return ErrPermissionDenied
+	case errors.Is(err, usecase.ErrVersionConflict):
+		return ErrVersionConflict
+	case errors.Is(err, usecase.ErrSupplierFeedDisabled):
+		return ErrFeedDisabled
+	case errors.Is(err, usecase.ErrImageInUse):
+		return ErrImageInUse
+	case errors.Is(err, usecase.ErrInvalidSignature):
+		return ErrInvalidSignature
+	}
+
+	validationErr := httpValidationOrInternalErr(err)
+	if validationErr == ErrInternal {
+		logrus.WithContext(ctx).Error(err)
+	}
+	return validationErr
+}
+
 // httpValidationOrInternalErr return valdiation or internal error
 func httpValidationOrInternalErr(err error) error {
-	validationErrors, ok := err.(validator.ValidationErrors)
-	if !ok {
+	var fieldErr *model.FieldError
+	if errors.As(err, &fieldErr) {
+		return ErrValidationFailed.WithMessage(fieldErr.Message).WithDetails(map[string]string{fieldErr.Field: fieldErr.Message})
+	}
+
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
 		// Jika tidak ada kesalahan validasi, mengembalikan kesalahan internal
 		return ErrInternal
 	}
 
 	fields := make(map[string]string)
 	for _, validationError := range validationErrors {
-		tag := validationError.Tag()
-		fields[validationError.Field()] = fmt.Sprintf("Failed on the '%s' tag", tag)
+		fields[validationError.Field()] = "Failed on the '" + validationError.Tag() + "' tag"
+	}
+
+	return ErrValidationFailed.WithDetails(fields)
+}
+
+// HTTPErrorHandler renders every error returned by the handlers and the middlewares in the errorResponse envelope
+func HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	httpErr := &Error{}
+	var echoErr *echo.HTTPError
+	switch {
+	case errors.As(err, &httpErr):
+	case errors.As(err, &echoErr):
+		httpErr = fromEchoHTTPError(echoErr)
+	default:
+		logrus.WithContext(c.Request().Context()).Error(err)
+		httpErr = ErrInternal
+	}
+
+	resp := errorResponse{
+		Success:   false,
+		Code:      httpErr.Code,
+		Message:   httpErr.Message,
+		Details:   httpErr.Details,
+		RequestID: requestIDFromContext(c.Request().Context()),
+	}
+
+	// set by a handler failing before it streams its file
+	c.Response().Header().Del(echo.HeaderContentType)
+	c.Response().Header().Del(echo.HeaderContentDisposition)
+
+	var renderErr error
+	if c.Request().Method == http.MethodHead {
+		renderErr = c.NoContent(httpErr.Status)
+	} else {
+		renderErr = c.JSON(httpErr.Status, resp)
+	}
+	if renderErr != nil {
+		logrus.WithContext(c.Request().Context()).Error(renderErr)
 	}
+}
+
+// fromEchoHTTPError converts the errors of echo itself and of the middlewares, e.g. an unknown route
+func fromEchoHTTPError(echoErr *echo.HTTPError) *Error {
+	message, ok := echoErr.Message.(string)
+	if !ok {
+		message = http.StatusText(echoErr.Code)
+	}
+
+	httpErr := &Error{Status: echoErr.Code, Message: message}
+	switch echoErr.Code {
+	case http.StatusBadRequest:
+		httpErr.Code = model.ErrorReasonInvalidArgument
+	case http.StatusUnauthorized:
+		httpErr.Code = model.ErrorReasonUnauthenticated
+	case http.StatusForbidden:
+		httpErr.Code = model.ErrorReasonPermissionDenied
+	case http.StatusNotFound:
+		httpErr.Code = model.ErrorReasonNotFound
+	case http.StatusMethodNotAllowed:
+		httpErr.Code = model.ErrorReasonMethodNotAllowed
+	case http.StatusRequestEntityTooLarge:
+		httpErr.Code = model.ErrorReasonRequestTooLarge
+	default:
+		if echoErr.Code >= http.StatusInternalServerError {
+			return ErrInternal
+		}
+		httpErr.Code = model.ErrorReasonInvalidArgument
+	}
+
+	return httpErr
+}
+
+// RequestIDHandler puts the request id of the RequestID middleware in the request context, for the logs and the error responses
+func RequestIDHandler(c echo.Context, requestID string) {
+	ctx := context.WithValue(c.Request().Context(), trace.Key, requestID)
+	c.SetRequest(c.Request().WithContext(ctx))
+}
 
-	return echo.NewHTTPError(http.StatusBadRequest, setErrorMessage(fields))
+func requestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(trace.Key).(string)
+	return requestID
 }
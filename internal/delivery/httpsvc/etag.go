@@ -0,0 +1,44 @@
+package httpsvc
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/binus-thesis-team/product-service/internal/model"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	headerETag    = "ETag"
+	headerIfMatch = "If-Match"
+)
+
+// setProductETag sets the strong ETag of the product, its version
+func setProductETag(c echo.Context, product *model.Product) {
+	c.Response().Header().Set(headerETag, strconv.Quote(strconv.FormatInt(product.Version, 10)))
+}
+
+// expectedVersionFromIfMatch returns the version of the If-Match header, 0 when it's missing or "*".
+// A weak or unknown entity tag can't match any version, so it's a version conflict.
+func expectedVersionFromIfMatch(c echo.Context) (int64, error) {
+	ifMatch := strings.TrimSpace(c.Request().Header.Get(headerIfMatch))
+	if ifMatch == "" || ifMatch == "*" {
+		return 0, nil
+	}
+
+	if strings.Contains(ifMatch, ",") {
+		return 0, ErrInvalidArgument.WithMessage("If-Match must hold a single entity tag")
+	}
+
+	unquoted, err := strconv.Unquote(ifMatch)
+	if err != nil || !strings.HasPrefix(ifMatch, `"`) {
+		return 0, ErrVersionConflict
+	}
+
+	version, err := strconv.ParseInt(unquoted, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, ErrVersionConflict
+	}
+
+	return version, nil
+}
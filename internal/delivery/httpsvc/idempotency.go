@@ -0,0 +1,176 @@
+package httpsvc
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+
+	"github.com/binus-thesis-team/product-service/internal/model"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	headerIdempotencyKey     = "Idempotency-Key"
+	headerIdempotentReplayed = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+)
+
+// idempotent replays the response of a request retried with the same Idempotency-Key header,
+// only the successful responses are kept so a failed request can be retried with its key.
+// A request without the header runs as usual.
+func (s *service) idempotent() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			clientKey := c.Request().Header.Get(headerIdempotencyKey)
+			if s.idempotencyRepository == nil || clientKey == "" {
+				return next(c)
+			}
+
+			ctx := c.Request().Context()
+			if len(clientKey) > maxIdempotencyKeyLength {
+				return ErrInvalidArgument.WithMessage(fmt.Sprintf("%s must be at most %d characters", headerIdempotencyKey, maxIdempotencyKeyLength))
+			}
+
+			fingerprint, err := requestFingerprint(c)
+			if err != nil {
+				logrus.WithContext(ctx).WithError(err).Error("failed to read the request")
+				return ErrInvalidArgument
+			}
+
+			user := model.GetUserFromCtx(ctx)
+			key := model.NewIdempotencyKey(user.GetUserID(), c.Request().Method+" "+c.Path(), clientKey)
+			record, err := s.idempotencyRepository.Begin(ctx, key, fingerprint)
+			if err != nil {
+				return toHTTPError(ctx, err)
+			}
+			if record != nil {
+				return replayIdempotentResponse(c, record, fingerprint)
+			}
+
+			recorder := &bodyRecorder{ResponseWriter: c.Response().Writer}
+			c.Response().Writer = recorder
+
+			// the key is saved even when the client is gone, it's the request most likely retried
+			saveCtx := context.WithoutCancel(ctx)
+			err = next(c)
+			status := c.Response().Status
+			if err != nil || !c.Response().Committed || status < http.StatusOK || status >= http.StatusMultipleChoices {
+				_ = s.idempotencyRepository.Release(saveCtx, key)
+				return err
+			}
+
+			_ = s.idempotencyRepository.Complete(saveCtx, key, &model.IdempotencyRecord{
+				Fingerprint: fingerprint,
+				StatusCode:  status,
+				ContentType: c.Response().Header().Get(echo.HeaderContentType),
+				Body:        recorder.body.Bytes(),
+			})
+			return nil
+		}
+	}
+}
+
+func replayIdempotentResponse(c echo.Context, record *model.IdempotencyRecord, fingerprint string) error {
+	switch {
+	case record.Fingerprint != fingerprint:
+		return ErrIdempotencyKeyReused
+	case !record.Completed:
+		return ErrIdempotencyKeyInFlight
+	}
+
+	c.Response().Header().Set(headerIdempotentReplayed, "true")
+	return c.Blob(record.StatusCode, record.ContentType, record.Body)
+}
+
+// requestFingerprint hashes the body of the request, a multipart form is hashed by its fields and files
+// since its boundary may change between the retries
+func requestFingerprint(c echo.Context) (string, error) {
+	sum := sha256.New()
+
+	// the key is scoped by route, the product of the path and the expected version are part of the request
+	writeFingerprintField(sum, c.Request().URL.RequestURI())
+	writeFingerprintField(sum, c.Request().Header.Get(headerIfMatch))
+
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	if mediaType != echo.MIMEMultipartForm {
+		body, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			return "", err
+		}
+		c.Request().Body = io.NopCloser(bytes.NewReader(body))
+
+		sum.Write(body)
+		return hex.EncodeToString(sum.Sum(nil)), nil
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		return "", err
+	}
+
+	for _, name := range sortedKeys(form.Value) {
+		writeFingerprintField(sum, name)
+		for _, value := range form.Value[name] {
+			writeFingerprintField(sum, value)
+		}
+	}
+
+	for _, name := range sortedKeys(form.File) {
+		writeFingerprintField(sum, name)
+		for _, fileHeader := range form.File[name] {
+			writeFingerprintField(sum, fileHeader.Filename)
+
+			file, err := fileHeader.Open()
+			if err != nil {
+				return "", err
+			}
+			_, err = io.Copy(sum, file)
+			_ = file.Close()
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+// writeFingerprintField writes the value prefixed by its length, so two forms can't share a fingerprint
+func writeFingerprintField(sum hash.Hash, value string) {
+	_, _ = fmt.Fprintf(sum, "%d:%s", len(value), value)
+}
+
+func sortedKeys[T any](values map[string]T) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// bodyRecorder keeps a copy of the response body written through it
+type bodyRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+// Write :nodoc:
+func (r *bodyRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the flusher of the underlying writer
+func (r *bodyRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
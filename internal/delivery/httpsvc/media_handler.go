@@ -0,0 +1,90 @@
+package httpsvc
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/binus-thesis-team/product-service/internal/config"
+	"github.com/binus-thesis-team/product-service/internal/model"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	headerCacheControl       = "Cache-Control"
+	headerContentTypeOptions = "X-Content-Type-Options"
+	defaultMediaContentType  = "application/octet-stream"
+	mediaQueryParamExpires   = "expires"
+	mediaQueryParamSignature = "signature"
+)
+
+// GetMedia streams the stored image of the path. The ranges and the conditional requests on its strong ETag
+// are answered by http.ServeContent. A public image is cached for MediaCacheMaxAge by any cache, the one of
+// a signed url only by the client until the url expires.
+func (s *service) GetMedia() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+
+		// the trailing slash is added to every path by the server
+		key, err := url.PathUnescape(strings.TrimSuffix(c.Param("*"), "/"))
+		if err != nil || key == "" {
+			return ErrNotFound
+		}
+
+		expires, _ := strconv.ParseInt(c.QueryParam(mediaQueryParamExpires), 10, 64)
+		media, err := s.productUsecase.OpenMedia(ctx, model.OpenMediaRequest{
+			Key:       key,
+			Expires:   expires,
+			Signature: c.QueryParam(mediaQueryParamSignature),
+		})
+		if err != nil {
+			return toHTTPError(ctx, err)
+		}
+		defer func() {
+			if err := media.Close(); err != nil {
+				logrus.WithContext(ctx).Error(err)
+			}
+		}()
+
+		contentType := media.ContentType
+		if contentType == "" {
+			contentType = defaultMediaContentType
+		}
+
+		header := c.Response().Header()
+		header.Set(echo.HeaderContentType, contentType)
+		header.Set(headerContentTypeOptions, "nosniff")
+		if media.ETag != "" {
+			header.Set(headerETag, strconv.Quote(media.ETag))
+		}
+		if media.ExpiresAt.IsZero() {
+			header.Set(headerCacheControl, fmt.Sprintf("public, max-age=%d", int64(config.MediaCacheMaxAge().Seconds())))
+		} else {
+			maxAge := int64(math.Max(0, time.Until(media.ExpiresAt).Seconds()))
+			header.Set(headerCacheControl, fmt.Sprintf("private, max-age=%d", maxAge))
+		}
+
+		http.ServeContent(c.Response(), c.Request(), "", media.ModTime, media.ImageObject)
+		return nil
+	}
+}
+
+// SignImageURL returns the signed urls of the uploaded image of the image_url query, e.g. to preview an image
+// that isn't attached to a published product yet
+func (s *service) SignImageURL() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+
+		signed, err := s.productUsecase.SignImageURL(ctx, model.GetUserFromCtx(ctx), c.QueryParam("image_url"))
+		if err != nil {
+			return toHTTPError(ctx, err)
+		}
+
+		return c.JSON(http.StatusOK, setSuccessResponse(signed))
+	}
+}
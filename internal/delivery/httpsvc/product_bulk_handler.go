@@ -0,0 +1,130 @@
+package httpsvc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/binus-thesis-team/product-service/internal/model"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+type bulkOperationRequest struct {
+	Type            model.ProductBulkOperationType `json:"type"`
+	ProductID       int64                          `json:"product_id"`
+	ExpectedVersion int64                          `json:"expected_version"`
+	// Fields is a JSON merge patch of the product, for an update
+	Fields       json.RawMessage `json:"fields"`
+	PricePercent *float64        `json:"price_percent"`
+	Stock        *int64          `json:"stock"`
+}
+
+type bulkResultResponse struct {
+	Index     int                           `json:"index"`
+	ProductID int64                         `json:"product_id"`
+	Status    model.ProductBulkResultStatus `json:"status"`
+	Code      model.ErrorReason             `json:"code,omitempty"`
+	Message   string                        `json:"message,omitempty"`
+	Details   any                           `json:"details,omitempty"`
+	Product   *model.Product                `json:"product,omitempty"`
+}
+
+// BulkMutate applies a list of operations, the response holds the result of every operation at its index
+func (s *service) BulkMutate() echo.HandlerFunc {
+	type request struct {
+		Operations  []bulkOperationRequest `json:"operations"`
+		StopOnError bool                   `json:"stop_on_error"`
+	}
+
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+
+		req := request{}
+		if err := c.Bind(&req); err != nil {
+			logrus.WithContext(ctx).Error(err)
+			return ErrInvalidArgument
+		}
+
+		input := model.BulkMutateProductsRequest{StopOnError: req.StopOnError}
+		for i, operation := range req.Operations {
+			bulkOperation, err := newProductBulkOperation(operation)
+			if err != nil {
+				var fieldErr *model.FieldError
+				if errors.As(err, &fieldErr) {
+					field := fmt.Sprintf("operations[%d].%s", i, fieldErr.Field)
+					return ErrValidationFailed.WithMessage(fieldErr.Message).WithDetails(map[string]string{field: fieldErr.Message})
+				}
+				return toHTTPError(ctx, err)
+			}
+			input.Operations = append(input.Operations, bulkOperation)
+		}
+
+		results, err := s.productUsecase.BulkMutate(ctx, model.GetUserFromCtx(ctx), input)
+		if err != nil {
+			return toHTTPError(ctx, err)
+		}
+
+		resp := make([]bulkResultResponse, 0, len(results))
+		for _, result := range results {
+			resp = append(resp, newBulkResultResponse(ctx, result))
+		}
+
+		return c.JSON(http.StatusOK, setSuccessResponse(resp))
+	}
+}
+
+func newProductBulkOperation(req bulkOperationRequest) (model.ProductBulkOperation, error) {
+	operation := model.ProductBulkOperation{
+		Type:            req.Type,
+		ProductID:       req.ProductID,
+		ExpectedVersion: req.ExpectedVersion,
+	}
+
+	switch req.Type {
+	case model.ProductBulkOperationUpdate:
+		if len(req.Fields) == 0 {
+			return operation, &model.FieldError{Field: "fields", Message: "Fields are required"}
+		}
+
+		fields, err := model.NewPatchProductRequestFromMergePatch(req.ProductID, req.Fields)
+		var fieldErr *model.FieldError
+		if errors.As(err, &fieldErr) {
+			return operation, &model.FieldError{Field: "fields." + fieldErr.Field, Message: fieldErr.Message}
+		}
+		operation.Fields = fields
+	case model.ProductBulkOperationAdjustPrice:
+		if req.PricePercent == nil {
+			return operation, &model.FieldError{Field: "price_percent", Message: "Price percent is required"}
+		}
+		operation.PricePercent = *req.PricePercent
+	case model.ProductBulkOperationSetStock:
+		if req.Stock == nil {
+			return operation, &model.FieldError{Field: "stock", Message: "Stock is required"}
+		}
+		operation.Stock = *req.Stock
+	}
+
+	return operation, nil
+}
+
+func newBulkResultResponse(ctx context.Context, result *model.ProductBulkResult) bulkResultResponse {
+	resp := bulkResultResponse{
+		Index:     result.Index,
+		ProductID: result.ProductID,
+		Status:    result.Status,
+		Product:   result.Product,
+	}
+
+	if result.Err != nil {
+		httpErr := ErrInternal
+		errors.As(toHTTPError(ctx, result.Err), &httpErr)
+		resp.Code = httpErr.Code
+		resp.Message = httpErr.Message
+		resp.Details = httpErr.Details
+	}
+
+	return resp
+}
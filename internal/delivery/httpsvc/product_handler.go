@@ -1,20 +1,26 @@
 package httpsvc
 
 import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
 	"github.com/binus-thesis-team/iam-service/utils"
 	"github.com/binus-thesis-team/product-service/internal/model"
-	"github.com/binus-thesis-team/product-service/internal/usecase"
 	"github.com/labstack/echo/v4"
 	"github.com/sirupsen/logrus"
 	"io"
+	"mime"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
+	"time"
 )
 
+// mergePatchMIME is the media type of a JSON merge patch, RFC 7396
+const mergePatchMIME = "application/merge-patch+json"
+
 func (s *service) Create() echo.HandlerFunc {
 	type request struct {
+		ExternalID  string  `json:"external_id"`
 		Name        string  `json:"name"`
 		Price       float64 `json:"price"`
 		Stock       int64   `json:"stock"`
@@ -32,24 +38,15 @@ func (s *service) Create() echo.HandlerFunc {
 		}
 
 		createdProduct, err := s.productUsecase.Create(ctx, model.GetUserFromCtx(ctx), model.CreateProductRequest{
+			ExternalID:  req.ExternalID,
 			Name:        req.Name,
 			Price:       req.Price,
 			Stock:       req.Stock,
 			Description: req.Description,
 			ImageUrl:    req.ImageUrl,
 		})
-		switch err {
-		case nil:
-			break
-		case usecase.ErrNotFound:
-			return ErrNotFound
-		case usecase.ErrDuplicateProduct:
-			return ErrProductAlreadyExist
-		case usecase.ErrPermissionDenied:
-			return ErrPermissionDenied
-		default:
-			logrus.Error(err)
-			return ErrInternal
+		if err != nil {
+			return toHTTPError(ctx, err)
 		}
 
 		return c.JSON(http.StatusCreated, setSuccessResponse(createdProduct))
@@ -63,17 +60,14 @@ func (s *service) GetDetail() echo.HandlerFunc {
 
 		product, err := s.productUsecase.FindByID(ctx, productID)
 		if err != nil {
-			logrus.WithContext(ctx).WithFields(logrus.Fields{
-				"product_id": productID,
-			}).Error(err)
-
-			return ErrInternal
+			return toHTTPError(ctx, err)
 		}
 
 		logrus.WithContext(ctx).WithFields(logrus.Fields{
 			"product_id": product.ID,
 		}).Info("success delete product from db")
 
+		setProductETag(c, product)
 		return c.JSON(http.StatusOK, setSuccessResponse(product))
 	}
 }
@@ -88,8 +82,7 @@ func (s *service) GetList() echo.HandlerFunc {
 		}
 		page, err := strconv.Atoi(pageStr)
 		if err != nil {
-			logrus.WithError(err).Error("failed to parse page")
-			return c.JSON(http.StatusBadRequest, err)
+			return ErrInvalidArgument.WithMessage("page must be an integer")
 		}
 
 		limitStr := c.QueryParam("limit")
@@ -98,8 +91,7 @@ func (s *service) GetList() echo.HandlerFunc {
 		}
 		limit, err := strconv.Atoi(limitStr)
 		if err != nil {
-			logrus.WithError(err).Error("failed to parse limit")
-			return c.JSON(http.StatusBadRequest, err)
+			return ErrInvalidArgument.WithMessage("limit must be an integer")
 		}
 
 		query := c.QueryParam("query")
@@ -114,8 +106,7 @@ func (s *service) GetList() echo.HandlerFunc {
 			SortDir: dir,
 		})
 		if err != nil {
-			logrus.WithError(err).Error("failed to get products")
-			return c.JSON(http.StatusBadRequest, err)
+			return toHTTPError(ctx, err)
 		}
 
 		logrus.WithFields(logrus.Fields{
@@ -140,8 +131,7 @@ func (s *service) handleFindProductIDsByQuery() echo.HandlerFunc {
 
 		productIDs, count, err := s.productUsecase.FindIDsByQuery(ctx, query)
 		if err != nil {
-			logrus.WithError(err).Error("failed to get products")
-			return c.JSON(http.StatusBadRequest, err)
+			return toHTTPError(ctx, err)
 		}
 
 		return c.JSON(http.StatusOK, setSuccessResponse(searchResponse{
@@ -170,43 +160,81 @@ func (s *service) Update() echo.HandlerFunc {
 		}
 		productID := utils.StringToInt64(c.Param("product_id"))
 
+		expectedVersion, err := expectedVersionFromIfMatch(c)
+		if err != nil {
+			return err
+		}
+
 		createdProduct, err := s.productUsecase.Update(ctx, model.GetUserFromCtx(ctx), model.UpdateProductRequest{
-			ID:          productID,
-			Name:        req.Name,
-			Price:       req.Price,
-			Stock:       req.Stock,
-			Description: req.Description,
-			ImageUrl:    req.ImageUrl,
+			ID:              productID,
+			Name:            req.Name,
+			Price:           req.Price,
+			Stock:           req.Stock,
+			Description:     req.Description,
+			ImageUrl:        req.ImageUrl,
+			ExpectedVersion: expectedVersion,
 		})
-		switch err {
-		case nil:
-			break
-		case usecase.ErrNotFound:
-			return ErrNotFound
-		case usecase.ErrDuplicateProduct:
-			return ErrProductAlreadyExist
-		case usecase.ErrPermissionDenied:
-			return ErrPermissionDenied
-		default:
-			logrus.Error(err)
-			return ErrInternal
+		if err != nil {
+			return toHTTPError(ctx, err)
 		}
 
+		setProductETag(c, createdProduct)
 		return c.JSON(http.StatusCreated, setSuccessResponse(createdProduct))
 	}
 }
 
+// Patch applies a JSON merge patch (RFC 7396) to the product, only the members of the patch are changed
+func (s *service) Patch() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+
+		mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+		if mediaType != mergePatchMIME && mediaType != echo.MIMEApplicationJSON {
+			return ErrUnsupportedMediaType
+		}
+
+		patch, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			logrus.WithContext(ctx).Error(err)
+			return ErrInvalidArgument
+		}
+		if !json.Valid(patch) {
+			return ErrInvalidArgument.WithMessage("invalid JSON merge patch")
+		}
+
+		productID := utils.StringToInt64(c.Param("product_id"))
+		input, err := model.NewPatchProductRequestFromMergePatch(productID, patch)
+		if err != nil {
+			return toHTTPError(ctx, err)
+		}
+
+		input.ExpectedVersion, err = expectedVersionFromIfMatch(c)
+		if err != nil {
+			return err
+		}
+
+		product, err := s.productUsecase.Patch(ctx, model.GetUserFromCtx(ctx), input)
+		if err != nil {
+			return toHTTPError(ctx, err)
+		}
+
+		setProductETag(c, product)
+		return c.JSON(http.StatusOK, setSuccessResponse(product))
+	}
+}
+
 func (s *service) Delete() echo.HandlerFunc {
 	return func(c echo.Context) error {
 		ctx := c.Request().Context()
 		productID := utils.StringToInt64(c.Param("product_id"))
 
-		if err := s.productUsecase.DeleteByProductID(ctx, model.GetUserFromCtx(ctx), productID); err != nil {
-			logrus.WithContext(ctx).WithFields(logrus.Fields{
-				"product_id": productID,
-			}).Error(err)
+		expectedVersion, err := expectedVersionFromIfMatch(c)
+		if err != nil {
+			return err
+		}
 
-			return ErrInternal
+		if err := s.productUsecase.DeleteByProductID(ctx, model.GetUserFromCtx(ctx), productID, expectedVersion); err != nil {
+			return toHTTPError(ctx, err)
 		}
 
 		logrus.WithContext(ctx).WithFields(logrus.Fields{
@@ -227,27 +255,19 @@ func (s *service) UploadImage() echo.HandlerFunc {
 			return ErrInvalidArgument
 		}
 
-		workDir, _ := os.Getwd()
-		path := filepath.Join(workDir, "", "/assets/products/", file.Filename)
-
-		err = s.productUsecase.UploadImage(ctx, model.GetUserFromCtx(ctx), model.UploadImageProductRequest{
+		resp, err := s.productUsecase.UploadImage(ctx, model.GetUserFromCtx(ctx), model.UploadImageProductRequest{
 			ProductImage: file,
-			Path:         path,
 		})
 		if err != nil {
-			logrus.WithContext(ctx).WithError(err).Error("failed to upload image")
-			return ErrInternal
+			return toHTTPError(ctx, err)
 		}
 
 		logrus.WithContext(ctx).WithFields(logrus.Fields{
-			"file_name": file.Filename,
-			"file_path": path,
+			"file_name": resp.FileName,
+			"image_url": resp.ImageUrl,
 		}).Info("success upload image")
 
-		return c.JSON(http.StatusOK, setSuccessResponse(model.UploadImageProductResponse{
-			FileName: file.Filename,
-			ImageUrl: path,
-		}))
+		return c.JSON(http.StatusOK, setSuccessResponse(resp))
 	}
 }
 
@@ -260,20 +280,12 @@ func (s *service) RemoveImage() echo.HandlerFunc {
 		err := c.Bind(&input)
 		if err != nil {
 			logrus.Error(err)
-			return ErrInternal
+			return ErrInvalidArgument
 		}
 
 		err = s.productUsecase.RemoveImage(ctx, model.GetUserFromCtx(ctx), input)
-		switch err {
-		case nil:
-			break
-		case usecase.ErrNotFound:
-			return ErrNotFound
-		case usecase.ErrPermissionDenied:
-			return ErrPermissionDenied
-		default:
-			logrus.Error(err)
-			return ErrInternal
+		if err != nil {
+			return toHTTPError(ctx, err)
 		}
 
 		return c.JSON(http.StatusOK, setSuccessResponse(input.ImageUrl))
@@ -293,30 +305,210 @@ func (s *service) UploadFile() echo.HandlerFunc {
 		src, err := file.Open()
 		if err != nil {
 			logrus.WithContext(ctx).WithError(err).Error("failed to open product file")
-			return err
+			return ErrInternal
 		}
 		defer src.Close()
 
 		productFile, err := io.ReadAll(src)
 		if err != nil {
 			logrus.WithContext(ctx).WithError(err).Error("failed to open read product file")
-			return err
+			return ErrInternal
 		}
 
-		err = s.productUsecase.UploadFile(ctx, model.GetUserFromCtx(ctx), model.UploadFileProductRequest{
-			ProductFile: productFile,
-		})
+		var columnMapping map[string]string
+		if mapping := c.FormValue("column_mapping"); mapping != "" {
+			if err := json.Unmarshal([]byte(mapping), &columnMapping); err != nil {
+				logrus.WithContext(ctx).WithError(err).Error("failed to parse column mapping")
+				return ErrInvalidArgument
+			}
+		}
+
+		dryRun := false
+		if dryRunStr := c.FormValue("dry_run"); dryRunStr != "" {
+			dryRun, err = strconv.ParseBool(dryRunStr)
+			if err != nil {
+				logrus.WithContext(ctx).WithError(err).Error("failed to parse dry run")
+				return ErrInvalidArgument
+			}
+		}
+
+		atomic := false
+		if atomicStr := c.FormValue("atomic"); atomicStr != "" {
+			atomic, err = strconv.ParseBool(atomicStr)
+			if err != nil {
+				logrus.WithContext(ctx).WithError(err).Error("failed to parse atomic")
+				return ErrInvalidArgument
+			}
+		}
+
+		input := model.UploadFileProductRequest{
+			Filename:      file.Filename,
+			ProductFile:   productFile,
+			ColumnMapping: columnMapping,
+			Mode:          model.ProductImportMode(c.FormValue("mode")),
+			Atomic:        atomic,
+		}
+
+		if dryRun {
+			report, err := s.productUsecase.DryRunUploadFile(ctx, model.GetUserFromCtx(ctx), input)
+			if err != nil {
+				return toHTTPError(ctx, err)
+			}
+
+			return c.JSON(http.StatusOK, setSuccessResponse(report))
+		}
+
+		job, err := s.productUsecase.UploadFile(ctx, model.GetUserFromCtx(ctx), input)
 		if err != nil {
-			logrus.WithContext(ctx).WithError(err).Error("failed to upload file")
-			return ErrInternal
+			return toHTTPError(ctx, err)
 		}
 
 		logrus.WithContext(ctx).WithFields(logrus.Fields{
 			"file_name": file.Filename,
-		}).Info("success upload image")
+			"job_id":    job.ID,
+		}).Info("success queue product file import")
 
-		return c.JSON(http.StatusOK, setSuccessResponse(model.UploadImageProductResponse{
-			FileName: file.Filename,
-		}))
+		return c.JSON(http.StatusAccepted, setSuccessResponse(job))
+	}
+}
+
+// GetImportTemplate returns the expected import header row and the rules of every column,
+// or only the header row as a csv file with format=csv
+func (s *service) GetImportTemplate() echo.HandlerFunc {
+	type templateResponse struct {
+		Header []string                   `json:"header"`
+		Fields []model.ProductImportField `json:"fields"`
+	}
+
+	return func(c echo.Context) error {
+		if c.QueryParam("format") != "csv" {
+			return c.JSON(http.StatusOK, setSuccessResponse(templateResponse{
+				Header: model.ProductImportHeader(),
+				Fields: model.ProductImportFields,
+			}))
+		}
+
+		c.Response().Header().Set(echo.HeaderContentType, "text/csv")
+		c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="products-template.csv"`)
+		c.Response().WriteHeader(http.StatusOK)
+
+		w := csv.NewWriter(c.Response())
+		_ = w.Write(model.ProductImportHeader())
+		w.Flush()
+
+		return w.Error()
+	}
+}
+
+// Export streams the products matching the optional query in the import column layout,
+// as csv by default, as json lines with format=jsonl or as a workbook with format=xlsx
+func (s *service) Export() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+
+		format := model.ProductExportFormat(c.QueryParam("format"))
+		if format == "" {
+			format = model.ProductExportFormatCSV
+		}
+		if !format.IsValid() {
+			return ErrInvalidArgument.WithMessage("format must be csv, jsonl or xlsx")
+		}
+
+		// headers only, the status is sent along with the first product
+		filename := fmt.Sprintf("products-%s.%s", time.Now().Format("20060102150405"), format)
+		c.Response().Header().Set(echo.HeaderContentType, format.ContentType())
+		c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+		w, err := model.NewProductExportWriter(c.Response(), format)
+		if err != nil {
+			return ErrInvalidArgument
+		}
+
+		err = s.productUsecase.Export(ctx, model.GetUserFromCtx(ctx), model.ProductExportCriteria{
+			Query: c.QueryParam("query"),
+		}, w.Write)
+		if err == nil {
+			err = w.Flush()
+		}
+
+		switch {
+		case err == nil:
+			return nil
+		case c.Response().Committed:
+			// too late for an error response, the client gets a truncated file
+			logrus.WithContext(ctx).WithError(err).Error("failed to stream product export")
+			return nil
+		default:
+			return toHTTPError(ctx, err)
+		}
+	}
+}
+
+// GetMerchantFeed renders the published products as a Google Merchant Center feed, xml by default or tsv
+func (s *service) GetMerchantFeed() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+
+		format := model.ProductFeedFormat(c.QueryParam("format"))
+		if format == "" {
+			format = model.ProductFeedFormatXML
+		}
+		if !format.IsValid() {
+			return ErrInvalidArgument.WithMessage("format must be xml or tsv")
+		}
+
+		c.Response().Header().Set(echo.HeaderContentType, format.ContentType())
+
+		err := s.productUsecase.WriteFeed(ctx, c.Response(), format)
+		switch {
+		case err == nil:
+			return nil
+		case c.Response().Committed:
+			logrus.WithContext(ctx).WithError(err).Error("failed to stream merchant feed")
+			return nil
+		default:
+			logrus.WithContext(ctx).WithError(err).Error("failed to render merchant feed")
+			return ErrInternal
+		}
+	}
+}
+
+func (s *service) GetImportJob() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+		jobID := utils.StringToInt64(c.Param("job_id"))
+
+		job, err := s.productUsecase.FindImportJobByID(ctx, model.GetUserFromCtx(ctx), jobID)
+		if err != nil {
+			return toHTTPError(ctx, err)
+		}
+
+		return c.JSON(http.StatusOK, setSuccessResponse(job))
+	}
+}
+
+// GetImportJobErrorReport downloads the rejected rows of an import job as csv
+func (s *service) GetImportJobErrorReport() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+		jobID := utils.StringToInt64(c.Param("job_id"))
+
+		rowErrors, err := s.productUsecase.FindImportJobRowErrors(ctx, model.GetUserFromCtx(ctx), jobID)
+		if err != nil {
+			return toHTTPError(ctx, err)
+		}
+
+		c.Response().Header().Set(echo.HeaderContentType, "text/csv")
+		c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="import-%d-errors.csv"`, jobID))
+		c.Response().WriteHeader(http.StatusOK)
+
+		w := csv.NewWriter(c.Response())
+		_ = w.Write([]string{"row", "column", "reason"})
+		for _, rowErr := range rowErrors {
+			_ = w.Write([]string{strconv.FormatInt(rowErr.RowNumber, 10), rowErr.ColumnName, rowErr.Reason})
+		}
+		w.Flush()
+
+		return w.Error()
 	}
 }
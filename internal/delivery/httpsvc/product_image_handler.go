@@ -0,0 +1,125 @@
+package httpsvc
+
+import (
+	"net/http"
+
+	"github.com/binus-thesis-team/iam-service/utils"
+	"github.com/binus-thesis-team/product-service/internal/model"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// GetImages returns the gallery of the product by position
+func (s *service) GetImages() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+		productID := utils.StringToInt64(c.Param("product_id"))
+
+		images, err := s.productUsecase.FindImagesByProductID(ctx, productID)
+		if err != nil {
+			return toHTTPError(ctx, err)
+		}
+
+		return c.JSON(http.StatusOK, setSuccessResponse(images))
+	}
+}
+
+// AttachImage appends an uploaded image to the gallery, the response is the product with its gallery
+func (s *service) AttachImage() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+
+		input := model.AttachProductImageRequest{}
+		if err := c.Bind(&input); err != nil {
+			logrus.WithContext(ctx).Error(err)
+			return ErrInvalidArgument
+		}
+		input.ProductID = utils.StringToInt64(c.Param("product_id"))
+
+		var err error
+		input.ExpectedVersion, err = expectedVersionFromIfMatch(c)
+		if err != nil {
+			return err
+		}
+
+		product, err := s.productUsecase.AttachImage(ctx, model.GetUserFromCtx(ctx), input)
+		if err != nil {
+			return toHTTPError(ctx, err)
+		}
+
+		setProductETag(c, product)
+		return c.JSON(http.StatusCreated, setSuccessResponse(product))
+	}
+}
+
+// ReorderImages orders the gallery as the image_ids of the body, every image of the gallery must be listed
+func (s *service) ReorderImages() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+
+		input := model.ReorderProductImagesRequest{}
+		if err := c.Bind(&input); err != nil {
+			logrus.WithContext(ctx).Error(err)
+			return ErrInvalidArgument
+		}
+		input.ProductID = utils.StringToInt64(c.Param("product_id"))
+
+		var err error
+		input.ExpectedVersion, err = expectedVersionFromIfMatch(c)
+		if err != nil {
+			return err
+		}
+
+		product, err := s.productUsecase.ReorderImages(ctx, model.GetUserFromCtx(ctx), input)
+		if err != nil {
+			return toHTTPError(ctx, err)
+		}
+
+		setProductETag(c, product)
+		return c.JSON(http.StatusOK, setSuccessResponse(product))
+	}
+}
+
+// SetPrimaryImage makes the image the primary one, i.e. the image_url of the product
+func (s *service) SetPrimaryImage() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+		productID := utils.StringToInt64(c.Param("product_id"))
+		imageID := utils.StringToInt64(c.Param("image_id"))
+
+		expectedVersion, err := expectedVersionFromIfMatch(c)
+		if err != nil {
+			return err
+		}
+
+		product, err := s.productUsecase.SetPrimaryImage(ctx, model.GetUserFromCtx(ctx), productID, imageID, expectedVersion)
+		if err != nil {
+			return toHTTPError(ctx, err)
+		}
+
+		setProductETag(c, product)
+		return c.JSON(http.StatusOK, setSuccessResponse(product))
+	}
+}
+
+// DetachImage removes the image from the gallery, the stored image is kept
+func (s *service) DetachImage() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+		productID := utils.StringToInt64(c.Param("product_id"))
+		imageID := utils.StringToInt64(c.Param("image_id"))
+
+		expectedVersion, err := expectedVersionFromIfMatch(c)
+		if err != nil {
+			return err
+		}
+
+		product, err := s.productUsecase.DetachImage(ctx, model.GetUserFromCtx(ctx), productID, imageID, expectedVersion)
+		if err != nil {
+			return toHTTPError(ctx, err)
+		}
+
+		setProductETag(c, product)
+		return c.JSON(http.StatusOK, setSuccessResponse(product))
+	}
+}
@@ -1,48 +1,104 @@
 package httpsvc
 
 import (
+	"fmt"
+
 	"github.com/binus-thesis-team/iam-service/auth"
+	"github.com/binus-thesis-team/product-service/internal/config"
 	"github.com/binus-thesis-team/product-service/internal/model"
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
 )
 
+// imageUploadFormOverhead is the size of an image upload form besides the image
+const imageUploadFormOverhead = 64 << 10
+
 // service http service
 type service struct {
-	productUsecase model.ProductUsecase
-	authMiddleware *auth.AuthenticationMiddleware
+	productUsecase      model.ProductUsecase
+	supplierFeedUsecase model.SupplierFeedUsecase
+	authMiddleware      *auth.AuthenticationMiddleware
+	// idempotencyRepository is nil when the idempotency keys are disabled
+	idempotencyRepository model.IdempotencyRepository
 }
 
 // RouteService ..
 func RouteService(
 	group *echo.Group,
 	productUsecase model.ProductUsecase,
+	supplierFeedUsecase model.SupplierFeedUsecase,
 	authMiddleware *auth.AuthenticationMiddleware,
+	idempotencyRepository model.IdempotencyRepository,
 ) {
 	svc := &service{
-		productUsecase: productUsecase,
-		authMiddleware: authMiddleware,
+		productUsecase:        productUsecase,
+		supplierFeedUsecase:   supplierFeedUsecase,
+		authMiddleware:        authMiddleware,
+		idempotencyRepository: idempotencyRepository,
 	}
 
 	svc.initInternalCommunicationRoutes(group.Group("/internal"))
 	svc.initRoutes(group)
 }
 
+// RouteMedia routes the images of the image store, without an access token since the shop pages embed them
+func RouteMedia(group *echo.Group, productUsecase model.ProductUsecase) {
+	svc := &service{productUsecase: productUsecase}
+
+	group.GET("/*", svc.GetMedia())
+	group.HEAD("/*", svc.GetMedia())
+}
+
 func (s *service) initRoutes(group *echo.Group) {
+	// public, the shopping channels fetch the feed without an access token
+	group.GET("/products/merchant-feed/", s.GetMerchantFeed())
+
 	productRoute := group.Group("/products", s.authMiddleware.MustAuthenticateAccessToken())
 	{
-		productRoute.POST("/", s.Create())
+		productRoute.POST("/", s.Create(), s.idempotent())
 		productRoute.GET("/:product_id/", s.GetDetail())
 		productRoute.GET("/", s.GetList())
-		productRoute.PUT("/:product_id/", s.Update())
+		productRoute.GET("/export/", s.Export())
+		productRoute.POST("/bulk/", s.BulkMutate(), s.idempotent())
+		productRoute.PUT("/:product_id/", s.Update(), s.idempotent())
+		productRoute.PATCH("/:product_id/", s.Patch(), s.idempotent())
 		productRoute.DELETE("/:product_id/", s.Delete())
 
+		productImageRoute := productRoute.Group("/:product_id/images")
+		{
+			productImageRoute.GET("/", s.GetImages())
+			productImageRoute.POST("/", s.AttachImage())
+			productImageRoute.PUT("/order/", s.ReorderImages())
+			productImageRoute.PUT("/:image_id/primary/", s.SetPrimaryImage())
+			productImageRoute.DELETE("/:image_id/", s.DetachImage())
+		}
+
 		imageGroup := productRoute.Group("/images")
 		{
-			imageGroup.POST("/upload/", s.UploadImage())
+			// the image size is checked again once read, the limit also leaves room for the rest of the form
+			imageGroup.POST("/upload/", s.UploadImage(), middleware.BodyLimit(fmt.Sprintf("%dB", config.ImageUploadMaxSizeBytes()+imageUploadFormOverhead)))
 			imageGroup.DELETE("/remove/", s.RemoveImage())
+			imageGroup.GET("/signed-url/", s.SignImageURL())
 		}
 
-		productRoute.POST("/file/upload/", s.UploadFile())
+		fileGroup := productRoute.Group("/file")
+		{
+			fileGroup.POST("/upload/", s.UploadFile(), s.idempotent())
+			fileGroup.GET("/template/", s.GetImportTemplate())
+			fileGroup.GET("/jobs/:job_id/", s.GetImportJob())
+			fileGroup.GET("/jobs/:job_id/errors/", s.GetImportJobErrorReport())
+		}
+
+		feedGroup := productRoute.Group("/feeds")
+		{
+			feedGroup.POST("/", s.CreateSupplierFeed())
+			feedGroup.GET("/", s.GetSupplierFeeds())
+			feedGroup.GET("/:feed_id/", s.GetSupplierFeed())
+			feedGroup.PUT("/:feed_id/", s.UpdateSupplierFeed())
+			feedGroup.DELETE("/:feed_id/", s.DeleteSupplierFeed())
+			feedGroup.GET("/:feed_id/runs/", s.GetSupplierFeedRuns())
+			feedGroup.POST("/:feed_id/runs/", s.TriggerSupplierFeed())
+		}
 	}
 }
 
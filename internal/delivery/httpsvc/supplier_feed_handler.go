@@ -0,0 +1,118 @@
+package httpsvc
+
+import (
+	"net/http"
+
+	"github.com/binus-thesis-team/iam-service/utils"
+	"github.com/binus-thesis-team/product-service/internal/model"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+func (s *service) CreateSupplierFeed() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+
+		req := model.CreateSupplierFeedRequest{}
+		if err := c.Bind(&req); err != nil {
+			logrus.Error(err)
+			return ErrInvalidArgument
+		}
+
+		feed, err := s.supplierFeedUsecase.Create(ctx, model.GetUserFromCtx(ctx), req)
+		if err != nil {
+			return toHTTPError(ctx, err)
+		}
+
+		return c.JSON(http.StatusCreated, setSuccessResponse(feed))
+	}
+}
+
+func (s *service) GetSupplierFeeds() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+
+		feeds, err := s.supplierFeedUsecase.FindAll(ctx, model.GetUserFromCtx(ctx))
+		if err != nil {
+			return toHTTPError(ctx, err)
+		}
+
+		return c.JSON(http.StatusOK, setSuccessResponse(feeds))
+	}
+}
+
+func (s *service) GetSupplierFeed() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+		feedID := utils.StringToInt64(c.Param("feed_id"))
+
+		feed, err := s.supplierFeedUsecase.FindByID(ctx, model.GetUserFromCtx(ctx), feedID)
+		if err != nil {
+			return toHTTPError(ctx, err)
+		}
+
+		return c.JSON(http.StatusOK, setSuccessResponse(feed))
+	}
+}
+
+func (s *service) UpdateSupplierFeed() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+
+		req := model.UpdateSupplierFeedRequest{}
+		if err := c.Bind(&req); err != nil {
+			logrus.Error(err)
+			return ErrInvalidArgument
+		}
+		req.ID = utils.StringToInt64(c.Param("feed_id"))
+
+		feed, err := s.supplierFeedUsecase.Update(ctx, model.GetUserFromCtx(ctx), req)
+		if err != nil {
+			return toHTTPError(ctx, err)
+		}
+
+		return c.JSON(http.StatusOK, setSuccessResponse(feed))
+	}
+}
+
+func (s *service) DeleteSupplierFeed() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+		feedID := utils.StringToInt64(c.Param("feed_id"))
+
+		if err := s.supplierFeedUsecase.DeleteByID(ctx, model.GetUserFromCtx(ctx), feedID); err != nil {
+			return toHTTPError(ctx, err)
+		}
+
+		return c.JSON(http.StatusOK, setSuccessResponse(feedID))
+	}
+}
+
+// TriggerSupplierFeed queues a run of the feed, it's picked up by the next poll of the scheduler
+func (s *service) TriggerSupplierFeed() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+		feedID := utils.StringToInt64(c.Param("feed_id"))
+
+		feed, err := s.supplierFeedUsecase.TriggerByID(ctx, model.GetUserFromCtx(ctx), feedID)
+		if err != nil {
+			return toHTTPError(ctx, err)
+		}
+
+		return c.JSON(http.StatusAccepted, setSuccessResponse(feed))
+	}
+}
+
+func (s *service) GetSupplierFeedRuns() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+		feedID := utils.StringToInt64(c.Param("feed_id"))
+
+		runs, err := s.supplierFeedUsecase.FindRunsByFeedID(ctx, model.GetUserFromCtx(ctx), feedID)
+		if err != nil {
+			return toHTTPError(ctx, err)
+		}
+
+		return c.JSON(http.StatusOK, setSuccessResponse(runs))
+	}
+}
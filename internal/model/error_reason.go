@@ -0,0 +1,32 @@
+package model
+
+// ErrorDomain is the domain of the ErrorInfo details sent by the grpc service
+const ErrorDomain = "product-service"
+
+// ErrorReason is the stable reason of an error sent to the callers, e.g. as the ErrorInfo of a grpc status.
+// The values must never change, the callers branch on them.
+type ErrorReason string
+
+const (
+	ErrorReasonNotFound               ErrorReason = "NOT_FOUND"
+	ErrorReasonDuplicateProduct       ErrorReason = "DUPLICATE_PRODUCT"
+	ErrorReasonPermissionDenied       ErrorReason = "PERMISSION_DENIED"
+	ErrorReasonValidationFailed       ErrorReason = "VALIDATION_FAILED"
+	ErrorReasonVersionConflict        ErrorReason = "VERSION_CONFLICT"
+	ErrorReasonInvalidImportFile      ErrorReason = "INVALID_IMPORT_FILE"
+	ErrorReasonInvalidImportMode      ErrorReason = "INVALID_IMPORT_MODE"
+	ErrorReasonImportQueueFull        ErrorReason = "IMPORT_QUEUE_FULL"
+	ErrorReasonInvalidArgument        ErrorReason = "INVALID_ARGUMENT"
+	ErrorReasonUnauthenticated        ErrorReason = "UNAUTHENTICATED"
+	ErrorReasonMethodNotAllowed       ErrorReason = "METHOD_NOT_ALLOWED"
+	ErrorReasonRequestTooLarge        ErrorReason = "REQUEST_TOO_LARGE"
+	ErrorReasonUnsupportedMediaType   ErrorReason = "UNSUPPORTED_MEDIA_TYPE"
+	ErrorReasonIdempotencyKeyReused   ErrorReason = "IDEMPOTENCY_KEY_REUSED"
+	ErrorReasonIdempotencyKeyInFlight ErrorReason = "IDEMPOTENCY_KEY_IN_FLIGHT"
+	ErrorReasonSupplierFeedDisabled   ErrorReason = "SUPPLIER_FEED_DISABLED"
+	ErrorReasonImageInUse             ErrorReason = "IMAGE_IN_USE"
+	ErrorReasonInvalidSignature       ErrorReason = "INVALID_SIGNATURE"
+	ErrorReasonTimeout                ErrorReason = "TIMEOUT"
+	ErrorReasonCanceled               ErrorReason = "CANCELED"
+	ErrorReasonInternal               ErrorReason = "INTERNAL"
+)
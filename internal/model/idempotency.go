@@ -0,0 +1,36 @@
+package model
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+// IdempotencyRepository keeps the responses of the requests sent with an idempotency key,
+// so a retry of the request replays the response instead of running again
+type IdempotencyRepository interface {
+	// Begin locks the key for the request with the fingerprint, it returns the record of the key
+	// when it's already taken, either in flight or completed, and nil when the lock is acquired
+	Begin(ctx context.Context, key, fingerprint string) (record *IdempotencyRecord, err error)
+	// Complete stores the response of the key for the idempotency window
+	Complete(ctx context.Context, key string, record *IdempotencyRecord) error
+	// Release unlocks the key of a failed request, so it can be retried
+	Release(ctx context.Context, key string) error
+}
+
+// IdempotencyRecord is the state of an idempotency key
+type IdempotencyRecord struct {
+	Fingerprint string `json:"fingerprint"`
+	// Completed is false while the first request of the key is in flight
+	Completed   bool   `json:"completed"`
+	StatusCode  int    `json:"status_code,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// NewIdempotencyKey scopes the key sent by the client to its user and to the operation, e.g. the route
+func NewIdempotencyKey(userID int64, operation, key string) string {
+	sum := sha256.Sum256([]byte(operation))
+	return "idempotency:" + strconv.FormatInt(userID, 10) + ":" + hex.EncodeToString(sum[:8]) + ":" + key
+}
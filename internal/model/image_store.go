@@ -0,0 +1,39 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+var (
+	// ErrImageNotFound is a key with no image stored
+	ErrImageNotFound = errors.New("image not found")
+	// ErrInvalidImageKey is a key reaching out of the store, e.g. ../config.yml
+	ErrInvalidImageKey = errors.New("invalid image key")
+)
+
+// ImageStore keeps the product images by object key, e.g. products/shoe.png, the key is a slash
+// separated path relative to the store. Every replica sees the same images through it.
+type ImageStore interface {
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	// Get returns the content of the image, ErrImageNotFound when nothing is stored at the key
+	Get(ctx context.Context, key string) (*ImageObject, error)
+	// Delete returns ErrImageNotFound when nothing is stored at the key
+	Delete(ctx context.Context, key string) error
+	// URL returns the public url of the key
+	URL(key string) string
+	// Key returns the key of a url returned by URL, false for any other url
+	Key(url string) (key string, ok bool)
+}
+
+// ImageObject is the content of a stored image, seekable for the range requests
+type ImageObject struct {
+	io.ReadSeekCloser
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+	// ETag changes whenever the content does
+	ETag string
+}
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// OpenMediaRequest is a request of an image served under /media, Expires and Signature are the query of a
+// signed url, empty for a public one
+type OpenMediaRequest struct {
+	Key       string
+	Expires   int64
+	Signature string
+}
+
+// Media is an image opened for /media, ExpiresAt is the expiry of its signed url, zero when it's public
+type Media struct {
+	*ImageObject
+	ExpiresAt time.Time
+}
+
+// SignedImageURL is the signed url of an uploaded image and of its derivatives by size. ExpiresAt is nil and
+// the urls aren't signed when the signed urls are disabled.
+type SignedImageURL struct {
+	ImageUrl  string            `json:"image_url"`
+	Images    map[string]string `json:"images,omitempty"`
+	ExpiresAt *time.Time        `json:"expires_at,omitempty"`
+}
@@ -2,11 +2,15 @@ package model
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"mime/multipart"
 	"time"
 
 	pb "github.com/binus-thesis-team/product-service/pb/product_service"
+	"github.com/dustin/go-humanize"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"gorm.io/gorm"
@@ -17,46 +21,165 @@ type ProductUsecase interface {
 	FindByID(ctx context.Context, id int64) (product *Product, err error)
 	FindByProductIDs(ctx context.Context, productIDs []int64) (product []*Product, err error)
 	Update(ctx context.Context, user SessionUser, input UpdateProductRequest) (product *Product, err error)
-	DeleteByProductID(ctx context.Context, user SessionUser, productID int64) (err error)
+	Patch(ctx context.Context, user SessionUser, input PatchProductRequest) (product *Product, err error)
+	// DeleteByProductID and RestoreByProductID fail with a version conflict unless expectedVersion is 0 or the current version
+	DeleteByProductID(ctx context.Context, user SessionUser, productID, expectedVersion int64) (err error)
+	RestoreByProductID(ctx context.Context, user SessionUser, productID, expectedVersion int64) (product *Product, err error)
+	// BulkMutate applies the operations, the result of each one is at its index
+	BulkMutate(ctx context.Context, user SessionUser, input BulkMutateProductsRequest) (results []*ProductBulkResult, err error)
 	SearchByPage(ctx context.Context, searchCriteria ProductSearchCriteria) (ids []int64, count int64, err error)
 	SearchByCriteria(ctx context.Context, user SessionUser, searchCriteria ProductSearchCriteria) (products []*Product, count int64, err error)
 	FindIDsByQuery(ctx context.Context, query string) (ids []int64, count int64, err error)
+	// FindAllByIDs reads the products for the other methods, the reads aren't counted as requests
 	FindAllByIDs(ctx context.Context, ids []int64) (products []*Product)
-	UploadImage(ctx context.Context, user SessionUser, input UploadImageProductRequest) error
+	UploadImage(ctx context.Context, user SessionUser, input UploadImageProductRequest) (*UploadImageProductResponse, error)
 	RemoveImage(ctx context.Context, user SessionUser, input RemoveImageProductRequest) error
-	UploadFile(ctx context.Context, user SessionUser, input UploadFileProductRequest) error
-	UploadFileWithoutSession(ctx context.Context, input UploadFileProductRequest) error
+	// FindImagesByProductID reads the gallery of the product by position
+	FindImagesByProductID(ctx context.Context, productID int64) (images []*ProductImage, err error)
+	// AttachImage, ReorderImages, SetPrimaryImage and DetachImage change the gallery of the product, its ImageUrl
+	// follows the primary image. They return the product with its gallery.
+	AttachImage(ctx context.Context, user SessionUser, input AttachProductImageRequest) (product *Product, err error)
+	ReorderImages(ctx context.Context, user SessionUser, input ReorderProductImagesRequest) (product *Product, err error)
+	SetPrimaryImage(ctx context.Context, user SessionUser, productID, imageID, expectedVersion int64) (product *Product, err error)
+	DetachImage(ctx context.Context, user SessionUser, productID, imageID, expectedVersion int64) (product *Product, err error)
+	// OpenMedia opens a stored image for /media, the caller closes it
+	OpenMedia(ctx context.Context, input OpenMediaRequest) (media *Media, err error)
+	// SignImageURL returns the urls of an uploaded image signed for the images of no published product
+	SignImageURL(ctx context.Context, user SessionUser, imageURL string) (signed *SignedImageURL, err error)
+	// RegenerateImageDerivatives generates again the derivatives of every uploaded image of the products
+	RegenerateImageDerivatives(ctx context.Context) (report *ImageDerivativesReport, err error)
+	UploadFile(ctx context.Context, user SessionUser, input UploadFileProductRequest) (job *ProductImportJob, err error)
+	UploadFileWithoutSession(ctx context.Context, input UploadFileProductRequest) (job *ProductImportJob, err error)
+	UploadStreamWithoutSession(ctx context.Context, input UploadStreamProductRequest) (report *ProductImportReport, err error)
+	DryRunUploadFile(ctx context.Context, user SessionUser, input UploadFileProductRequest) (report *ProductImportReport, err error)
+	DryRunUploadFileWithoutSession(ctx context.Context, input UploadFileProductRequest) (report *ProductImportReport, err error)
+	Export(ctx context.Context, user SessionUser, criteria ProductExportCriteria, fn func(product *Product) error) (err error)
+	ExportWithoutSession(ctx context.Context, criteria ProductExportCriteria, fn func(product *Product) error) (err error)
+	FindImportJobByID(ctx context.Context, user SessionUser, id int64) (job *ProductImportJob, err error)
+	// FailStaleImportJobs fails the import jobs left queued or running by a process that is gone
+	FailStaleImportJobs(ctx context.Context) (count int64, err error)
+	// DrainImportJobs fails the queued import jobs and waits for the running ones until ctx is done
+	DrainImportJobs(ctx context.Context) (err error)
+	FindImportJobRowErrors(ctx context.Context, user SessionUser, id int64) (rowErrors []*ProductImportRowError, err error)
+	// WriteFeed renders the merchant feed of the published products, regenerated from the products changed since the last call
+	WriteFeed(ctx context.Context, w io.Writer, format ProductFeedFormat) (err error)
+	// GenerateFeed renders the merchant feed regenerated from the previous snapshot, a nil one reads the whole catalog
+	GenerateFeed(ctx context.Context, previous *ProductFeedSnapshot, w io.Writer, format ProductFeedFormat) (snapshot *ProductFeedSnapshot, err error)
 }
 
+// ProductRequestCounter tallies the reads of the products requested by the clients,
+// the most requested ones are warmed in the cache
+type ProductRequestCounter interface {
+	Incr(id int64)
+}
+
+// ProductRepository :nodoc:
+// Every write setting the ImageUrl of a product makes it the primary image of its gallery in the same transaction.
 type ProductRepository interface {
 	Create(ctx context.Context, requesterID int64, product *Product) error
+	CreateMany(ctx context.Context, requesterID int64, products []*Product) error
+	Transaction(ctx context.Context, fn func(repo ProductRepository) error) error
 	FindByID(ctx context.Context, id int64) (*Product, error)
-	UpdateByID(ctx context.Context, requesterID int64, product *Product) (err error)
-	DeleteByID(ctx context.Context, id int64) error
+	// UpdateByID, PatchByID, DeleteByID and RestoreByID bump the version, they return ErrVersionConflict
+	// when expectedVersion isn't 0 and doesn't match the stored version
+	UpdateByID(ctx context.Context, requesterID int64, product *Product, expectedVersion int64) (err error)
+	// PatchByID updates the given columns only, their zero values included
+	PatchByID(ctx context.Context, requesterID, id int64, columns map[string]any, expectedVersion int64) (err error)
+	DeleteByID(ctx context.Context, id, expectedVersion int64) error
+	RestoreByID(ctx context.Context, id, expectedVersion int64) error
+	// FindAllByIDs reads the products from the database, soft deleted ones included
+	FindAllByIDs(ctx context.Context, ids []int64) (products []*Product, err error)
+	// BulkMutate applies the operations in a single statement, it returns the ids of the mutated products,
+	// an operation whose expected version or deletion state doesn't match is left out
+	BulkMutate(ctx context.Context, requesterID int64, operations []ProductBulkOperation) (mutatedIDs []int64, err error)
 	SearchByPage(ctx context.Context, searchCriteria ProductSearchCriteria) (ids []int64, count int64, err error)
 	FindAllByQuery(ctx context.Context, query string, size, cursorAfter int64) (ids []int64, err error)
+	FindAllProductsByQuery(ctx context.Context, query string, size, cursorAfter int64) (products []*Product, err error)
+	FindIDsByLastUpdated(ctx context.Context, limit int64) (ids []int64, err error)
+	// FindAllProductsChangedSince reads the products updated or deleted since the given time, soft deleted ones included
+	FindAllProductsChangedSince(ctx context.Context, since time.Time, size, cursorAfter int64) (products []*Product, err error)
+	FindAllByExternalIDs(ctx context.Context, externalIDs []string) (products []*Product, err error)
+	FindAllImageURLs(ctx context.Context, size int64, cursorAfter string) (imageURLs []string, err error)
+	// IsAnyImagePublished tells whether any of the urls is the image of a product that isn't soft deleted
+	// or is in its gallery
+	IsAnyImagePublished(ctx context.Context, imageURLs []string) (published bool, err error)
+	// IsAnyImageReferenced tells whether any of the urls is the image of a product or is in its gallery,
+	// the soft deleted products included
+	IsAnyImageReferenced(ctx context.Context, imageURLs []string) (referenced bool, err error)
+	// LockByID reads the product and locks its row until the end of the transaction, it must run in a Transaction
+	LockByID(ctx context.Context, id int64) (product *Product, err error)
+	FindAllImagesByProductID(ctx context.Context, productID int64) (images []*ProductImage, err error)
+	CreateImage(ctx context.Context, image *ProductImage) error
+	// UpdateImages saves the position and the primary flag of the images of the product
+	UpdateImages(ctx context.Context, productID int64, images []*ProductImage) error
+	DeleteImage(ctx context.Context, productID, imageID int64) error
+	// UpsertByExternalIDs and UpdateByExternalIDs leave the soft deleted products as is, the products without
+	// a result are the deleted ones, and for UpdateByExternalIDs the missing ones
+	UpsertByExternalIDs(ctx context.Context, requesterID int64, products []*Product) (results []ProductUpsertResult, err error)
+	UpdateByExternalIDs(ctx context.Context, requesterID int64, products []*Product) (results []ProductUpsertResult, err error)
 }
 
+// ErrVersionConflict is a conditional write of a product whose version has changed
+var ErrVersionConflict = errors.New("version conflict")
+
+// Product :nodoc:, its Version goes up on every write and is used as its ETag.
+// Its Images are the urls of the derivatives of an uploaded ImageUrl by their size, they're set on read.
+// Its Gallery is only read by the gallery operations.
 type Product struct {
-	ID          int64          `json:"id,omitempty" gorm:"<-:create; primary_key;AUTO_INCREMENT"`
-	Name        string         `json:"name,omitempty"`
-	Price       float64        `json:"price,omitempty"`
-	Stock       int64          `json:"stock,omitempty"`
-	Description string         `json:"description,omitempty"`
-	ImageUrl    string         `json:"image_url,omitempty"`
-	CreatedAt   *time.Time     `json:"created_at,omitempty" gorm:"->;<-:create"`
-	UpdatedAt   *time.Time     `json:"updated_at,omitempty"`
-	DeletedAt   gorm.DeletedAt `json:"deleted_at,omitempty"`
+	ID          int64             `json:"id,omitempty" gorm:"<-:create; primary_key;AUTO_INCREMENT"`
+	ExternalID  *string           `json:"external_id,omitempty"`
+	Name        string            `json:"name,omitempty"`
+	Price       float64           `json:"price,omitempty"`
+	Stock       int64             `json:"stock,omitempty"`
+	Description string            `json:"description,omitempty"`
+	ImageUrl    string            `json:"image_url,omitempty"`
+	Version     int64             `json:"version,omitempty" gorm:"default:1"`
+	Images      map[string]string `json:"images,omitempty" gorm:"-"`
+	Gallery     []*ProductImage   `json:"gallery,omitempty" gorm:"-"`
+	CreatedAt   *time.Time        `json:"created_at,omitempty" gorm:"->;<-:create"`
+	UpdatedAt   *time.Time        `json:"updated_at,omitempty"`
+	DeletedAt   gorm.DeletedAt    `json:"deleted_at,omitempty"`
+}
+
+// GetExternalID returns the external id, empty when the product has none
+func (p *Product) GetExternalID() string {
+	if p.ExternalID == nil {
+		return ""
+	}
+	return *p.ExternalID
+}
+
+// HasVersion returns true when the expected version is 0, i.e. unconditional, or the current version
+func (p *Product) HasVersion(expectedVersion int64) bool {
+	return expectedVersion == 0 || p.Version == expectedVersion
+}
+
+// NewExternalID returns nil for an empty external id, so it's stored as NULL
+func NewExternalID(externalID string) *string {
+	if externalID == "" {
+		return nil
+	}
+	return &externalID
+}
+
+// ProductUpsertResult is a product saved by its external id
+type ProductUpsertResult struct {
+	ID         int64
+	ExternalID string
+	Created    bool
 }
 
 func (p *Product) ToProto() *pb.Product {
 	product := &pb.Product{
 		Id:          p.ID,
+		ExternalId:  p.GetExternalID(),
 		Name:        p.Name,
 		Price:       p.Price,
 		Stock:       p.Stock,
 		Description: p.Description,
 		ImageUrl:    p.ImageUrl,
+		Version:     p.Version,
+		Images:      p.Images,
 	}
 
 	if product.CreatedAt.IsValid() {
@@ -79,11 +202,14 @@ func NewProductFromProto(p *pb.Product) *Product {
 
 	product := &Product{
 		ID:          p.GetId(),
+		ExternalID:  NewExternalID(p.GetExternalId()),
 		Name:        p.GetName(),
 		Price:       p.GetPrice(),
 		Stock:       p.GetStock(),
 		Description: p.GetDescription(),
 		ImageUrl:    p.GetImageUrl(),
+		Version:     p.GetVersion(),
+		Images:      p.GetImages(),
 	}
 
 	createdAt := p.GetCreatedAt().AsTime()
@@ -99,6 +225,7 @@ func NewProductFromProto(p *pb.Product) *Product {
 }
 
 type CreateProductRequest struct {
+	ExternalID  string  `json:"external_id,omitempty"`
 	Name        string  `json:"name,omitempty" binding:"required"`
 	Price       float64 `json:"price,omitempty" binding:"required"`
 	Stock       int64   `json:"stock,omitempty" binding:"required"`
@@ -110,25 +237,56 @@ func (c *CreateProductRequest) Validate() error {
 	return validate.Struct(c)
 }
 
+// ToProduct :nodoc:
+func (c *CreateProductRequest) ToProduct() *Product {
+	return &Product{
+		ExternalID:  NewExternalID(c.ExternalID),
+		Name:        c.Name,
+		Price:       c.Price,
+		Stock:       c.Stock,
+		Description: c.Description,
+		ImageUrl:    c.ImageUrl,
+	}
+}
+
 func (c *CreateProductRequest) ValidateDTOCreateProductRequest() error {
 	if c.Name == "" {
-		return errors.New("Name is required")
+		return newFieldError("name", "Name is required")
 	}
 
 	if c.Price <= 0 {
-		return errors.New("Price must be greater than 0")
+		return newFieldError("price", "Price must be greater than 0")
 	}
 
 	if c.Stock <= 0 {
-		return errors.New("Stock must be greater than 0")
+		return newFieldError("stock", "Stock must be greater than 0")
 	}
 
 	if c.Description == "" {
-		return errors.New("Description is required")
+		return newFieldError("description", "Description is required")
 	}
 
 	if c.ImageUrl == "" {
-		return errors.New("Image URL is required")
+		return newFieldError("image_url", "Image URL is required")
+	}
+
+	return nil
+}
+
+// ValidateDTOImportProductRequest validates an imported row updating an existing product. The rules are
+// looser than the create ones so every exported product can be imported back over itself: the stock can
+// be sold out, and the description and image can be empty like a product whose gallery has been emptied.
+func (c *CreateProductRequest) ValidateDTOImportProductRequest() error {
+	if c.Name == "" {
+		return newFieldError("name", "Name is required")
+	}
+
+	if c.Price <= 0 {
+		return newFieldError("price", "Price must be greater than 0")
+	}
+
+	if c.Stock < 0 {
+		return newFieldError("stock", "Stock can't be negative")
 	}
 
 	return nil
@@ -141,6 +299,8 @@ type UpdateProductRequest struct {
 	Stock       int64   `json:"stock,omitempty" binding:"required"`
 	Description string  `json:"description,omitempty" binding:"required"`
 	ImageUrl    string  `json:"image_url,omitempty" binding:"required"`
+	// ExpectedVersion is the version the update is based on, 0 updates unconditionally
+	ExpectedVersion int64 `json:"-"`
 }
 
 func (c *UpdateProductRequest) Validate() error {
@@ -149,32 +309,155 @@ func (c *UpdateProductRequest) Validate() error {
 
 func (c *UpdateProductRequest) ValidateDTOUpdateProductRequest() error {
 	if c.ID <= 0 {
-		return errors.New("ID is required")
+		return newFieldError("id", "ID is required")
 	}
 
 	if c.Name == "" {
-		return errors.New("Name is required")
+		return newFieldError("name", "Name is required")
 	}
 
 	if c.Price <= 0 {
-		return errors.New("Price must be greater than 0")
+		return newFieldError("price", "Price must be greater than 0")
 	}
 
 	if c.Stock <= 0 {
-		return errors.New("Stock must be greater than 0")
+		return newFieldError("stock", "Stock must be greater than 0")
 	}
 
 	if c.Description == "" {
-		return errors.New("Description is required")
+		return newFieldError("description", "Description is required")
 	}
 
 	if c.ImageUrl == "" {
-		return errors.New("Image URL is required")
+		return newFieldError("image_url", "Image URL is required")
+	}
+
+	return nil
+}
+
+// PatchProductRequest updates only the set fields, unlike UpdateProductRequest a field can be set to its zero value
+// e.g. a stock of 0. An empty ExternalID clears the external id.
+type PatchProductRequest struct {
+	ID          int64
+	ExternalID  *string
+	Name        *string
+	Price       *float64
+	Stock       *int64
+	Description *string
+	ImageUrl    *string
+	// ExpectedVersion is the version the patch is based on, 0 patches unconditionally
+	ExpectedVersion int64
+}
+
+// NewPatchProductRequestFromMergePatch parses a JSON merge patch (RFC 7396) of the product,
+// a null member clears the external id and is rejected for the required fields
+func NewPatchProductRequestFromMergePatch(id int64, patch []byte) (PatchProductRequest, error) {
+	req := PatchProductRequest{ID: id}
+
+	members := map[string]json.RawMessage{}
+	if err := json.Unmarshal(patch, &members); err != nil {
+		return req, newFieldError("body", "Body must be a JSON object")
+	}
+
+	for field, raw := range members {
+		isNull := string(raw) == "null"
+		if isNull && field != "external_id" {
+			return req, newFieldError(field, "Field can't be null")
+		}
+
+		var target any
+		switch field {
+		case "external_id":
+			req.ExternalID = new(string)
+			if isNull {
+				continue
+			}
+			target = req.ExternalID
+		case "name":
+			req.Name = new(string)
+			target = req.Name
+		case "price":
+			req.Price = new(float64)
+			target = req.Price
+		case "stock":
+			req.Stock = new(int64)
+			target = req.Stock
+		case "description":
+			req.Description = new(string)
+			target = req.Description
+		case "image_url":
+			req.ImageUrl = new(string)
+			target = req.ImageUrl
+		default:
+			return req, newFieldError(field, "Unknown field")
+		}
+
+		if err := json.Unmarshal(raw, target); err != nil {
+			return req, newFieldError(field, "Invalid value")
+		}
+	}
+
+	return req, nil
+}
+
+// IsEmpty returns true when the patch changes nothing
+func (c *PatchProductRequest) IsEmpty() bool {
+	return len(c.Columns()) == 0
+}
+
+// ValidateDTOPatchProductRequest validates only the fields being changed
+func (c *PatchProductRequest) ValidateDTOPatchProductRequest() error {
+	if c.ID <= 0 {
+		return newFieldError("id", "ID is required")
+	}
+
+	if c.Name != nil && *c.Name == "" {
+		return newFieldError("name", "Name is required")
+	}
+
+	if c.Price != nil && *c.Price <= 0 {
+		return newFieldError("price", "Price must be greater than 0")
+	}
+
+	if c.Stock != nil && *c.Stock < 0 {
+		return newFieldError("stock", "Stock can't be negative")
+	}
+
+	if c.Description != nil && *c.Description == "" {
+		return newFieldError("description", "Description is required")
+	}
+
+	if c.ImageUrl != nil && *c.ImageUrl == "" {
+		return newFieldError("image_url", "Image URL is required")
 	}
 
 	return nil
 }
 
+// Columns returns the changed columns, zero values included
+func (c *PatchProductRequest) Columns() map[string]any {
+	columns := map[string]any{}
+	if c.ExternalID != nil {
+		columns["external_id"] = NewExternalID(*c.ExternalID)
+	}
+	if c.Name != nil {
+		columns["name"] = *c.Name
+	}
+	if c.Price != nil {
+		columns["price"] = *c.Price
+	}
+	if c.Stock != nil {
+		columns["stock"] = *c.Stock
+	}
+	if c.Description != nil {
+		columns["description"] = *c.Description
+	}
+	if c.ImageUrl != nil {
+		columns["image_url"] = *c.ImageUrl
+	}
+	return columns
+}
+
 // ProductSearchCriteria :nodoc:
 type ProductSearchCriteria struct {
 	Query   string `json:"query"`
@@ -202,21 +485,32 @@ func (c *ProductSearchCriteria) SetDefaultValue() {
 
 type UploadImageProductRequest struct {
 	ProductImage *multipart.FileHeader `form:"product_image" binding:"required"`
-	Path         string
 }
 
-func (ps *UploadImageProductRequest) ValidateDTOUploadImageProductRequest() error {
-	const maxFileSize = 5 << 20 // 5 MB in bytes
-	if ps.ProductImage.Size > maxFileSize {
-		return errors.New("The file size exceeds the maximum limit of 5 MB")
+// ValidateDTOUploadImageProductRequest :nodoc:, maxSize is the max size of the image in bytes
+func (ps *UploadImageProductRequest) ValidateDTOUploadImageProductRequest(maxSize int64) error {
+	if ps.ProductImage == nil {
+		return newFieldError("product_image", "Product image is required")
+	}
+	if ps.ProductImage.Size > maxSize {
+		return newFieldError("product_image", fmt.Sprintf("The file size exceeds the maximum limit of %s", humanize.IBytes(uint64(maxSize))))
 	}
 
 	return nil
 }
 
 type UploadImageProductResponse struct {
-	FileName string `json:"file_name"`
-	ImageUrl string `json:"image_url"`
+	FileName string            `json:"file_name"`
+	ImageUrl string            `json:"image_url"`
+	Images   map[string]string `json:"images,omitempty"`
+}
+
+// ImageDerivativesReport counts the images of a derivatives regeneration, Skipped are the urls of images
+// that weren't uploaded, e.g. hosted elsewhere
+type ImageDerivativesReport struct {
+	Regenerated int
+	Failed      int
+	Skipped     int
 }
 
 type RemoveImageProductRequest struct {
@@ -228,5 +522,22 @@ func (r *RemoveImageProductRequest) Validate() error {
 }
 
 type UploadFileProductRequest struct {
+	Filename    string `form:"-"`
 	ProductFile []byte `form:"product_file" binding:"required"`
+	// ColumnMapping maps a file header to an import field, an empty field ignores the column
+	ColumnMapping map[string]string `form:"column_mapping"`
+	Mode          ProductImportMode `form:"mode"`
+	// Atomic imports the whole file in a single transaction, rolled back on the first invalid row
+	Atomic bool `form:"atomic"`
+}
+
+// UploadStreamProductRequest is an import read while it's uploaded, from a csv Content
+// or from the products returned by NextProduct when it's set
+type UploadStreamProductRequest struct {
+	Filename      string
+	ColumnMapping map[string]string
+	Mode          ProductImportMode
+	Content       io.Reader
+	// NextProduct returns the next product of the stream, io.EOF once the stream is over
+	NextProduct func() (*Product, error)
 }
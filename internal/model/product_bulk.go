@@ -0,0 +1,102 @@
+package model
+
+import (
+	"fmt"
+	"math"
+)
+
+// ProductBulkOperationType :nodoc:
+type ProductBulkOperationType string
+
+const (
+	// ProductBulkOperationUpdate changes the fields of the operation, as a patch
+	ProductBulkOperationUpdate ProductBulkOperationType = "update"
+	// ProductBulkOperationAdjustPrice changes the price by a percentage, e.g. -10 lowers it by 10%
+	ProductBulkOperationAdjustPrice ProductBulkOperationType = "adjust_price"
+	ProductBulkOperationSetStock    ProductBulkOperationType = "set_stock"
+	ProductBulkOperationDelete      ProductBulkOperationType = "delete"
+	ProductBulkOperationRestore     ProductBulkOperationType = "restore"
+)
+
+// IsValid :nodoc:
+func (t ProductBulkOperationType) IsValid() bool {
+	switch t {
+	case ProductBulkOperationUpdate, ProductBulkOperationAdjustPrice, ProductBulkOperationSetStock,
+		ProductBulkOperationDelete, ProductBulkOperationRestore:
+		return true
+	default:
+		return false
+	}
+}
+
+// ProductBulkOperation is one operation of a bulk mutation, ExpectedVersion works as for a single mutation
+type ProductBulkOperation struct {
+	Type            ProductBulkOperationType
+	ProductID       int64
+	ExpectedVersion int64
+	// Fields are the fields changed by an update
+	Fields PatchProductRequest
+	// PricePercent is the price change of adjust_price, greater than -100
+	PricePercent float64
+	// Stock is the stock set by set_stock
+	Stock int64
+}
+
+// ValidateDTOProductBulkOperation :nodoc:
+func (o *ProductBulkOperation) ValidateDTOProductBulkOperation() error {
+	if o.ProductID <= 0 {
+		return newFieldError("product_id", "Product ID is required")
+	}
+
+	switch o.Type {
+	case ProductBulkOperationUpdate:
+		fields := o.Fields
+		fields.ID = o.ProductID
+		if fields.IsEmpty() {
+			return newFieldError("fields", "Fields are required")
+		}
+		return fields.ValidateDTOPatchProductRequest()
+	case ProductBulkOperationAdjustPrice:
+		if o.PricePercent <= -100 || math.IsNaN(o.PricePercent) || math.IsInf(o.PricePercent, 0) {
+			return newFieldError("price_percent", "Price percent must be greater than -100")
+		}
+	case ProductBulkOperationSetStock:
+		if o.Stock < 0 {
+			return newFieldError("stock", "Stock can't be negative")
+		}
+	case ProductBulkOperationDelete, ProductBulkOperationRestore:
+	default:
+		return newFieldError("type", fmt.Sprintf("Unknown operation type %q", o.Type))
+	}
+
+	return nil
+}
+
+// BulkMutateProductsRequest :nodoc:
+type BulkMutateProductsRequest struct {
+	Operations []ProductBulkOperation
+	// StopOnError applies the operations before the first failing one only, the rest are skipped.
+	// Otherwise every valid operation is applied.
+	StopOnError bool
+}
+
+// ProductBulkResultStatus :nodoc:
+type ProductBulkResultStatus string
+
+const (
+	ProductBulkResultSucceeded ProductBulkResultStatus = "succeeded"
+	ProductBulkResultFailed    ProductBulkResultStatus = "failed"
+	// ProductBulkResultSkipped is an operation after the failed one of a stop on error mutation
+	ProductBulkResultSkipped ProductBulkResultStatus = "skipped"
+)
+
+// ProductBulkResult is the result of the operation at Index
+type ProductBulkResult struct {
+	Index     int
+	ProductID int64
+	Status    ProductBulkResultStatus
+	// Err is the error of a failed operation
+	Err error
+	// Product is the product after a succeeded operation
+	Product *Product
+}
@@ -0,0 +1,194 @@
+package model
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// ProductExportFormat :nodoc:
+type ProductExportFormat string
+
+const (
+	ProductExportFormatCSV   ProductExportFormat = "csv"
+	ProductExportFormatJSONL ProductExportFormat = "jsonl"
+	ProductExportFormatXLSX  ProductExportFormat = "xlsx"
+)
+
+// IsValid :nodoc:
+func (f ProductExportFormat) IsValid() bool {
+	switch f {
+	case ProductExportFormatCSV, ProductExportFormatJSONL, ProductExportFormatXLSX:
+		return true
+	default:
+		return false
+	}
+}
+
+// ContentType :nodoc:
+func (f ProductExportFormat) ContentType() string {
+	switch f {
+	case ProductExportFormatJSONL:
+		return "application/x-ndjson"
+	case ProductExportFormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	default:
+		return "text/csv"
+	}
+}
+
+// ProductExportCriteria filters the exported products, an empty query exports the whole catalog
+type ProductExportCriteria struct {
+	Query string `json:"query"`
+}
+
+// ToImportRecord returns the product as an import row, in the order of ProductImportHeader
+func (p *Product) ToImportRecord() []string {
+	return []string{
+		p.GetExternalID(),
+		p.Name,
+		strconv.FormatFloat(p.Price, 'f', -1, 64),
+		strconv.FormatInt(p.Stock, 10),
+		p.Description,
+		p.ImageUrl,
+	}
+}
+
+// ProductExportWriter writes the products in the column layout accepted by the import,
+// so an exported file of any format can be imported back over its products in the upsert mode
+type ProductExportWriter interface {
+	Write(product *Product) error
+	// Flush writes the buffered products, and the csv header when no product has been written
+	Flush() error
+}
+
+// NewProductExportWriter :nodoc:
+func NewProductExportWriter(w io.Writer, format ProductExportFormat) (ProductExportWriter, error) {
+	switch format {
+	case ProductExportFormatCSV:
+		return &productCSVExportWriter{writer: csv.NewWriter(w)}, nil
+	case ProductExportFormatJSONL:
+		bw := bufio.NewWriter(w)
+		return &productJSONLExportWriter{buffer: bw, encoder: json.NewEncoder(bw)}, nil
+	case ProductExportFormatXLSX:
+		return newProductXLSXExportWriter(w)
+	default:
+		return nil, fmt.Errorf("unknown export format %s", format)
+	}
+}
+
+type productCSVExportWriter struct {
+	writer        *csv.Writer
+	headerWritten bool
+}
+
+func (w *productCSVExportWriter) Write(product *Product) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	return w.writer.Write(product.ToImportRecord())
+}
+
+func (w *productCSVExportWriter) Flush() error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// writeHeader is deferred to the first write, nothing reaches the underlying writer before that
+func (w *productCSVExportWriter) writeHeader() error {
+	if w.headerWritten {
+		return nil
+	}
+	w.headerWritten = true
+	return w.writer.Write(ProductImportHeader())
+}
+
+type productJSONLExportWriter struct {
+	buffer  *bufio.Writer
+	encoder *json.Encoder
+}
+
+func (w *productJSONLExportWriter) Write(product *Product) error {
+	record := product.ToImportRecord()
+	line := make(map[string]any, len(ProductImportFields))
+	for i, field := range ProductImportFields {
+		line[field.Name] = record[i]
+	}
+	line["price"] = product.Price
+	line["stock"] = product.Stock
+
+	return w.encoder.Encode(line)
+}
+
+func (w *productJSONLExportWriter) Flush() error {
+	return w.buffer.Flush()
+}
+
+// productXLSXExportSheet is the only sheet of the exported workbook, the first one is read by the import
+const productXLSXExportSheet = "Products"
+
+// productXLSXExportWriter streams the rows into the workbook, which is written out on Flush
+// since an xlsx file is a zip archive that can't be sent before it's complete
+type productXLSXExportWriter struct {
+	writer io.Writer
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	row    int
+}
+
+func newProductXLSXExportWriter(w io.Writer) (*productXLSXExportWriter, error) {
+	file := excelize.NewFile()
+	if err := file.SetSheetName(file.GetSheetName(0), productXLSXExportSheet); err != nil {
+		return nil, err
+	}
+
+	stream, err := file.NewStreamWriter(productXLSXExportSheet)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]any, 0, len(ProductImportFields))
+	for _, name := range ProductImportHeader() {
+		header = append(header, name)
+	}
+	if err := stream.SetRow("A1", header); err != nil {
+		return nil, err
+	}
+
+	return &productXLSXExportWriter{writer: w, file: file, stream: stream, row: 1}, nil
+}
+
+func (w *productXLSXExportWriter) Write(product *Product) error {
+	w.row++
+	cell, err := excelize.CoordinatesToCellName(1, w.row)
+	if err != nil {
+		return err
+	}
+
+	// price and stock are numeric cells, the rest are text so an external id like 00123 keeps its zeros
+	return w.stream.SetRow(cell, []any{
+		product.GetExternalID(),
+		product.Name,
+		product.Price,
+		product.Stock,
+		product.Description,
+		product.ImageUrl,
+	})
+}
+
+func (w *productXLSXExportWriter) Flush() error {
+	defer w.file.Close()
+
+	if err := w.stream.Flush(); err != nil {
+		return err
+	}
+	return w.file.Write(w.writer)
+}
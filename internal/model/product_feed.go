@@ -0,0 +1,222 @@
+package model
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProductFeedFormat :nodoc:
+type ProductFeedFormat string
+
+const (
+	// ProductFeedFormatXML is a Google Merchant Center RSS 2.0 feed
+	ProductFeedFormatXML ProductFeedFormat = "xml"
+	// ProductFeedFormatTSV is a Google Merchant Center tab separated feed
+	ProductFeedFormatTSV ProductFeedFormat = "tsv"
+)
+
+// IsValid :nodoc:
+func (f ProductFeedFormat) IsValid() bool {
+	return f == ProductFeedFormatXML || f == ProductFeedFormatTSV
+}
+
+// ContentType :nodoc:
+func (f ProductFeedFormat) ContentType() string {
+	if f == ProductFeedFormatTSV {
+		return "text/tab-separated-values; charset=utf-8"
+	}
+	return "application/rss+xml; charset=utf-8"
+}
+
+const (
+	ProductFeedAvailabilityInStock    = "in_stock"
+	ProductFeedAvailabilityOutOfStock = "out_of_stock"
+)
+
+// ProductFeedChannel describes the shop publishing the feed
+type ProductFeedChannel struct {
+	Title       string
+	Link        string
+	Description string
+	Currency    string
+	// ProductLinkFormat is the product page url with a %d for the product id, no link is rendered when empty
+	ProductLinkFormat string
+}
+
+// ProductFeedItem is a product as rendered in the feed
+type ProductFeedItem struct {
+	ID          int64     `json:"id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Price       float64   `json:"price"`
+	Stock       int64     `json:"stock"`
+	ImageLink   string    `json:"image_link"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// NewProductFeedItem :nodoc:
+func NewProductFeedItem(p *Product) *ProductFeedItem {
+	item := &ProductFeedItem{
+		ID:          p.ID,
+		Title:       p.Name,
+		Description: p.Description,
+		Price:       p.Price,
+		Stock:       p.Stock,
+		ImageLink:   p.ImageUrl,
+	}
+	if p.UpdatedAt != nil {
+		item.UpdatedAt = *p.UpdatedAt
+	}
+	return item
+}
+
+// Availability is derived from the stock
+func (i *ProductFeedItem) Availability() string {
+	if i.Stock > 0 {
+		return ProductFeedAvailabilityInStock
+	}
+	return ProductFeedAvailabilityOutOfStock
+}
+
+// FormatPrice returns the price as expected by Merchant Center, e.g. 15000.00 IDR
+func (i *ProductFeedItem) FormatPrice(currency string) string {
+	return strconv.FormatFloat(i.Price, 'f', 2, 64) + " " + currency
+}
+
+// ProductFeedSnapshot is a generated feed kept between two generations,
+// so the next one only reads the products changed since GeneratedAt
+type ProductFeedSnapshot struct {
+	GeneratedAt time.Time                  `json:"generated_at"`
+	Items       map[int64]*ProductFeedItem `json:"items"`
+}
+
+// SortedItems returns the items ordered by id
+func (s *ProductFeedSnapshot) SortedItems() []*ProductFeedItem {
+	items := make([]*ProductFeedItem, 0, len(s.Items))
+	for _, item := range s.Items {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	return items
+}
+
+// WriteProductFeed renders the items in the given format
+func WriteProductFeed(w io.Writer, format ProductFeedFormat, channel ProductFeedChannel, items []*ProductFeedItem) error {
+	switch format {
+	case ProductFeedFormatXML:
+		return writeProductFeedXML(w, channel, items)
+	case ProductFeedFormatTSV:
+		return writeProductFeedTSV(w, channel, items)
+	default:
+		return fmt.Errorf("unknown feed format %s", format)
+	}
+}
+
+// productFeedNamespace is the namespace of the g: prefixed elements
+const productFeedNamespace = "http://base.google.com/ns/1.0"
+
+type productFeedRSS struct {
+	XMLName xml.Name           `xml:"rss"`
+	Version string             `xml:"version,attr"`
+	G       string             `xml:"xmlns:g,attr"`
+	Channel productFeedChannel `xml:"channel"`
+}
+
+type productFeedChannel struct {
+	Title       string               `xml:"title"`
+	Link        string               `xml:"link"`
+	Description string               `xml:"description"`
+	Items       []productFeedXMLItem `xml:"item"`
+}
+
+type productFeedXMLItem struct {
+	ID           int64  `xml:"g:id"`
+	Title        string `xml:"g:title"`
+	Description  string `xml:"g:description"`
+	Link         string `xml:"g:link,omitempty"`
+	ImageLink    string `xml:"g:image_link,omitempty"`
+	Price        string `xml:"g:price"`
+	Availability string `xml:"g:availability"`
+}
+
+func writeProductFeedXML(w io.Writer, channel ProductFeedChannel, items []*ProductFeedItem) error {
+	rss := productFeedRSS{
+		Version: "2.0",
+		G:       productFeedNamespace,
+		Channel: productFeedChannel{
+			Title:       channel.Title,
+			Link:        channel.Link,
+			Description: channel.Description,
+			Items:       make([]productFeedXMLItem, 0, len(items)),
+		},
+	}
+	for _, item := range items {
+		rss.Channel.Items = append(rss.Channel.Items, productFeedXMLItem{
+			ID:           item.ID,
+			Title:        item.Title,
+			Description:  item.Description,
+			Link:         channel.productLink(item.ID),
+			ImageLink:    item.ImageLink,
+			Price:        item.FormatPrice(channel.Currency),
+			Availability: item.Availability(),
+		})
+	}
+
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString(xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(bw)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(rss); err != nil {
+		return err
+	}
+	if err := bw.WriteByte('\n'); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// productFeedTSVHeader is the attribute names of Merchant Center, in the column order of writeProductFeedTSV
+var productFeedTSVHeader = []string{"id", "title", "description", "link", "image_link", "price", "availability"}
+
+func writeProductFeedTSV(w io.Writer, channel ProductFeedChannel, items []*ProductFeedItem) error {
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString(strings.Join(productFeedTSVHeader, "\t") + "\n"); err != nil {
+		return err
+	}
+	for _, item := range items {
+		line := strings.Join([]string{
+			strconv.FormatInt(item.ID, 10),
+			tsvField(item.Title),
+			tsvField(item.Description),
+			channel.productLink(item.ID),
+			tsvField(item.ImageLink),
+			item.FormatPrice(channel.Currency),
+			item.Availability(),
+		}, "\t")
+		if _, err := bw.WriteString(line + "\n"); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+func (c ProductFeedChannel) productLink(id int64) string {
+	if c.ProductLinkFormat == "" {
+		return ""
+	}
+	return fmt.Sprintf(c.ProductLinkFormat, id)
+}
+
+// tsvField flattens the tabs and line breaks, the tsv feed has no quoting
+func tsvField(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
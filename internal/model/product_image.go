@@ -0,0 +1,71 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+const maxProductImageAltTextLength = 255
+
+// ProductImage is an image of the gallery of a product, the ImageUrl of the product is the one of its primary image
+type ProductImage struct {
+	ID        int64             `json:"id" gorm:"<-:create; primary_key;AUTO_INCREMENT"`
+	ProductID int64             `json:"product_id"`
+	ImageUrl  string            `json:"image_url"`
+	AltText   string            `json:"alt_text"`
+	Position  int               `json:"position"`
+	IsPrimary bool              `json:"is_primary"`
+	Images    map[string]string `json:"images,omitempty" gorm:"-"`
+	CreatedAt *time.Time        `json:"created_at,omitempty" gorm:"->;<-:create"`
+	UpdatedAt *time.Time        `json:"updated_at,omitempty"`
+}
+
+// AttachProductImageRequest :nodoc:
+type AttachProductImageRequest struct {
+	ProductID int64  `json:"-"`
+	ImageUrl  string `json:"image_url"`
+	AltText   string `json:"alt_text"`
+	// IsPrimary makes the image the primary one, the first image of a gallery is always primary
+	IsPrimary       bool  `json:"is_primary"`
+	ExpectedVersion int64 `json:"-"`
+}
+
+// ValidateDTOAttachProductImageRequest :nodoc:
+func (r *AttachProductImageRequest) ValidateDTOAttachProductImageRequest() error {
+	if r.ImageUrl == "" {
+		return newFieldError("image_url", "Image URL is required")
+	}
+	if len(r.AltText) > maxProductImageAltTextLength {
+		return newFieldError("alt_text", fmt.Sprintf("Alt text must be at most %d characters", maxProductImageAltTextLength))
+	}
+	return nil
+}
+
+// ReorderProductImagesRequest :nodoc:
+type ReorderProductImagesRequest struct {
+	ProductID int64 `json:"-"`
+	// ImageIDs are the ids of every image of the gallery in their new order
+	ImageIDs        []int64 `json:"image_ids"`
+	ExpectedVersion int64   `json:"-"`
+}
+
+// ValidateDTOReorderProductImagesRequest checks the ids are the ones of the gallery
+func (r *ReorderProductImagesRequest) ValidateDTOReorderProductImagesRequest(gallery []*ProductImage) error {
+	if len(r.ImageIDs) != len(gallery) {
+		return newFieldError("image_ids", "Image IDs must list every image of the product")
+	}
+
+	positions := make(map[int64]int, len(r.ImageIDs))
+	for position, id := range r.ImageIDs {
+		if _, ok := positions[id]; ok {
+			return newFieldError("image_ids", "Image IDs must be unique")
+		}
+		positions[id] = position
+	}
+	for _, image := range gallery {
+		if _, ok := positions[image.ID]; !ok {
+			return newFieldError("image_ids", "Image IDs must list every image of the product")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	pb "github.com/binus-thesis-team/product-service/pb/product_service"
+)
+
+type ProductImportJobRepository interface {
+	Create(ctx context.Context, job *ProductImportJob) error
+	FindByID(ctx context.Context, id int64) (*ProductImportJob, error)
+	Update(ctx context.Context, job *ProductImportJob) error
+	// FailStale fails the queued and running jobs not saved since staleBefore with the message
+	FailStale(ctx context.Context, staleBefore time.Time, message string) (count int64, err error)
+	CreateRowErrors(ctx context.Context, rowErrors []*ProductImportRowError) error
+	FindRowErrorsByJobID(ctx context.Context, jobID int64) ([]*ProductImportRowError, error)
+}
+
+// ProductImportJobStatus :nodoc:
+type ProductImportJobStatus string
+
+const (
+	ProductImportJobStatusQueued  ProductImportJobStatus = "queued"
+	ProductImportJobStatusRunning ProductImportJobStatus = "running"
+	ProductImportJobStatusDone    ProductImportJobStatus = "done"
+	ProductImportJobStatusFailed  ProductImportJobStatus = "failed"
+)
+
+// ProductImportMode decides what happens to the rows matching an existing product by external id
+type ProductImportMode string
+
+const (
+	// ProductImportModeCreateOnly creates every row, a row with an existing external id is rejected
+	ProductImportModeCreateOnly ProductImportMode = "create-only"
+	// ProductImportModeUpdateOnly updates the products matching the external id, other rows are rejected
+	ProductImportModeUpdateOnly ProductImportMode = "update-only"
+	// ProductImportModeUpsert updates the products matching the external id and creates the others
+	ProductImportModeUpsert ProductImportMode = "upsert"
+)
+
+// IsValid :nodoc:
+func (m ProductImportMode) IsValid() bool {
+	switch m {
+	case ProductImportModeCreateOnly, ProductImportModeUpdateOnly, ProductImportModeUpsert:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsKeyedByExternalID returns true when every row must carry an external id
+func (m ProductImportMode) IsKeyedByExternalID() bool {
+	return m == ProductImportModeUpdateOnly || m == ProductImportModeUpsert
+}
+
+// ProductImportJob tracks a product file import running in the background.
+// A job is done once every row has been processed even if some rows are rejected,
+// it is failed only when the file itself can't be processed.
+type ProductImportJob struct {
+	ID            int64                  `json:"id" gorm:"<-:create; primary_key;AUTO_INCREMENT"`
+	Filename      string                 `json:"filename"`
+	Mode          ProductImportMode      `json:"mode"`
+	Atomic        bool                   `json:"atomic"`
+	Status        ProductImportJobStatus `json:"status"`
+	TotalRows     int64                  `json:"total_rows"`
+	ProcessedRows int64                  `json:"processed_rows"`
+	CreatedRows   int64                  `json:"created_rows"`
+	UpdatedRows   int64                  `json:"updated_rows"`
+	FailedRows    int64                  `json:"failed_rows"`
+	ErrorMessage  string                 `json:"error_message,omitempty"`
+	CreatedBy     int64                  `json:"created_by"`
+	StartedAt     *time.Time             `json:"started_at,omitempty"`
+	FinishedAt    *time.Time             `json:"finished_at,omitempty"`
+	CreatedAt     *time.Time             `json:"created_at,omitempty" gorm:"->;<-:create"`
+	UpdatedAt     *time.Time             `json:"updated_at,omitempty"`
+}
+
+// ProductImportRowError is a rejected row of an import job.
+// RowNumber is the line of the row in the file, the header being row 1.
+type ProductImportRowError struct {
+	ID         int64  `json:"-" gorm:"<-:create; primary_key;AUTO_INCREMENT"`
+	JobID      int64  `json:"-"`
+	RowNumber  int64  `json:"row"`
+	ColumnName string `json:"column"`
+	Reason     string `json:"reason"`
+}
+
+// TableName :nodoc:
+func (ProductImportRowError) TableName() string {
+	return "product_import_job_errors"
+}
+
+// ProductImportReport is the outcome of a dry run import, nothing is saved
+type ProductImportReport struct {
+	Mode        ProductImportMode        `json:"mode"`
+	DryRun      bool                     `json:"dry_run"`
+	TotalRows   int64                    `json:"total_rows"`
+	CreatedRows int64                    `json:"created_rows"`
+	UpdatedRows int64                    `json:"updated_rows"`
+	FailedRows  int64                    `json:"failed_rows"`
+	RowErrors   []*ProductImportRowError `json:"row_errors"`
+}
+
+// ToProto :nodoc:
+func (r *ProductImportReport) ToProto() *pb.ImportReport {
+	rowErrors := make([]*pb.ImportRowError, 0, len(r.RowErrors))
+	for _, rowErr := range r.RowErrors {
+		rowErrors = append(rowErrors, &pb.ImportRowError{
+			Row:    rowErr.RowNumber,
+			Column: rowErr.ColumnName,
+			Reason: rowErr.Reason,
+		})
+	}
+
+	return &pb.ImportReport{
+		Mode:        string(r.Mode),
+		TotalRows:   r.TotalRows,
+		CreatedRows: r.CreatedRows,
+		UpdatedRows: r.UpdatedRows,
+		FailedRows:  r.FailedRows,
+		RowErrors:   rowErrors,
+	}
+}
+
+// ProductImportField describes a column accepted by the product import
+type ProductImportField struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Required bool     `json:"required"`
+	Rule     string   `json:"rule"`
+	Aliases  []string `json:"aliases"`
+}
+
+// ProductImportFields are the import columns, in the order of the template header row.
+// The rules are the ones enforced by CreateProductRequest.ValidateDTOCreateProductRequest, the looser ones
+// of ValidateDTOImportProductRequest apply to the rows updating a product. A required column must be in
+// the header even when its values can be empty.
+var ProductImportFields = []ProductImportField{
+	{Name: "external_id", Type: "string", Required: false, Rule: "unique, required by the update-only and upsert modes", Aliases: []string{"sku", "external_sku", "kode"}},
+	{Name: "name", Type: "string", Required: true, Rule: "must not be empty", Aliases: []string{"product_name", "title", "nama"}},
+	{Name: "price", Type: "number", Required: true, Rule: "must be greater than 0", Aliases: []string{"unit_price", "harga"}},
+	{Name: "stock", Type: "integer", Required: true, Rule: "must be greater than 0, must not be negative when updating a product", Aliases: []string{"qty", "quantity", "stok"}},
+	{Name: "description", Type: "string", Required: true, Rule: "must not be empty, may be empty when updating a product", Aliases: []string{"desc", "deskripsi"}},
+	{Name: "image_url", Type: "string", Required: true, Rule: "must not be empty, may be empty when updating a product", Aliases: []string{"image", "image_link", "gambar"}},
+}
+
+// ProductImportHeader returns the header row of the product import template
+func ProductImportHeader() []string {
+	header := make([]string, 0, len(ProductImportFields))
+	for _, field := range ProductImportFields {
+		header = append(header, field.Name)
+	}
+	return header
+}
+
+// ImportHeaderError reports the header columns that can't be resolved to the import fields
+type ImportHeaderError struct {
+	MissingColumns   []string `json:"missing_columns,omitempty"`
+	UnknownColumns   []string `json:"unknown_columns,omitempty"`
+	DuplicateColumns []string `json:"duplicate_columns,omitempty"`
+}
+
+// Error :nodoc:
+func (e *ImportHeaderError) Error() string {
+	var msgs []string
+	if len(e.MissingColumns) > 0 {
+		msgs = append(msgs, fmt.Sprintf("missing columns: %s", strings.Join(e.MissingColumns, ", ")))
+	}
+	if len(e.UnknownColumns) > 0 {
+		msgs = append(msgs, fmt.Sprintf("unknown columns: %s", strings.Join(e.UnknownColumns, ", ")))
+	}
+	if len(e.DuplicateColumns) > 0 {
+		msgs = append(msgs, fmt.Sprintf("duplicate columns: %s", strings.Join(e.DuplicateColumns, ", ")))
+	}
+	return "invalid import header, " + strings.Join(msgs, "; ")
+}
+
+// HasError returns true when any column can't be resolved
+func (e *ImportHeaderError) HasError() bool {
+	return len(e.MissingColumns) > 0 || len(e.UnknownColumns) > 0 || len(e.DuplicateColumns) > 0
+}
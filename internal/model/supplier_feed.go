@@ -0,0 +1,176 @@
+package model
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MinSupplierFeedSchedule is the shortest interval between two runs of a feed
+const MinSupplierFeedSchedule = 5 * time.Minute
+
+type SupplierFeedUsecase interface {
+	Create(ctx context.Context, user SessionUser, input CreateSupplierFeedRequest) (feed *SupplierFeed, err error)
+	FindByID(ctx context.Context, user SessionUser, id int64) (feed *SupplierFeed, err error)
+	FindAll(ctx context.Context, user SessionUser) (feeds []*SupplierFeed, err error)
+	Update(ctx context.Context, user SessionUser, input UpdateSupplierFeedRequest) (feed *SupplierFeed, err error)
+	DeleteByID(ctx context.Context, user SessionUser, id int64) (err error)
+	TriggerByID(ctx context.Context, user SessionUser, id int64) (feed *SupplierFeed, err error)
+	FindRunsByFeedID(ctx context.Context, user SessionUser, feedID int64) (runs []*SupplierFeedRun, err error)
+	// RunDueFeeds runs every feed whose next run is due, one after another, and returns the number of runs
+	RunDueFeeds(ctx context.Context) (count int, err error)
+	// FailStaleRuns fails the runs left running by a process that is gone and returns their number
+	FailStaleRuns(ctx context.Context) (count int64, err error)
+}
+
+type SupplierFeedRepository interface {
+	Create(ctx context.Context, feed *SupplierFeed) error
+	FindByID(ctx context.Context, id int64) (*SupplierFeed, error)
+	FindAll(ctx context.Context) ([]*SupplierFeed, error)
+	// Update saves the given columns of the feed only, zero values included, so the columns written
+	// meanwhile, e.g. the run schedule by ClaimDue, are kept
+	Update(ctx context.Context, feed *SupplierFeed, columns ...string) error
+	DeleteByID(ctx context.Context, id int64) error
+	// ClaimDue reschedules the next due feed and returns it, nil when no feed is due.
+	// A feed is claimed by a single process even when several run the scheduler.
+	ClaimDue(ctx context.Context, now time.Time) (*SupplierFeed, error)
+	CreateRun(ctx context.Context, run *SupplierFeedRun) error
+	UpdateRun(ctx context.Context, run *SupplierFeedRun) error
+	// FailStaleRuns fails the running runs started before staleBefore whose import job, if any, is no longer
+	// queued or running, with the message
+	FailStaleRuns(ctx context.Context, staleBefore time.Time, message string) (count int64, err error)
+	FindRunsByFeedID(ctx context.Context, feedID int64, limit int) ([]*SupplierFeedRun, error)
+}
+
+// SupplierFeedFormat :nodoc:
+type SupplierFeedFormat string
+
+const (
+	// SupplierFeedFormatCSV is a csv, xlsx or jsonl file, detected from the content like an upload
+	SupplierFeedFormatCSV SupplierFeedFormat = "csv"
+	// SupplierFeedFormatJSON is an array of objects, the keys being the columns
+	SupplierFeedFormatJSON SupplierFeedFormat = "json"
+)
+
+// IsValid :nodoc:
+func (f SupplierFeedFormat) IsValid() bool {
+	return f == SupplierFeedFormatCSV || f == SupplierFeedFormatJSON
+}
+
+// SupplierFeed is a supplier catalog file fetched from URL and imported every Schedule.
+// With an UpsertKey the feed is imported in upsert mode keyed by that column, otherwise in create-only mode.
+type SupplierFeed struct {
+	ID            int64              `json:"id" gorm:"<-:create; primary_key;AUTO_INCREMENT"`
+	Name          string             `json:"name"`
+	URL           string             `json:"url"`
+	Format        SupplierFeedFormat `json:"format"`
+	ColumnMapping map[string]string  `json:"column_mapping" gorm:"serializer:json"`
+	Schedule      string             `json:"schedule"`
+	UpsertKey     string             `json:"upsert_key"`
+	Enabled       bool               `json:"enabled"`
+	NextRunAt     time.Time          `json:"next_run_at"`
+	LastRunAt     *time.Time         `json:"last_run_at,omitempty"`
+	CreatedBy     int64              `json:"created_by"`
+	CreatedAt     time.Time          `json:"created_at" gorm:"->;<-:create"`
+	UpdatedAt     time.Time          `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt     `json:"deleted_at,omitempty"`
+}
+
+// GetSchedule returns the interval between two runs
+func (f *SupplierFeed) GetSchedule() time.Duration {
+	schedule, _ := time.ParseDuration(f.Schedule)
+	return max(schedule, MinSupplierFeedSchedule)
+}
+
+// ImportMode :nodoc:
+func (f *SupplierFeed) ImportMode() ProductImportMode {
+	if f.UpsertKey != "" {
+		return ProductImportModeUpsert
+	}
+	return ProductImportModeCreateOnly
+}
+
+// ImportColumnMapping returns the column mapping with the upsert key mapped to the external id
+func (f *SupplierFeed) ImportColumnMapping() map[string]string {
+	mapping := make(map[string]string, len(f.ColumnMapping)+1)
+	for column, field := range f.ColumnMapping {
+		mapping[column] = field
+	}
+	if f.UpsertKey != "" {
+		mapping[f.UpsertKey] = "external_id"
+	}
+	return mapping
+}
+
+// SupplierFeedRunStatus :nodoc:
+type SupplierFeedRunStatus string
+
+const (
+	SupplierFeedRunStatusRunning SupplierFeedRunStatus = "running"
+	SupplierFeedRunStatusDone    SupplierFeedRunStatus = "done"
+	SupplierFeedRunStatusFailed  SupplierFeedRunStatus = "failed"
+)
+
+// SupplierFeedRun is the outcome of a feed run. The rows are imported through an import job,
+// its row error report is the one of the job.
+type SupplierFeedRun struct {
+	ID           int64                 `json:"id" gorm:"<-:create; primary_key;AUTO_INCREMENT"`
+	FeedID       int64                 `json:"feed_id"`
+	ImportJobID  *int64                `json:"import_job_id,omitempty"`
+	Status       SupplierFeedRunStatus `json:"status"`
+	HTTPStatus   int                   `json:"http_status"`
+	TotalRows    int64                 `json:"total_rows"`
+	CreatedRows  int64                 `json:"created_rows"`
+	UpdatedRows  int64                 `json:"updated_rows"`
+	FailedRows   int64                 `json:"failed_rows"`
+	ErrorMessage string                `json:"error_message,omitempty"`
+	StartedAt    time.Time             `json:"started_at"`
+	FinishedAt   *time.Time            `json:"finished_at,omitempty"`
+}
+
+type CreateSupplierFeedRequest struct {
+	Name          string             `json:"name"`
+	URL           string             `json:"url"`
+	Format        SupplierFeedFormat `json:"format"`
+	ColumnMapping map[string]string  `json:"column_mapping"`
+	Schedule      string             `json:"schedule"`
+	UpsertKey     string             `json:"upsert_key"`
+	// Enabled defaults to true
+	Enabled *bool `json:"enabled"`
+}
+
+func (c *CreateSupplierFeedRequest) ValidateDTOCreateSupplierFeedRequest() error {
+	if c.Name == "" {
+		return newFieldError("name", "Name is required")
+	}
+
+	if u, err := url.Parse(c.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return newFieldError("url", "URL must be an absolute http or https URL")
+	}
+
+	if !c.Format.IsValid() {
+		return newFieldError("format", "Format must be csv or json")
+	}
+
+	schedule, err := time.ParseDuration(c.Schedule)
+	if err != nil {
+		return newFieldError("schedule", "Schedule must be a duration, e.g. 6h")
+	}
+	if schedule < MinSupplierFeedSchedule {
+		return newFieldError("schedule", "Schedule must be at least "+MinSupplierFeedSchedule.String())
+	}
+
+	return nil
+}
+
+// IsEnabled :nodoc:
+func (c *CreateSupplierFeedRequest) IsEnabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+type UpdateSupplierFeedRequest struct {
+	ID int64 `json:"-"`
+	CreateSupplierFeedRequest
+}
@@ -16,3 +16,18 @@ func init() {
 		validate = validator.New()
 	})
 }
+
+// FieldError is a validation failure of a single field
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// Error :nodoc:
+func (e *FieldError) Error() string {
+	return e.Message
+}
+
+func newFieldError(field, message string) error {
+	return &FieldError{Field: field, Message: message}
+}
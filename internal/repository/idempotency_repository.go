@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/binus-thesis-team/iam-service/utils"
+	"github.com/binus-thesis-team/product-service/internal/helper"
+	"github.com/binus-thesis-team/product-service/internal/model"
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/sirupsen/logrus"
+)
+
+// idempotencyBeginAttempts retries a key expiring between its SET NX and its GET
+const idempotencyBeginAttempts = 2
+
+type idempotencyRepository struct {
+	pool        *redigo.Pool
+	ttl         time.Duration
+	inFlightTTL time.Duration
+}
+
+// NewIdempotencyRepository stores the completed responses for ttl, an in flight key is unlocked
+// after inFlightTTL when its request never completes
+func NewIdempotencyRepository(pool *redigo.Pool, ttl, inFlightTTL time.Duration) model.IdempotencyRepository {
+	return &idempotencyRepository{
+		pool:        pool,
+		ttl:         ttl,
+		inFlightTTL: inFlightTTL,
+	}
+}
+
+func (r *idempotencyRepository) Begin(ctx context.Context, key, fingerprint string) (*model.IdempotencyRecord, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"ctx": utils.DumpIncomingContext(ctx),
+		"key": key,
+	})
+
+	inFlight, err := json.Marshal(&model.IdempotencyRecord{Fingerprint: fingerprint})
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	conn, err := r.pool.GetContext(ctx)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+	defer helper.WrapCloser(conn.Close)
+
+	for attempt := 0; attempt < idempotencyBeginAttempts; attempt++ {
+		_, err := redigo.String(conn.Do("SET", key, inFlight, "NX", "PX", r.inFlightTTL.Milliseconds()))
+		switch {
+		case err == nil:
+			return nil, nil
+		case !errors.Is(err, redigo.ErrNil):
+			logger.Error(err)
+			return nil, err
+		}
+
+		reply, err := redigo.Bytes(conn.Do("GET", key))
+		switch {
+		case errors.Is(err, redigo.ErrNil):
+			continue
+		case err != nil:
+			logger.Error(err)
+			return nil, err
+		}
+
+		record := &model.IdempotencyRecord{}
+		if err := json.Unmarshal(reply, record); err != nil {
+			logger.Error(err)
+			return nil, err
+		}
+		return record, nil
+	}
+
+	// the key keeps expiring, it's held by requests that never complete
+	return &model.IdempotencyRecord{Fingerprint: fingerprint}, nil
+}
+
+func (r *idempotencyRepository) Complete(ctx context.Context, key string, record *model.IdempotencyRecord) error {
+	logger := logrus.WithFields(logrus.Fields{
+		"ctx": utils.DumpIncomingContext(ctx),
+		"key": key,
+	})
+
+	record.Completed = true
+	value, err := json.Marshal(record)
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	conn, err := r.pool.GetContext(ctx)
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+	defer helper.WrapCloser(conn.Close)
+
+	if _, err := conn.Do("SET", key, value, "PX", r.ttl.Milliseconds()); err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *idempotencyRepository) Release(ctx context.Context, key string) error {
+	conn, err := r.pool.GetContext(ctx)
+	if err != nil {
+		logrus.WithField("key", key).Error(err)
+		return err
+	}
+	defer helper.WrapCloser(conn.Close)
+
+	if _, err := conn.Do("DEL", key); err != nil {
+		logrus.WithField("key", key).Error(err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/binus-thesis-team/product-service/internal/model"
+)
+
+// imageURLs maps the keys of an image store to public urls under baseURL
+type imageURLs struct {
+	baseURL string
+}
+
+func newImageURLs(baseURL string) imageURLs {
+	return imageURLs{baseURL: strings.TrimSuffix(baseURL, "/") + "/"}
+}
+
+// URL :nodoc:
+func (u imageURLs) URL(key string) string {
+	return u.baseURL + key
+}
+
+// Key :nodoc:
+func (u imageURLs) Key(url string) (string, bool) {
+	key, ok := strings.CutPrefix(url, u.baseURL)
+	if !ok || checkImageKey(key) != nil {
+		return "", false
+	}
+	return key, true
+}
+
+// checkImageKey rejects the keys reaching out of the store, e.g. ../config.yml
+func checkImageKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key || key == ".." || strings.HasPrefix(key, "../") {
+		return fmt.Errorf("%w %q", model.ErrInvalidImageKey, key)
+	}
+	return nil
+}
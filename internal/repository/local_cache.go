@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/binus-thesis-team/product-service/internal/helper"
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"github.com/jpillora/backoff"
+	"github.com/sirupsen/logrus"
+)
+
+// LocalCache is an in-process LRU tier that sits in front of the redis cache.
+// Every invalidation is published to a redis channel, so the other replicas
+// can drop their own copy of the same keys.
+// A nil *LocalCache is valid and behaves as a cache that always misses.
+type LocalCache struct {
+	items   *expirable.LRU[string, []byte]
+	pool    *redigo.Pool
+	channel string
+}
+
+// NewLocalCache :nodoc:
+func NewLocalCache(pool *redigo.Pool, channel string, size int, ttl time.Duration) *LocalCache {
+	return &LocalCache{
+		items:   expirable.NewLRU[string, []byte](size, nil, ttl),
+		pool:    pool,
+		channel: channel,
+	}
+}
+
+// Get returns the cached value of the key
+func (c *LocalCache) Get(key string) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	return c.items.Get(key)
+}
+
+// Set stores the value of the key until it is evicted, expired or invalidated
+func (c *LocalCache) Set(key string, value []byte) {
+	if c == nil {
+		return
+	}
+	c.items.Add(key, value)
+}
+
+// Invalidate removes the keys from this instance and publishes them to the other replicas.
+// Keys may be glob patterns as understood by path.Match.
+func (c *LocalCache) Invalidate(keys ...string) error {
+	if c == nil || len(keys) == 0 {
+		return nil
+	}
+
+	c.remove(keys)
+
+	return PublishLocalCacheInvalidation(c.pool, c.channel, keys...)
+}
+
+// PublishLocalCacheInvalidation asks every replica subscribed to the channel to drop the keys
+// from its local cache, without holding a local cache itself
+func PublishLocalCacheInvalidation(pool *redigo.Pool, channel string, keys ...string) error {
+	conn := pool.Get()
+	defer helper.WrapCloser(conn.Close)
+
+	payload, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.Do("PUBLISH", channel, payload)
+	return err
+}
+
+// Subscribe consumes invalidation messages until the context is done.
+// The connection is re-established with backoff, and the whole cache is purged
+// every time the subscription drops since messages may have been missed meanwhile.
+func (c *LocalCache) Subscribe(ctx context.Context) {
+	if c == nil {
+		return
+	}
+
+	b := &backoff.Backoff{
+		Factor: 2,
+		Jitter: true,
+		Min:    100 * time.Millisecond,
+		Max:    10 * time.Second,
+	}
+
+	for {
+		err := c.subscribe(ctx, b)
+		if ctx.Err() != nil {
+			return
+		}
+
+		logrus.WithField("channel", c.channel).Error("local cache subscription dropped: ", err)
+		c.items.Purge()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(b.Duration()):
+		}
+	}
+}
+
+func (c *LocalCache) subscribe(ctx context.Context, b *backoff.Backoff) error {
+	psc := redigo.PubSubConn{Conn: c.pool.Get()}
+	defer helper.WrapCloser(psc.Close)
+
+	if err := psc.Subscribe(c.channel); err != nil {
+		return err
+	}
+
+	// the connection is closed only once the unsubscribe is written, a connection isn't safe for concurrent writes
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	defer func() {
+		close(done)
+		<-stopped
+	}()
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			_ = psc.Unsubscribe()
+		case <-done:
+		}
+	}()
+
+	for {
+		switch v := psc.Receive().(type) {
+		case redigo.Message:
+			var keys []string
+			if err := json.Unmarshal(v.Data, &keys); err != nil {
+				logrus.WithField("data", string(v.Data)).Error(err)
+				continue
+			}
+			c.remove(keys)
+		case redigo.Subscription:
+			switch {
+			case v.Kind == "subscribe":
+				b.Reset()
+			case v.Count == 0:
+				return nil
+			}
+		case error:
+			return v
+		}
+	}
+}
+
+// remove drops the keys from this instance, a key holding glob characters
+// is matched against every cached key
+func (c *LocalCache) remove(keys []string) {
+	for _, key := range keys {
+		if !strings.ContainsAny(key, "*?[") {
+			c.items.Remove(key)
+			continue
+		}
+
+		for _, cachedKey := range c.items.Keys() {
+			if ok, _ := path.Match(key, cachedKey); ok {
+				c.items.Remove(cachedKey)
+			}
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+const testLocalCacheChannel = "cache:invalidation:test"
+
+func newTestRedisPool(t *testing.T) (*miniredis.Miniredis, *redigo.Pool) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	pool := &redigo.Pool{
+		Dial: func() (redigo.Conn, error) {
+			return redigo.Dial("tcp", server.Addr())
+		},
+	}
+	t.Cleanup(func() { _ = pool.Close() })
+
+	return server, pool
+}
+
+// subscribeTestLocalCache runs the subscription of the cache until the test ends,
+// it returns once the channel has the given number of subscribers
+func subscribeTestLocalCache(t *testing.T, server *miniredis.Miniredis, cache *LocalCache, subscribers int) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cache.Subscribe(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	waitFor(t, "the subscription", func() bool {
+		return server.PubSubNumSub(testLocalCacheChannel)[testLocalCacheChannel] == subscribers
+	})
+}
+
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLocalCache_GetEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewLocalCache(nil, testLocalCacheChannel, 2, time.Minute)
+
+	cache.Set("a", []byte("1"))
+	cache.Set("b", []byte("2"))
+	if value, ok := cache.Get("a"); !ok || string(value) != "1" {
+		t.Fatalf("Get(a) = %q, %v, want a hit", value, ok)
+	}
+
+	// b is the least recently used since a was read
+	cache.Set("c", []byte("3"))
+	if _, ok := cache.Get("b"); ok {
+		t.Error("Get(b) hit, want it evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := cache.Get(key); !ok {
+			t.Errorf("Get(%s) missed, want a hit", key)
+		}
+	}
+}
+
+func TestLocalCache_GetExpiresAfterTTL(t *testing.T) {
+	cache := NewLocalCache(nil, testLocalCacheChannel, 10, 50*time.Millisecond)
+
+	cache.Set("a", []byte("1"))
+	if _, ok := cache.Get("a"); !ok {
+		t.Fatal("Get(a) missed before the TTL")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if _, ok := cache.Get("a"); ok {
+		t.Error("Get(a) hit after the TTL")
+	}
+}
+
+func TestLocalCache_NilCacheAlwaysMisses(t *testing.T) {
+	var cache *LocalCache
+
+	cache.Set("a", []byte("1"))
+	if _, ok := cache.Get("a"); ok {
+		t.Error("Get(a) hit on a nil cache")
+	}
+	if err := cache.Invalidate("a"); err != nil {
+		t.Errorf("Invalidate() = %v on a nil cache", err)
+	}
+}
+
+func TestLocalCache_InvalidateReachesTheOtherReplicas(t *testing.T) {
+	server, pool := newTestRedisPool(t)
+	replica := NewLocalCache(pool, testLocalCacheChannel, 10, time.Minute)
+	otherReplica := NewLocalCache(pool, testLocalCacheChannel, 10, time.Minute)
+	subscribeTestLocalCache(t, server, replica, 1)
+	subscribeTestLocalCache(t, server, otherReplica, 2)
+
+	for _, cache := range []*LocalCache{replica, otherReplica} {
+		cache.Set(NewProductCacheKeyByID(1), []byte("1"))
+		cache.Set(NewProductCacheKeyByID(2), []byte("2"))
+		cache.Set(NewProductCacheKeyByID(30), []byte("30"))
+	}
+
+	if err := replica.Invalidate(NewProductCacheKeyByID(1), NewProductCacheKeyPattern("3*")); err != nil {
+		t.Fatal(err)
+	}
+
+	// the replica invalidating drops its keys before publishing them
+	if _, ok := replica.Get(NewProductCacheKeyByID(1)); ok {
+		t.Error("the invalidated key is still cached by the replica")
+	}
+	waitFor(t, "the other replica to drop the keys", func() bool {
+		_, ok1 := otherReplica.Get(NewProductCacheKeyByID(1))
+		_, ok30 := otherReplica.Get(NewProductCacheKeyByID(30))
+		return !ok1 && !ok30
+	})
+
+	for _, cache := range []*LocalCache{replica, otherReplica} {
+		if _, ok := cache.Get(NewProductCacheKeyByID(2)); !ok {
+			t.Error("a key not invalidated was dropped")
+		}
+	}
+}
+
+func TestLocalCache_SubscribePurgesWhenTheSubscriptionDrops(t *testing.T) {
+	server, pool := newTestRedisPool(t)
+	cache := NewLocalCache(pool, testLocalCacheChannel, 10, time.Minute)
+	subscribeTestLocalCache(t, server, cache, 1)
+
+	cache.Set("a", []byte("1"))
+
+	// the invalidations published while the subscription is down are lost, the whole cache must go
+	server.Close()
+	waitFor(t, "the cache to be purged", func() bool {
+		_, ok := cache.Get("a")
+		return !ok
+	})
+
+	if err := server.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the subscription to be back", func() bool {
+		return server.PubSubNumSub(testLocalCacheChannel)[testLocalCacheChannel] == 1
+	})
+
+	cache.Set("b", []byte("2"))
+	if err := PublishLocalCacheInvalidation(pool, testLocalCacheChannel, "b"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the invalidation after the resubscription", func() bool {
+		_, ok := cache.Get("b")
+		return !ok
+	})
+}
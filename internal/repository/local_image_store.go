@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path/filepath"
+
+	"github.com/binus-thesis-team/iam-service/utils"
+	"github.com/binus-thesis-team/product-service/internal/model"
+	"github.com/sirupsen/logrus"
+)
+
+type localImageStore struct {
+	imageURLs
+	dir string
+}
+
+// NewLocalImageStore stores the images as files under dir, their urls are under baseURL.
+// It's meant for a single replica, e.g. local development.
+func NewLocalImageStore(dir, baseURL string) model.ImageStore {
+	return &localImageStore{
+		imageURLs: newImageURLs(baseURL),
+		dir:       dir,
+	}
+}
+
+func (s *localImageStore) Put(ctx context.Context, key string, body io.Reader, _ int64, _ string) error {
+	logger := logrus.WithFields(logrus.Fields{
+		"ctx": utils.DumpIncomingContext(ctx),
+		"key": key,
+	})
+
+	path, err := s.path(key)
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	// written next to the image then renamed, a failed upload never leaves a partial image behind
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+	_, err = io.Copy(file, body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *localImageStore) Get(ctx context.Context, key string) (*model.ImageObject, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"ctx": utils.DumpIncomingContext(ctx),
+		"key": key,
+	})
+
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, model.ErrImageNotFound
+	case err != nil:
+		logger.Error(err)
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		logger.Error(err)
+		return nil, err
+	}
+
+	return &model.ImageObject{
+		ReadSeekCloser: file,
+		Size:           info.Size(),
+		ContentType:    mime.TypeByExtension(filepath.Ext(path)),
+		ModTime:        info.ModTime(),
+		// an image is replaced by a rename, so its modification time changes with its content
+		ETag: fmt.Sprintf("%x-%x", info.ModTime().UnixNano(), info.Size()),
+	}, nil
+}
+
+func (s *localImageStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return model.ErrImageNotFound
+	case err != nil:
+		logrus.WithFields(logrus.Fields{
+			"ctx": utils.DumpIncomingContext(ctx),
+			"key": key,
+		}).Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *localImageStore) path(key string) (string, error) {
+	if err := checkImageKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/binus-thesis-team/iam-service/utils"
+	"github.com/binus-thesis-team/product-service/internal/model"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func (u *productRepository) LockByID(ctx context.Context, id int64) (*model.Product, error) {
+	product := &model.Product{}
+	err := u.db.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", id).
+		Take(product).Error
+	switch {
+	case err == nil:
+		return product, nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, nil
+	default:
+		logrus.WithFields(logrus.Fields{
+			"ctx": utils.DumpIncomingContext(ctx),
+			"id":  id,
+		}).Error(err)
+		return nil, err
+	}
+}
+
+func (u *productRepository) FindAllImagesByProductID(ctx context.Context, productID int64) ([]*model.ProductImage, error) {
+	var images []*model.ProductImage
+	err := u.db.WithContext(ctx).
+		Where("product_id = ?", productID).
+		Order("position ASC, id ASC").
+		Find(&images).Error
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"ctx":       utils.DumpIncomingContext(ctx),
+			"productID": productID,
+		}).Error(err)
+		return nil, err
+	}
+
+	return images, nil
+}
+
+func (u *productRepository) CreateImage(ctx context.Context, image *model.ProductImage) error {
+	err := u.db.WithContext(ctx).Create(image).Error
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"ctx":   utils.DumpIncomingContext(ctx),
+			"image": utils.Dump(image),
+		}).Error(err)
+		return err
+	}
+
+	return nil
+}
+
+// UpdateImages clears the primary flag of the images no longer primary first, a product has at most one
+// primary image at any time
+func (u *productRepository) UpdateImages(ctx context.Context, productID int64, images []*model.ProductImage) error {
+	logger := logrus.WithFields(logrus.Fields{
+		"ctx":       utils.DumpIncomingContext(ctx),
+		"productID": productID,
+	})
+
+	var primaryIDs []int64
+	for _, image := range images {
+		if image.IsPrimary {
+			primaryIDs = append(primaryIDs, image.ID)
+		}
+	}
+
+	query := u.db.WithContext(ctx).
+		Model(&model.ProductImage{}).
+		Where("product_id = ? AND is_primary", productID)
+	if len(primaryIDs) > 0 {
+		query = query.Where("id NOT IN ?", primaryIDs)
+	}
+	if err := query.Update("is_primary", false).Error; err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	now := time.Now()
+	for _, image := range images {
+		err := u.db.WithContext(ctx).
+			Model(&model.ProductImage{}).
+			Where("id = ? AND product_id = ?", image.ID, productID).
+			Updates(map[string]any{
+				"position":   image.Position,
+				"is_primary": image.IsPrimary,
+				"updated_at": now,
+			}).Error
+		if err != nil {
+			logger.Error(err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (u *productRepository) DeleteImage(ctx context.Context, productID, imageID int64) error {
+	err := u.db.WithContext(ctx).
+		Where("id = ? AND product_id = ?", imageID, productID).
+		Delete(&model.ProductImage{}).Error
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"ctx":       utils.DumpIncomingContext(ctx),
+			"productID": productID,
+			"imageID":   imageID,
+		}).Error(err)
+		return err
+	}
+
+	return nil
+}
+
+// syncPrimaryImages makes the image_url of the products their primary image. The image is appended to the
+// gallery when it isn't in it, an empty image_url leaves the gallery without a primary image. It runs on the
+// db of the write setting the image_url so the gallery changes in the same transaction as the products.
+func syncPrimaryImages(ctx context.Context, db *gorm.DB, productIDs []int64) error {
+	if len(productIDs) == 0 {
+		return nil
+	}
+
+	db = db.WithContext(ctx)
+	now := time.Now()
+
+	// the stale primary images are cleared first, a product has at most one
+	err := db.Exec(`UPDATE product_images SET is_primary = false, updated_at = ?
+		FROM products
+		WHERE products.id = product_images.product_id AND products.id IN ?
+			AND product_images.is_primary AND product_images.image_url <> products.image_url`, now, productIDs).Error
+	if err != nil {
+		return err
+	}
+
+	err = db.Exec(`UPDATE product_images SET is_primary = true, updated_at = ?
+		FROM products
+		WHERE products.id = product_images.product_id AND products.id IN ? AND products.image_url <> ''
+			AND NOT product_images.is_primary AND product_images.image_url = products.image_url`, now, productIDs).Error
+	if err != nil {
+		return err
+	}
+
+	return db.Exec(`INSERT INTO product_images (product_id, image_url, "position", is_primary, created_at, updated_at)
+		SELECT products.id, products.image_url,
+			(SELECT COUNT(*) FROM product_images WHERE product_images.product_id = products.id), true, ?, ?
+		FROM products
+		WHERE products.id IN ? AND products.image_url <> ''
+			AND NOT EXISTS (SELECT 1 FROM product_images
+				WHERE product_images.product_id = products.id AND product_images.image_url = products.image_url)`,
+		now, now, productIDs).Error
+}
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/binus-thesis-team/iam-service/utils"
+	"github.com/binus-thesis-team/product-service/internal/model"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const rowErrorsBatchSize = 500
+
+type productImportJobRepository struct {
+	db *gorm.DB
+}
+
+// NewProductImportJobRepository :nodoc:
+func NewProductImportJobRepository(db *gorm.DB) model.ProductImportJobRepository {
+	return &productImportJobRepository{
+		db: db,
+	}
+}
+
+func (r *productImportJobRepository) Create(ctx context.Context, job *model.ProductImportJob) error {
+	err := r.db.WithContext(ctx).Create(job).Error
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"ctx": utils.DumpIncomingContext(ctx),
+			"job": utils.Dump(job),
+		}).Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *productImportJobRepository) FindByID(ctx context.Context, id int64) (*model.ProductImportJob, error) {
+	job := &model.ProductImportJob{}
+	err := r.db.WithContext(ctx).Take(job, "id = ?", id).Error
+	switch err {
+	case nil:
+		return job, nil
+	case gorm.ErrRecordNotFound:
+		return nil, nil
+	default:
+		logrus.WithFields(logrus.Fields{
+			"ctx": utils.DumpIncomingContext(ctx),
+			"id":  id,
+		}).Error(err)
+		return nil, err
+	}
+}
+
+// Update saves every column of the job, zero values included
+func (r *productImportJobRepository) Update(ctx context.Context, job *model.ProductImportJob) error {
+	err := r.db.WithContext(ctx).Model(job).Select("*").Omit("id", "created_at").Updates(job).Error
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"ctx": utils.DumpIncomingContext(ctx),
+			"job": utils.Dump(job),
+		}).Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *productImportJobRepository) FailStale(ctx context.Context, staleBefore time.Time, message string) (int64, error) {
+	statuses := []model.ProductImportJobStatus{model.ProductImportJobStatusQueued, model.ProductImportJobStatusRunning}
+	result := r.db.WithContext(ctx).
+		Model(&model.ProductImportJob{}).
+		Where("status IN ? AND updated_at < ?", statuses, staleBefore).
+		Updates(map[string]any{
+			"status":        model.ProductImportJobStatusFailed,
+			"error_message": message,
+			"finished_at":   time.Now(),
+		})
+	if result.Error != nil {
+		logrus.WithFields(logrus.Fields{
+			"ctx":         utils.DumpIncomingContext(ctx),
+			"staleBefore": staleBefore,
+		}).Error(result.Error)
+		return 0, result.Error
+	}
+
+	return result.RowsAffected, nil
+}
+
+func (r *productImportJobRepository) CreateRowErrors(ctx context.Context, rowErrors []*model.ProductImportRowError) error {
+	if len(rowErrors) == 0 {
+		return nil
+	}
+
+	err := r.db.WithContext(ctx).CreateInBatches(rowErrors, rowErrorsBatchSize).Error
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"ctx":   utils.DumpIncomingContext(ctx),
+			"count": len(rowErrors),
+		}).Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *productImportJobRepository) FindRowErrorsByJobID(ctx context.Context, jobID int64) ([]*model.ProductImportRowError, error) {
+	var rowErrors []*model.ProductImportRowError
+	err := r.db.WithContext(ctx).
+		Where("job_id = ?", jobID).
+		Order("row_number ASC, id ASC").
+		Find(&rowErrors).Error
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"ctx":   utils.DumpIncomingContext(ctx),
+			"jobID": jobID,
+		}).Error(err)
+		return nil, err
+	}
+
+	return rowErrors, nil
+}
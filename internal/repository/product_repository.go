@@ -2,7 +2,12 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/binus-thesis-team/cacher"
 	"github.com/binus-thesis-team/iam-service/utils"
@@ -12,18 +17,52 @@ import (
 	"gorm.io/gorm"
 )
 
+const productCacheKeyPrefix = "cache:object:product:id:"
+
+const productBatchSize = 500
+
 type productRepository struct {
 	db           *gorm.DB
 	cacheManager cacher.CacheManager
+	localCache   *LocalCache
+
+	// txCacheKeys holds the cache keys to delete once the transaction is committed,
+	// only set on the repository given to a Transaction callback
+	txCacheKeys *pendingCacheKeys
+}
+
+type pendingCacheKeys struct {
+	mu   sync.Mutex
+	keys []string
 }
 
-func NewProductRepository(db *gorm.DB, cacheManager cacher.CacheManager) model.ProductRepository {
+func (p *pendingCacheKeys) add(keys ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keys = append(p.keys, keys...)
+}
+
+// NewProductRepository :nodoc:
+// cacheManager and localCache are optional, pass nil to disable them. A command writing
+// products must pass the caches of the server, or the replicas keep serving the products it changed.
+func NewProductRepository(db *gorm.DB, cacheManager cacher.CacheManager, localCache *LocalCache) model.ProductRepository {
 	return &productRepository{
 		db:           db,
 		cacheManager: cacheManager,
+		localCache:   localCache,
 	}
 }
 
+// NewProductCacheKeyByID returns the cache key of a product
+func NewProductCacheKeyByID(id int64) string {
+	return fmt.Sprintf("%s%d", productCacheKeyPrefix, id)
+}
+
+// NewProductCacheKeyPattern returns the cache key pattern matching the product ids of the given pattern
+func NewProductCacheKeyPattern(idPattern string) string {
+	return productCacheKeyPrefix + idPattern
+}
+
 func (u *productRepository) Create(ctx context.Context, requesterID int64, product *model.Product) error {
 	logger := logrus.WithFields(logrus.Fields{
 		"ctx":         utils.DumpIncomingContext(ctx),
@@ -31,21 +70,88 @@ func (u *productRepository) Create(ctx context.Context, requesterID int64, produ
 		"product":     utils.Dump(product),
 	})
 
-	err := u.db.WithContext(ctx).Create(product).Error
+	err := u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(product).Error; err != nil {
+			return err
+		}
+		return syncPrimaryImages(ctx, tx, []int64{product.ID})
+	})
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	if err := u.deleteCacheByKeys(u.newCacheKeyByID(product.ID)); err != nil {
+		logger.Error(err)
+	}
+
+	return nil
+}
+
+// CreateMany inserts the products with multi-row inserts of productBatchSize rows
+func (u *productRepository) CreateMany(ctx context.Context, requesterID int64, products []*model.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	logger := logrus.WithFields(logrus.Fields{
+		"ctx":         utils.DumpIncomingContext(ctx),
+		"requesterID": requesterID,
+		"count":       len(products),
+	})
+
+	err := u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.CreateInBatches(products, productBatchSize).Error; err != nil {
+			return err
+		}
+
+		ids := make([]int64, 0, len(products))
+		for _, product := range products {
+			ids = append(ids, product.ID)
+		}
+		return syncPrimaryImages(ctx, tx, ids)
+	})
 	if err != nil {
 		logger.Error(err)
 		return err
 	}
 
-	if err := u.cacheManager.DeleteByKeys([]string{
-		u.newCacheKeyByID(product.ID),
-	}); err != nil {
+	keys := make([]string, 0, len(products))
+	for _, product := range products {
+		keys = append(keys, u.newCacheKeyByID(product.ID))
+	}
+	if err := u.deleteCacheByKeys(keys...); err != nil {
 		logger.Error(err)
 	}
 
 	return nil
 }
 
+// Transaction runs fn in a single database transaction with a repository bound to it,
+// every change is rolled back when fn returns an error.
+// The cache of the changed products is deleted only after the commit.
+func (u *productRepository) Transaction(ctx context.Context, fn func(repo model.ProductRepository) error) error {
+	txRepo := &productRepository{
+		cacheManager: u.cacheManager,
+		localCache:   u.localCache,
+		txCacheKeys:  &pendingCacheKeys{},
+	}
+
+	err := u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		txRepo.db = tx
+		return fn(txRepo)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := u.deleteCacheByKeys(txRepo.txCacheKeys.keys...); err != nil {
+		logrus.WithField("ctx", utils.DumpIncomingContext(ctx)).Error(err)
+	}
+
+	return nil
+}
+
 func (u *productRepository) FindByID(ctx context.Context, id int64) (*model.Product, error) {
 	logger := logrus.WithFields(logrus.Fields{
 		"ctx": utils.DumpIncomingContext(ctx),
@@ -53,7 +159,14 @@ func (u *productRepository) FindByID(ctx context.Context, id int64) (*model.Prod
 	})
 
 	cacheKey := u.newCacheKeyByID(id)
-	if !config.DisableCaching() {
+	if !config.DisableCaching() && u.cacheManager != nil {
+		if cachedData, ok := u.localCache.Get(cacheKey); ok {
+			product := &model.Product{}
+			if err := json.Unmarshal(cachedData, product); err == nil {
+				return product, nil
+			}
+		}
+
 		reply, mu, err := findFromCacheByKey[*model.Product](u.cacheManager, cacheKey)
 		defer cacher.SafeUnlock(mu)
 		if err != nil {
@@ -62,6 +175,9 @@ func (u *productRepository) FindByID(ctx context.Context, id int64) (*model.Prod
 		}
 
 		if mu == nil {
+			if reply != nil {
+				u.localCache.Set(cacheKey, []byte(utils.Dump(reply)))
+			}
 			return reply, nil
 		}
 	}
@@ -71,59 +187,239 @@ func (u *productRepository) FindByID(ctx context.Context, id int64) (*model.Prod
 	switch err {
 	case nil:
 	case gorm.ErrRecordNotFound:
-		storeNil(u.cacheManager, cacheKey)
+		if u.cacheManager != nil {
+			storeNil(u.cacheManager, cacheKey)
+		}
 		return nil, nil
 	default:
 		logger.Error(err)
 		return nil, err
 	}
 
+	if u.cacheManager == nil {
+		return product, nil
+	}
+
 	err = u.cacheManager.StoreWithoutBlocking(cacher.NewItem(cacheKey, utils.Dump(product)))
 	if err != nil {
 		logger.Error(err)
 	}
+	u.localCache.Set(cacheKey, []byte(utils.Dump(product)))
 
 	return product, nil
 }
 
-func (u *productRepository) UpdateByID(ctx context.Context, requesterID int64, product *model.Product) error {
+func (u *productRepository) UpdateByID(ctx context.Context, requesterID int64, product *model.Product, expectedVersion int64) error {
 	logger := logrus.WithFields(logrus.Fields{
-		"ctx":         utils.DumpIncomingContext(ctx),
-		"requesterID": requesterID,
-		"product":     utils.Dump(product),
+		"ctx":             utils.DumpIncomingContext(ctx),
+		"requesterID":     requesterID,
+		"product":         utils.Dump(product),
+		"expectedVersion": expectedVersion,
 	})
 
-	err := u.db.WithContext(ctx).Updates(product).Debug().Error
-	if err != nil {
+	err := u.updateByIDAndVersion(ctx, u.db, product.ID, expectedVersion, map[string]any{
+		"name":        product.Name,
+		"price":       product.Price,
+		"stock":       product.Stock,
+		"description": product.Description,
+		"image_url":   product.ImageUrl,
+	})
+	if err != nil && !errors.Is(err, model.ErrVersionConflict) {
 		logger.Error(err)
-		return err
 	}
+	return err
+}
 
-	if err := u.cacheManager.DeleteByKeys([]string{
-		u.newCacheKeyByID(product.ID),
-	}); err != nil {
+// PatchByID updates the given columns only, a map is used since gorm skips the zero values of a struct
+func (u *productRepository) PatchByID(ctx context.Context, requesterID, id int64, columns map[string]any, expectedVersion int64) error {
+	logger := logrus.WithFields(logrus.Fields{
+		"ctx":             utils.DumpIncomingContext(ctx),
+		"requesterID":     requesterID,
+		"id":              id,
+		"columns":         utils.Dump(columns),
+		"expectedVersion": expectedVersion,
+	})
+
+	err := u.updateByIDAndVersion(ctx, u.db, id, expectedVersion, columns)
+	if err != nil && !errors.Is(err, model.ErrVersionConflict) {
 		logger.Error(err)
 	}
-
-	return nil
+	return err
 }
 
-func (u *productRepository) DeleteByID(ctx context.Context, id int64) error {
+// DeleteByID soft deletes the product, an already deleted one is left as is
+func (u *productRepository) DeleteByID(ctx context.Context, id, expectedVersion int64) error {
 	logger := logrus.WithFields(logrus.Fields{
-		"ctx": utils.DumpIncomingContext(ctx),
-		"id":  id,
+		"ctx":             utils.DumpIncomingContext(ctx),
+		"id":              id,
+		"expectedVersion": expectedVersion,
 	})
 
-	space := &model.Product{ID: id}
+	err := u.updateByIDAndVersion(ctx, u.db, id, expectedVersion, map[string]any{"deleted_at": time.Now()})
+	if err != nil && !errors.Is(err, model.ErrVersionConflict) {
+		logger.Error(err)
+	}
+	return err
+}
 
-	if err := u.db.WithContext(ctx).Delete(space).Error; err != nil {
+// RestoreByID clears the soft delete of the product
+func (u *productRepository) RestoreByID(ctx context.Context, id, expectedVersion int64) error {
+	logger := logrus.WithFields(logrus.Fields{
+		"ctx":             utils.DumpIncomingContext(ctx),
+		"id":              id,
+		"expectedVersion": expectedVersion,
+	})
+
+	err := u.updateByIDAndVersion(ctx, u.db.Unscoped(), id, expectedVersion, map[string]any{"deleted_at": nil})
+	if err != nil && !errors.Is(err, model.ErrVersionConflict) {
 		logger.Error(err)
+	}
+	return err
+}
+
+// updateByIDAndVersion updates the columns and bumps the version in a single statement, conditioned
+// on the expected version unless it's 0. No row updated means the version has changed meanwhile.
+// A changed image_url is synced to the gallery in the same transaction.
+func (u *productRepository) updateByIDAndVersion(ctx context.Context, db *gorm.DB, id, expectedVersion int64, columns map[string]any) error {
+	_, imageChanged := columns["image_url"]
+	columns["version"] = gorm.Expr("version + 1")
+
+	var rowsAffected int64
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(&model.Product{ID: id})
+		if expectedVersion > 0 {
+			query = query.Where("version = ?", expectedVersion)
+		}
+
+		result := query.Updates(columns)
+		if result.Error != nil {
+			return result.Error
+		}
+		rowsAffected = result.RowsAffected
+
+		if !imageChanged || rowsAffected == 0 {
+			return nil
+		}
+		return syncPrimaryImages(ctx, tx, []int64{id})
+	})
+	if err != nil {
 		return err
 	}
 
+	if err := u.deleteCacheByKeys(u.newCacheKeyByID(id)); err != nil {
+		logrus.WithField("id", id).Error(err)
+	}
+
+	if expectedVersion > 0 && rowsAffected == 0 {
+		return model.ErrVersionConflict
+	}
 	return nil
 }
 
+func (u *productRepository) FindAllByIDs(ctx context.Context, ids []int64) ([]*model.Product, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var products []*model.Product
+	err := u.db.WithContext(ctx).Unscoped().Where("id IN ?", ids).Find(&products).Error
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"ctx": utils.DumpIncomingContext(ctx),
+			"ids": ids,
+		}).Error(err)
+		return nil, err
+	}
+
+	return products, nil
+}
+
+// BulkMutate updates every product from a VALUES list, each row holds the columns set by its operation and
+// NULL for the others. A delete or a restore must flip the deletion state, the other operations need a live product.
+func (u *productRepository) BulkMutate(ctx context.Context, requesterID int64, operations []model.ProductBulkOperation) ([]int64, error) {
+	if len(operations) == 0 {
+		return nil, nil
+	}
+
+	logger := logrus.WithFields(logrus.Fields{
+		"ctx":         utils.DumpIncomingContext(ctx),
+		"requesterID": requesterID,
+		"count":       len(operations),
+	})
+
+	now := time.Now()
+	values := make([]string, 0, len(operations))
+	args := []any{now, now}
+	for _, operation := range operations {
+		values = append(values, "(?::int8, ?::int8, ?::text, ?::text, ?::float8, ?::int8, ?::text, ?::text, ?::bool, ?::text, ?::float8)")
+
+		fields := operation.Fields
+		if operation.Type == model.ProductBulkOperationSetStock {
+			fields = model.PatchProductRequest{Stock: &operation.Stock}
+		}
+
+		var externalID *string
+		if fields.ExternalID != nil {
+			externalID = model.NewExternalID(*fields.ExternalID)
+		}
+
+		args = append(args, operation.ProductID, operation.ExpectedVersion, string(operation.Type),
+			fields.Name, fields.Price, fields.Stock, fields.Description, fields.ImageUrl,
+			fields.ExternalID != nil, externalID, operation.PricePercent)
+	}
+
+	query := fmt.Sprintf(`UPDATE products SET
+			name = COALESCE(v.name, products.name),
+			price = CASE WHEN v.op = 'adjust_price'
+				THEN ROUND((products.price * (100 + v.price_percent) / 100)::numeric, 2)::float8
+				ELSE COALESCE(v.price, products.price) END,
+			stock = COALESCE(v.stock, products.stock),
+			description = COALESCE(v.description, products.description),
+			image_url = COALESCE(v.image_url, products.image_url),
+			external_id = CASE WHEN v.set_external_id THEN v.external_id ELSE products.external_id END,
+			deleted_at = CASE v.op WHEN 'delete' THEN ?::timestamptz WHEN 'restore' THEN NULL ELSE products.deleted_at END,
+			version = products.version + 1,
+			updated_at = ?
+		FROM (VALUES %s) AS v (id, expected_version, op, name, price, stock, description, image_url, set_external_id, external_id, price_percent)
+		WHERE products.id = v.id
+			AND (v.expected_version = 0 OR products.version = v.expected_version)
+			AND (products.deleted_at IS NULL) = (v.op <> 'restore')
+		RETURNING products.id`, strings.Join(values, ", "))
+
+	var mutatedIDs []int64
+	err := u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Raw(query, args...).Scan(&mutatedIDs).Error; err != nil {
+			return err
+		}
+
+		mutated := make(map[int64]bool, len(mutatedIDs))
+		for _, id := range mutatedIDs {
+			mutated[id] = true
+		}
+		var imageChangedIDs []int64
+		for _, operation := range operations {
+			if operation.Fields.ImageUrl != nil && mutated[operation.ProductID] {
+				imageChangedIDs = append(imageChangedIDs, operation.ProductID)
+			}
+		}
+		return syncPrimaryImages(ctx, tx, imageChangedIDs)
+	})
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	cacheKeys := make([]string, 0, len(mutatedIDs))
+	for _, id := range mutatedIDs {
+		cacheKeys = append(cacheKeys, u.newCacheKeyByID(id))
+	}
+	if err := u.deleteCacheByKeys(cacheKeys...); err != nil {
+		logger.Error(err)
+	}
+
+	return mutatedIDs, nil
+}
+
 func (u *productRepository) SearchByPage(ctx context.Context, searchCriteria model.ProductSearchCriteria) (ids []int64, count int64, err error) {
 	logger := logrus.WithFields(logrus.Fields{
 		"ctx":            utils.DumpIncomingContext(ctx),
@@ -176,6 +472,276 @@ func (u *productRepository) FindAllByQuery(ctx context.Context, query string, si
 	}
 }
 
+// FindAllProductsByQuery reads a page of products ordered by id straight from the database,
+// an empty query matches every product
+func (u *productRepository) FindAllProductsByQuery(ctx context.Context, query string, size, cursorAfter int64) ([]*model.Product, error) {
+	var scopes []func(*gorm.DB) *gorm.DB
+	scopes = append(scopes, withSize(size))
+	if query != "" {
+		scopes = append(scopes, u.scopeByProductNameAndDescription(query))
+	}
+
+	var products []*model.Product
+	err := u.db.WithContext(ctx).
+		Scopes(scopes...).
+		Where("id > ?", cursorAfter).
+		Order("id ASC").
+		Find(&products).Error
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"ctx":         utils.DumpIncomingContext(ctx),
+			"query":       query,
+			"size":        size,
+			"cursorAfter": cursorAfter,
+		}).Error(err)
+		return nil, err
+	}
+
+	return products, nil
+}
+
+func (u *productRepository) FindAllProductsChangedSince(ctx context.Context, since time.Time, size, cursorAfter int64) ([]*model.Product, error) {
+	var products []*model.Product
+	err := u.db.WithContext(ctx).
+		Unscoped().
+		Scopes(withSize(size)).
+		Where("(updated_at >= ? OR deleted_at >= ?) AND id > ?", since, since, cursorAfter).
+		Order("id ASC").
+		Find(&products).Error
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"ctx":         utils.DumpIncomingContext(ctx),
+			"since":       since,
+			"size":        size,
+			"cursorAfter": cursorAfter,
+		}).Error(err)
+		return nil, err
+	}
+
+	return products, nil
+}
+
+func (u *productRepository) FindIDsByLastUpdated(ctx context.Context, limit int64) ([]int64, error) {
+	var ids []int64
+	err := u.db.WithContext(ctx).
+		Model(model.Product{}).
+		Scopes(withSize(limit)).
+		Order("updated_at DESC").
+		Pluck("id", &ids).Error
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"ctx":   utils.DumpIncomingContext(ctx),
+			"limit": limit,
+		}).Error(err)
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// FindAllImageURLs reads the distinct image urls of the products and of their galleries after cursorAfter
+// in order, the soft deleted products included since they may be restored
+func (u *productRepository) FindAllImageURLs(ctx context.Context, size int64, cursorAfter string) ([]string, error) {
+	var imageURLs []string
+	err := u.db.WithContext(ctx).
+		Raw(`SELECT image_url FROM (SELECT image_url FROM products UNION SELECT image_url FROM product_images) AS images
+			WHERE image_url > ? ORDER BY image_url ASC LIMIT ?`, cursorAfter, size).
+		Scan(&imageURLs).Error
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"ctx":         utils.DumpIncomingContext(ctx),
+			"size":        size,
+			"cursorAfter": cursorAfter,
+		}).Error(err)
+		return nil, err
+	}
+
+	return imageURLs, nil
+}
+
+// IsAnyImagePublished reads straight from the database, so a deleted product hides its images at once
+func (u *productRepository) IsAnyImagePublished(ctx context.Context, imageURLs []string) (bool, error) {
+	if len(imageURLs) == 0 {
+		return false, nil
+	}
+
+	var published bool
+	err := u.db.WithContext(ctx).
+		Raw(`SELECT EXISTS (SELECT 1 FROM products WHERE image_url IN ? AND deleted_at IS NULL)
+			OR EXISTS (SELECT 1 FROM product_images JOIN products ON products.id = product_images.product_id
+				WHERE product_images.image_url IN ? AND products.deleted_at IS NULL)`, imageURLs, imageURLs).
+		Scan(&published).Error
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"ctx":       utils.DumpIncomingContext(ctx),
+			"imageURLs": imageURLs,
+		}).Error(err)
+		return false, err
+	}
+
+	return published, nil
+}
+
+// IsAnyImageReferenced reads straight from the database, the soft deleted products count since they may be restored
+func (u *productRepository) IsAnyImageReferenced(ctx context.Context, imageURLs []string) (bool, error) {
+	if len(imageURLs) == 0 {
+		return false, nil
+	}
+
+	var referenced bool
+	err := u.db.WithContext(ctx).
+		Raw(`SELECT EXISTS (SELECT 1 FROM products WHERE image_url IN ?)
+			OR EXISTS (SELECT 1 FROM product_images WHERE image_url IN ?)`, imageURLs, imageURLs).
+		Scan(&referenced).Error
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"ctx":       utils.DumpIncomingContext(ctx),
+			"imageURLs": imageURLs,
+		}).Error(err)
+		return false, err
+	}
+
+	return referenced, nil
+}
+
+// FindAllByExternalIDs reads the products matching the external ids straight from the database,
+// soft deleted ones included, without touching the cache
+func (u *productRepository) FindAllByExternalIDs(ctx context.Context, externalIDs []string) ([]*model.Product, error) {
+	if len(externalIDs) == 0 {
+		return nil, nil
+	}
+
+	var products []*model.Product
+	err := u.db.WithContext(ctx).
+		Unscoped().
+		Select("id", "external_id", "deleted_at").
+		Where("external_id IN ?", externalIDs).
+		Find(&products).Error
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"ctx":   utils.DumpIncomingContext(ctx),
+			"count": len(externalIDs),
+		}).Error(err)
+		return nil, err
+	}
+
+	return products, nil
+}
+
+// UpsertByExternalIDs inserts the products, or updates the existing ones with the same external id, in a single statement.
+// A soft deleted product matching the external id is left as is and out of the results, it must be restored first.
+func (u *productRepository) UpsertByExternalIDs(ctx context.Context, requesterID int64, products []*model.Product) ([]model.ProductUpsertResult, error) {
+	if len(products) == 0 {
+		return nil, nil
+	}
+
+	logger := logrus.WithFields(logrus.Fields{
+		"ctx":         utils.DumpIncomingContext(ctx),
+		"requesterID": requesterID,
+		"count":       len(products),
+	})
+
+	now := time.Now()
+	values := make([]string, 0, len(products))
+	args := make([]any, 0, len(products)*8)
+	for _, product := range products {
+		values = append(values, "(?, ?, ?, ?, ?, ?, ?, ?)")
+		args = append(args, product.ExternalID, product.Name, product.Price, product.Stock, product.Description, product.ImageUrl, now, now)
+	}
+
+	query := fmt.Sprintf(`INSERT INTO products (external_id, name, price, stock, description, image_url, created_at, updated_at)
+		VALUES %s
+		ON CONFLICT (external_id) WHERE external_id IS NOT NULL DO UPDATE SET
+			name = EXCLUDED.name,
+			price = EXCLUDED.price,
+			stock = EXCLUDED.stock,
+			description = EXCLUDED.description,
+			image_url = EXCLUDED.image_url,
+			version = products.version + 1,
+			updated_at = EXCLUDED.updated_at
+		WHERE products.deleted_at IS NULL
+		RETURNING id, external_id, (xmax = 0) AS created`, strings.Join(values, ", "))
+
+	var results []model.ProductUpsertResult
+	err := u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Raw(query, args...).Scan(&results).Error; err != nil {
+			return err
+		}
+
+		ids := make([]int64, 0, len(results))
+		for _, result := range results {
+			ids = append(ids, result.ID)
+		}
+		return syncPrimaryImages(ctx, tx, ids)
+	})
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	if err := u.deleteCacheByKeys(u.newCacheKeysByUpsertResults(results)...); err != nil {
+		logger.Error(err)
+	}
+
+	return results, nil
+}
+
+// UpdateByExternalIDs updates the products matching the external ids in a single statement,
+// the products without a match are left out of the results
+func (u *productRepository) UpdateByExternalIDs(ctx context.Context, requesterID int64, products []*model.Product) ([]model.ProductUpsertResult, error) {
+	if len(products) == 0 {
+		return nil, nil
+	}
+
+	logger := logrus.WithFields(logrus.Fields{
+		"ctx":         utils.DumpIncomingContext(ctx),
+		"requesterID": requesterID,
+		"count":       len(products),
+	})
+
+	values := make([]string, 0, len(products))
+	args := []any{time.Now()}
+	for _, product := range products {
+		values = append(values, "(?::text, ?::text, ?::float8, ?::int8, ?::text, ?::text)")
+		args = append(args, product.ExternalID, product.Name, product.Price, product.Stock, product.Description, product.ImageUrl)
+	}
+
+	query := fmt.Sprintf(`UPDATE products SET
+			name = v.name,
+			price = v.price,
+			stock = v.stock,
+			description = v.description,
+			image_url = v.image_url,
+			version = products.version + 1,
+			updated_at = ?
+		FROM (VALUES %s) AS v (external_id, name, price, stock, description, image_url)
+		WHERE products.external_id = v.external_id AND products.deleted_at IS NULL
+		RETURNING products.id, products.external_id`, strings.Join(values, ", "))
+
+	var results []model.ProductUpsertResult
+	err := u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Raw(query, args...).Scan(&results).Error; err != nil {
+			return err
+		}
+
+		ids := make([]int64, 0, len(results))
+		for _, result := range results {
+			ids = append(ids, result.ID)
+		}
+		return syncPrimaryImages(ctx, tx, ids)
+	})
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	if err := u.deleteCacheByKeys(u.newCacheKeysByUpsertResults(results)...); err != nil {
+		logger.Error(err)
+	}
+
+	return results, nil
+}
+
 func (u *productRepository) findAllIDsByCriteria(ctx context.Context, criteria model.ProductSearchCriteria) ([]int64, error) {
 	var scopes []func(*gorm.DB) *gorm.DB
 	scopes = append(scopes, scopeByPageAndLimit(criteria.Page, criteria.Size))
@@ -237,6 +803,36 @@ func (u *productRepository) scopeByProductNameAndDescription(query string) func(
 	}
 }
 
+// deleteCacheByKeys removes the keys from redis, then from the local cache of every replica
+func (u *productRepository) deleteCacheByKeys(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	if u.txCacheKeys != nil {
+		u.txCacheKeys.add(keys...)
+		return nil
+	}
+
+	var err error
+	if u.cacheManager != nil {
+		err = u.cacheManager.DeleteByKeys(keys)
+	}
+	if lcErr := u.localCache.Invalidate(keys...); lcErr != nil && err == nil {
+		err = lcErr
+	}
+
+	return err
+}
+
 func (u *productRepository) newCacheKeyByID(id int64) string {
-	return fmt.Sprintf("cache:object:product:id:%d", id)
+	return NewProductCacheKeyByID(id)
+}
+
+func (u *productRepository) newCacheKeysByUpsertResults(results []model.ProductUpsertResult) []string {
+	keys := make([]string, 0, len(results))
+	for _, result := range results {
+		keys = append(keys, u.newCacheKeyByID(result.ID))
+	}
+	return keys
 }
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/binus-thesis-team/product-service/internal/helper"
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/sirupsen/logrus"
+)
+
+const productRequestCounterKey = "cache:stats:product:requests"
+
+// RequestCounter tallies product reads in memory and periodically flushes them
+// into a redis sorted set, so the most requested products can be found later.
+// A nil *RequestCounter is valid and ignores every read. It implements model.ProductRequestCounter.
+type RequestCounter struct {
+	mu     sync.Mutex
+	counts map[int64]int64
+	pool   *redigo.Pool
+}
+
+// NewRequestCounter :nodoc:
+func NewRequestCounter(pool *redigo.Pool) *RequestCounter {
+	return &RequestCounter{
+		counts: make(map[int64]int64),
+		pool:   pool,
+	}
+}
+
+// Incr records a single read of the product
+func (r *RequestCounter) Incr(id int64) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	r.counts[id]++
+	r.mu.Unlock()
+}
+
+// Run flushes the tally every interval until the context is done, then flushes one last time
+func (r *RequestCounter) Run(ctx context.Context, interval time.Duration) {
+	if r == nil {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := r.flush(); err != nil {
+				logrus.Error(err)
+			}
+			return
+		case <-ticker.C:
+			if err := r.flush(); err != nil {
+				logrus.Error(err)
+			}
+		}
+	}
+}
+
+// FindMostRequestedIDs returns up to limit product ids ordered by their request count
+func (r *RequestCounter) FindMostRequestedIDs(limit int64) ([]int64, error) {
+	conn := r.pool.Get()
+	defer helper.WrapCloser(conn.Close)
+
+	return redigo.Int64s(conn.Do("ZREVRANGE", productRequestCounterKey, 0, limit-1))
+}
+
+func (r *RequestCounter) flush() error {
+	r.mu.Lock()
+	counts := r.counts
+	r.counts = make(map[int64]int64)
+	r.mu.Unlock()
+
+	if len(counts) == 0 {
+		return nil
+	}
+
+	conn := r.pool.Get()
+	defer helper.WrapCloser(conn.Close)
+
+	for id, count := range counts {
+		if err := conn.Send("ZINCRBY", productRequestCounterKey, count, id); err != nil {
+			return err
+		}
+	}
+
+	_, err := conn.Do("")
+	return err
+}
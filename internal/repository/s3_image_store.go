@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/binus-thesis-team/iam-service/utils"
+	"github.com/binus-thesis-team/product-service/internal/model"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/sirupsen/logrus"
+)
+
+// S3ImageStoreOptions are the options of an S3 compatible store, e.g. MinIO
+type S3ImageStoreOptions struct {
+	// Endpoint is the host of the service without the scheme, e.g. localhost:9000
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	UseSSL          bool
+	// BaseURL is the public url of the bucket, e.g. a CDN in front of it
+	BaseURL string
+}
+
+type s3ImageStore struct {
+	imageURLs
+	client *minio.Client
+	bucket string
+}
+
+// NewS3ImageStore stores the images as objects of the bucket, which must exist
+func NewS3ImageStore(ctx context.Context, opts S3ImageStoreOptions) (model.ImageStore, error) {
+	client, err := minio.New(opts.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(opts.AccessKeyID, opts.SecretAccessKey, ""),
+		Secure: opts.UseSSL,
+		Region: opts.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := client.BucketExists(ctx, opts.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("bucket %q doesn't exist", opts.Bucket)
+	}
+
+	return &s3ImageStore{
+		imageURLs: newImageURLs(opts.BaseURL),
+		client:    client,
+		bucket:    opts.Bucket,
+	}, nil
+}
+
+func (s *s3ImageStore) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	logger := logrus.WithFields(logrus.Fields{
+		"ctx": utils.DumpIncomingContext(ctx),
+		"key": key,
+	})
+
+	if err := checkImageKey(key); err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	_, err := s.client.PutObject(ctx, s.bucket, key, body, size, minio.PutObjectOptions{ContentType: contentType})
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *s3ImageStore) Get(ctx context.Context, key string) (*model.ImageObject, error) {
+	if err := checkImageKey(key); err != nil {
+		return nil, err
+	}
+
+	// GetObject is lazy, the missing object is only reported by its first read or its Stat
+	object, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, s.toImageError(ctx, key, err)
+	}
+
+	info, err := object.Stat()
+	if err != nil {
+		_ = object.Close()
+		return nil, s.toImageError(ctx, key, err)
+	}
+
+	return &model.ImageObject{
+		ReadSeekCloser: object,
+		Size:           info.Size,
+		ContentType:    info.ContentType,
+		ModTime:        info.LastModified,
+		ETag:           info.ETag,
+	}, nil
+}
+
+func (s *s3ImageStore) Delete(ctx context.Context, key string) error {
+	if err := checkImageKey(key); err != nil {
+		return err
+	}
+
+	// removing a missing object succeeds, it's checked first to report it
+	if _, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{}); err != nil {
+		return s.toImageError(ctx, key, err)
+	}
+
+	if err := s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return s.toImageError(ctx, key, err)
+	}
+
+	return nil
+}
+
+func (s *s3ImageStore) toImageError(ctx context.Context, key string, err error) error {
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return model.ErrImageNotFound
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"ctx":    utils.DumpIncomingContext(ctx),
+		"bucket": s.bucket,
+		"key":    key,
+	}).Error(err)
+	return err
+}
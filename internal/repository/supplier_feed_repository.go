@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/binus-thesis-team/iam-service/utils"
+	"github.com/binus-thesis-team/product-service/internal/model"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type supplierFeedRepository struct {
+	db *gorm.DB
+}
+
+// NewSupplierFeedRepository :nodoc:
+func NewSupplierFeedRepository(db *gorm.DB) model.SupplierFeedRepository {
+	return &supplierFeedRepository{
+		db: db,
+	}
+}
+
+func (r *supplierFeedRepository) Create(ctx context.Context, feed *model.SupplierFeed) error {
+	err := r.db.WithContext(ctx).Create(feed).Error
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"ctx":  utils.DumpIncomingContext(ctx),
+			"feed": utils.Dump(feed),
+		}).Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *supplierFeedRepository) FindByID(ctx context.Context, id int64) (*model.SupplierFeed, error) {
+	feed := &model.SupplierFeed{}
+	err := r.db.WithContext(ctx).Take(feed, "id = ?", id).Error
+	switch err {
+	case nil:
+		return feed, nil
+	case gorm.ErrRecordNotFound:
+		return nil, nil
+	default:
+		logrus.WithFields(logrus.Fields{
+			"ctx": utils.DumpIncomingContext(ctx),
+			"id":  id,
+		}).Error(err)
+		return nil, err
+	}
+}
+
+func (r *supplierFeedRepository) FindAll(ctx context.Context) ([]*model.SupplierFeed, error) {
+	var feeds []*model.SupplierFeed
+	err := r.db.WithContext(ctx).Order("id ASC").Find(&feeds).Error
+	if err != nil {
+		logrus.WithField("ctx", utils.DumpIncomingContext(ctx)).Error(err)
+		return nil, err
+	}
+
+	return feeds, nil
+}
+
+func (r *supplierFeedRepository) Update(ctx context.Context, feed *model.SupplierFeed, columns ...string) error {
+	err := r.db.WithContext(ctx).Model(feed).Select(columns).Updates(feed).Error
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"ctx":     utils.DumpIncomingContext(ctx),
+			"feed":    utils.Dump(feed),
+			"columns": columns,
+		}).Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *supplierFeedRepository) DeleteByID(ctx context.Context, id int64) error {
+	err := r.db.WithContext(ctx).Delete(&model.SupplierFeed{}, "id = ?", id).Error
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"ctx": utils.DumpIncomingContext(ctx),
+			"id":  id,
+		}).Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *supplierFeedRepository) ClaimDue(ctx context.Context, now time.Time) (*model.SupplierFeed, error) {
+	var claimed *model.SupplierFeed
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		feed := &model.SupplierFeed{}
+		// a feed locked by another scheduler is skipped instead of waited for
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("enabled AND next_run_at <= ?", now).
+			Order("next_run_at ASC").
+			Take(feed).Error
+		switch err {
+		case nil:
+		case gorm.ErrRecordNotFound:
+			return nil
+		default:
+			return err
+		}
+
+		feed.LastRunAt = &now
+		feed.NextRunAt = now.Add(feed.GetSchedule())
+		err = tx.Model(feed).Updates(map[string]any{
+			"last_run_at": feed.LastRunAt,
+			"next_run_at": feed.NextRunAt,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		claimed = feed
+		return nil
+	})
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"ctx": utils.DumpIncomingContext(ctx),
+			"now": now,
+		}).Error(err)
+		return nil, err
+	}
+
+	return claimed, nil
+}
+
+func (r *supplierFeedRepository) CreateRun(ctx context.Context, run *model.SupplierFeedRun) error {
+	err := r.db.WithContext(ctx).Create(run).Error
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"ctx": utils.DumpIncomingContext(ctx),
+			"run": utils.Dump(run),
+		}).Error(err)
+		return err
+	}
+
+	return nil
+}
+
+// UpdateRun saves every column of the run, zero values included
+func (r *supplierFeedRepository) UpdateRun(ctx context.Context, run *model.SupplierFeedRun) error {
+	err := r.db.WithContext(ctx).Model(run).Select("*").Omit("id", "feed_id", "started_at").Updates(run).Error
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"ctx": utils.DumpIncomingContext(ctx),
+			"run": utils.Dump(run),
+		}).Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *supplierFeedRepository) FailStaleRuns(ctx context.Context, staleBefore time.Time, message string) (int64, error) {
+	liveJobStatuses := []model.ProductImportJobStatus{model.ProductImportJobStatusQueued, model.ProductImportJobStatusRunning}
+	result := r.db.WithContext(ctx).
+		Model(&model.SupplierFeedRun{}).
+		Where("status = ? AND started_at < ?", model.SupplierFeedRunStatusRunning, staleBefore).
+		Where("import_job_id IS NULL OR import_job_id NOT IN (?)",
+			r.db.Model(&model.ProductImportJob{}).Select("id").Where("status IN ?", liveJobStatuses)).
+		Updates(map[string]any{
+			"status":        model.SupplierFeedRunStatusFailed,
+			"error_message": message,
+			"finished_at":   time.Now(),
+		})
+	if result.Error != nil {
+		logrus.WithFields(logrus.Fields{
+			"ctx":         utils.DumpIncomingContext(ctx),
+			"staleBefore": staleBefore,
+		}).Error(result.Error)
+		return 0, result.Error
+	}
+
+	return result.RowsAffected, nil
+}
+
+func (r *supplierFeedRepository) FindRunsByFeedID(ctx context.Context, feedID int64, limit int) ([]*model.SupplierFeedRun, error) {
+	var runs []*model.SupplierFeedRun
+	err := r.db.WithContext(ctx).
+		Where("feed_id = ?", feedID).
+		Order("started_at DESC, id DESC").
+		Limit(limit).
+		Find(&runs).Error
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"ctx":    utils.DumpIncomingContext(ctx),
+			"feedID": feedID,
+		}).Error(err)
+		return nil, err
+	}
+
+	return runs, nil
+}
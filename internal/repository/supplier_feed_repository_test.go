@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/binus-thesis-team/product-service/internal/model"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestSQLMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	t.Helper()
+
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = sqlDB.Close() })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, mock
+}
+
+func TestSupplierFeedRepository_ClaimDueReschedulesTheNextDueFeed(t *testing.T) {
+	db, mock := newTestSQLMockDB(t)
+	now := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	// a feed locked by another scheduler is skipped, the oldest due one is claimed
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "supplier_feeds" WHERE (enabled AND next_run_at <= $1) AND "supplier_feeds"."deleted_at" IS NULL ORDER BY next_run_at ASC LIMIT 1 FOR UPDATE SKIP LOCKED`)).
+		WithArgs(now).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "schedule", "enabled", "next_run_at"}).
+			AddRow(7, "supplier", "1h", true, now.Add(-time.Minute)))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "supplier_feeds" SET "last_run_at"=$1,"next_run_at"=$2,"updated_at"=$3 WHERE "supplier_feeds"."deleted_at" IS NULL AND "id" = $4`)).
+		WithArgs(now, now.Add(time.Hour), sqlmock.AnyArg(), 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	feed, err := NewSupplierFeedRepository(db).ClaimDue(context.Background(), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feed == nil || feed.ID != 7 {
+		t.Fatalf("ClaimDue() = %+v, want the feed 7", feed)
+	}
+	if !feed.NextRunAt.Equal(now.Add(time.Hour)) || feed.LastRunAt == nil || !feed.LastRunAt.Equal(now) {
+		t.Errorf("the claimed feed runs last at %v and next at %v", feed.LastRunAt, feed.NextRunAt)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSupplierFeedRepository_ClaimDueReturnsNilWithoutDueFeed(t *testing.T) {
+	db, mock := newTestSQLMockDB(t)
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`FOR UPDATE SKIP LOCKED`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectCommit()
+
+	feed, err := NewSupplierFeedRepository(db).ClaimDue(context.Background(), now)
+	if err != nil || feed != nil {
+		t.Errorf("ClaimDue() = %+v, %v, want nil", feed, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSupplierFeedRepository_UpdateSavesTheGivenColumnsOnly(t *testing.T) {
+	db, mock := newTestSQLMockDB(t)
+	feed := &model.SupplierFeed{ID: 7, Name: "supplier", Schedule: "1h", NextRunAt: time.Now()}
+
+	// the run schedule written by ClaimDue meanwhile isn't overwritten
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "supplier_feeds" SET "name"=$1,"schedule"=$2,"enabled"=$3,"updated_at"=$4 WHERE "supplier_feeds"."deleted_at" IS NULL AND "id" = $5`)).
+		WithArgs("supplier", "1h", false, sqlmock.AnyArg(), 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := NewSupplierFeedRepository(db).Update(context.Background(), feed, "name", "schedule", "enabled"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
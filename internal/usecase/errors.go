@@ -3,7 +3,21 @@ package usecase
 import "errors"
 
 var (
-	ErrNotFound         = errors.New("not found")
-	ErrDuplicateProduct = errors.New("product already exist")
-	ErrPermissionDenied = errors.New("permission denied")
+	ErrNotFound          = errors.New("not found")
+	ErrDuplicateProduct  = errors.New("product already exist")
+	ErrPermissionDenied  = errors.New("permission denied")
+	ErrInvalidImportFile = errors.New("invalid import file")
+	ErrInvalidImportMode = errors.New("invalid import mode, use create-only, update-only or upsert")
+	ErrImportQueueFull   = errors.New("too many import jobs are queued, retry later")
+
+	ErrSupplierFeedDisabled = errors.New("supplier feed is disabled")
+
+	// ErrImageInUse is the removal of an uploaded image still referenced by a product or its gallery
+	ErrImageInUse = errors.New("the image is used by a product")
+
+	// ErrVersionConflict is a conditional write based on a version of the product that isn't the current one
+	ErrVersionConflict = errors.New("version conflict, the product has been changed")
+
+	// ErrInvalidSignature is a signed url whose signature doesn't match or that has expired
+	ErrInvalidSignature = errors.New("invalid or expired signature")
 )
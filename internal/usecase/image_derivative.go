@@ -0,0 +1,152 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/jpeg"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/binus-thesis-team/iam-service/utils"
+	"github.com/binus-thesis-team/product-service/internal/config"
+	"github.com/binus-thesis-team/product-service/internal/model"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/image/draw"
+)
+
+const (
+	imageDerivativeQuality = 85
+
+	// imageDerivativesBatchSize is the number of image urls read at once by RegenerateImageDerivatives
+	imageDerivativesBatchSize = 100
+)
+
+// imageDerivativeKey returns the key of the derivative of size stored next to the image,
+// e.g. products/<id>_150.jpg for products/<id>.png
+func imageDerivativeKey(key string, size int) string {
+	return strings.TrimSuffix(key, path.Ext(key)) + "_" + strconv.Itoa(size) + ".jpg"
+}
+
+// newImageDerivative scales the image down to fit in a size x size square, a smaller image isn't scaled up.
+// The derivatives are JPEG, the transparent pixels are laid on white.
+func newImageDerivative(img image.Image, size int) ([]byte, error) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	switch {
+	case max(width, height) <= size:
+	case width >= height:
+		width, height = size, max(1, height*size/width)
+	default:
+		width, height = max(1, width*size/height), size
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: imageDerivativeQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// putImageDerivatives stores the derivatives of every configured size of the image stored at key
+func (u *productUsecase) putImageDerivatives(ctx context.Context, key string, img image.Image) error {
+	for _, size := range config.ImageDerivativeSizes() {
+		data, err := newImageDerivative(img, size)
+		if err != nil {
+			return err
+		}
+
+		err = u.imageStore.Put(ctx, imageDerivativeKey(key, size), bytes.NewReader(data), int64(len(data)), mimeJPEG)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteImageDerivatives deletes the derivatives of the image stored at key, the missing ones are ignored
+func (u *productUsecase) deleteImageDerivatives(ctx context.Context, key string) error {
+	for _, size := range config.ImageDerivativeSizes() {
+		err := u.imageStore.Delete(ctx, imageDerivativeKey(key, size))
+		if err != nil && !errors.Is(err, model.ErrImageNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// imageDerivativeURLs returns the urls of the derivatives of an uploaded image by their size,
+// nil for an image that wasn't uploaded
+func (u *productUsecase) imageDerivativeURLs(imageURL string) map[string]string {
+	if u.imageStore == nil {
+		return nil
+	}
+
+	key, ok := u.uploadedImageKey(imageURL)
+	if !ok {
+		return nil
+	}
+
+	urls := make(map[string]string, len(config.ImageDerivativeSizes()))
+	for _, size := range config.ImageDerivativeSizes() {
+		urls[strconv.Itoa(size)] = u.imageStore.URL(imageDerivativeKey(key, size))
+	}
+	return urls
+}
+
+// RegenerateImageDerivatives stores again the derivatives of every uploaded image of the products, e.g. once
+// the sizes are changed. An image failing doesn't stop the others, it's counted in the report.
+func (u *productUsecase) RegenerateImageDerivatives(ctx context.Context) (*model.ImageDerivativesReport, error) {
+	logger := logrus.WithField("ctx", utils.DumpIncomingContext(ctx))
+
+	report := &model.ImageDerivativesReport{}
+	cursorAfter := ""
+	for {
+		imageURLs, err := u.productRepository.FindAllImageURLs(ctx, imageDerivativesBatchSize, cursorAfter)
+		if err != nil {
+			logger.Error(err)
+			return report, err
+		}
+
+		for _, imageURL := range imageURLs {
+			key, ok := u.uploadedImageKey(imageURL)
+			if !ok {
+				report.Skipped++
+				continue
+			}
+
+			if err := u.regenerateImageDerivatives(ctx, key); err != nil {
+				logger.WithField("key", key).Error(err)
+				report.Failed++
+				continue
+			}
+			report.Regenerated++
+		}
+
+		if len(imageURLs) < imageDerivativesBatchSize {
+			return report, nil
+		}
+		cursorAfter = imageURLs[len(imageURLs)-1]
+	}
+}
+
+func (u *productUsecase) regenerateImageDerivatives(ctx context.Context, key string) error {
+	src, err := u.imageStore.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	img, err := readUploadedImage(src, config.ImageUploadMaxSizeBytes(), config.ImageUploadMaxWidth(), config.ImageUploadMaxHeight())
+	if err != nil {
+		return err
+	}
+
+	return u.putImageDerivatives(ctx, key, img.image)
+}
@@ -0,0 +1,216 @@
+package usecase
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+)
+
+var errMalformedImage = errors.New("malformed image")
+
+// exifHeader prefixes the EXIF of a JPEG APP1 segment, and of some WebP EXIF chunks
+var exifHeader = []byte("Exif\x00\x00")
+
+// stripImageMetadata removes the metadata of an image without re-encoding it, e.g. the EXIF with the GPS
+// position of the camera. The EXIF is replaced by one holding only its orientation, so the image is still
+// displayed upright, the orientation is returned to lay the decoded pixels the same way.
+func stripImageMetadata(contentType string, data []byte) ([]byte, imageOrientation, error) {
+	switch contentType {
+	case mimeJPEG:
+		return stripJPEGMetadata(data)
+	case mimePNG:
+		return stripPNGMetadata(data)
+	case mimeWebP:
+		return stripWebPMetadata(data)
+	default:
+		return nil, 0, errMalformedImage
+	}
+}
+
+// stripJPEGMetadata drops the APPn segments but JFIF (APP0), the ICC profile (APP2) and Adobe (APP14)
+// which the colors depend on, and the comments. The segments after the start of the image data are kept as is.
+func stripJPEGMetadata(data []byte) ([]byte, imageOrientation, error) {
+	const (
+		markerSOI  = 0xD8
+		markerSOS  = 0xDA
+		markerAPP1 = 0xE1
+		markerCOM  = 0xFE
+	)
+
+	if len(data) < 2 || data[0] != 0xFF || data[1] != markerSOI {
+		return nil, 0, errMalformedImage
+	}
+
+	orientation := imageOrientationNormal
+
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:2]...)
+	for i := 2; ; {
+		if i+2 > len(data) || data[i] != 0xFF {
+			return nil, 0, errMalformedImage
+		}
+
+		marker := data[i+1]
+		switch {
+		case marker == 0xFF:
+			// fill byte
+			i++
+			continue
+		case marker == markerSOS:
+			return append(out, data[i:]...), orientation, nil
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7):
+			// standalone markers, without a length
+			out = append(out, data[i:i+2]...)
+			i += 2
+			continue
+		}
+
+		if i+4 > len(data) {
+			return nil, 0, errMalformedImage
+		}
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+		if end < i+4 || end > len(data) {
+			return nil, 0, errMalformedImage
+		}
+
+		payload := data[i+4 : end]
+		if marker == markerAPP1 && orientation == imageOrientationNormal && bytes.HasPrefix(payload, exifHeader) {
+			orientation = readEXIFOrientation(payload[len(exifHeader):])
+			if orientation != imageOrientationNormal {
+				exif := append(append([]byte{}, exifHeader...), newOrientationEXIF(orientation)...)
+				out = append(out, 0xFF, markerAPP1)
+				out = binary.BigEndian.AppendUint16(out, uint16(2+len(exif)))
+				out = append(out, exif...)
+			}
+		}
+
+		isMetadata := marker == markerCOM || (marker >= 0xE1 && marker <= 0xEF && marker != 0xE2 && marker != 0xEE)
+		if !isMetadata {
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+}
+
+// pngMetadataChunks are the chunks of text, time and EXIF
+var pngMetadataChunks = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"tIME": true,
+}
+
+func stripPNGMetadata(data []byte) ([]byte, imageOrientation, error) {
+	signature := []byte("\x89PNG\r\n\x1a\n")
+	if !bytes.HasPrefix(data, signature) {
+		return nil, 0, errMalformedImage
+	}
+
+	orientation := imageOrientationNormal
+
+	out := make([]byte, 0, len(data))
+	out = append(out, signature...)
+	for i := len(signature); ; {
+		// length, type, data then crc
+		if i+8 > len(data) {
+			return nil, 0, errMalformedImage
+		}
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		chunkType := string(data[i+4 : i+8])
+		end := i + 12 + length
+		if length < 0 || end < i || end > len(data) {
+			return nil, 0, errMalformedImage
+		}
+
+		if chunkType == "eXIf" && orientation == imageOrientationNormal {
+			orientation = readEXIFOrientation(data[i+8 : end-4])
+			if orientation != imageOrientationNormal {
+				out = appendPNGChunk(out, chunkType, newOrientationEXIF(orientation))
+			}
+		}
+
+		if !pngMetadataChunks[chunkType] {
+			out = append(out, data[i:end]...)
+		}
+		if chunkType == "IEND" {
+			return out, orientation, nil
+		}
+		i = end
+	}
+}
+
+// stripWebPMetadata drops the EXIF and XMP chunks and their flags of the VP8X chunk
+func stripWebPMetadata(data []byte) ([]byte, imageOrientation, error) {
+	const (
+		vp8xFlagXMP  = 0x04
+		vp8xFlagEXIF = 0x08
+	)
+
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, 0, errMalformedImage
+	}
+	riffEnd := 8 + int(binary.LittleEndian.Uint32(data[4:]))
+	if riffEnd < 12 || riffEnd > len(data) {
+		return nil, 0, errMalformedImage
+	}
+
+	orientation := imageOrientationNormal
+	// the EXIF chunk comes after the VP8X one, its flag is set once the chunks are read
+	vp8xFlags := -1
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:12]...)
+	for i := 12; i < riffEnd; {
+		if i+8 > riffEnd {
+			return nil, 0, errMalformedImage
+		}
+		fourCC := string(data[i : i+4])
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		// the chunks are padded to an even size
+		end := i + 8 + size + size%2
+		if size < 0 || end < i || end > riffEnd {
+			return nil, 0, errMalformedImage
+		}
+
+		switch fourCC {
+		case "EXIF":
+			if orientation != imageOrientationNormal {
+				break
+			}
+			orientation = readEXIFOrientation(bytes.TrimPrefix(data[i+8:i+8+size], exifHeader))
+			if orientation != imageOrientationNormal {
+				exif := newOrientationEXIF(orientation)
+				out = append(out, "EXIF"...)
+				out = binary.LittleEndian.AppendUint32(out, uint32(len(exif)))
+				out = append(out, exif...)
+			}
+		case "XMP ":
+		case "VP8X":
+			start := len(out)
+			out = append(out, data[i:end]...)
+			if size > 0 {
+				vp8xFlags = start + 8
+				out[vp8xFlags] &^= vp8xFlagEXIF | vp8xFlagXMP
+			}
+		default:
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+
+	if vp8xFlags >= 0 && orientation != imageOrientationNormal {
+		out[vp8xFlags] |= vp8xFlagEXIF
+	}
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out, orientation, nil
+}
+
+// appendPNGChunk appends a chunk with its length and crc
+func appendPNGChunk(out []byte, chunkType string, chunkData []byte) []byte {
+	out = binary.BigEndian.AppendUint32(out, uint32(len(chunkData)))
+	start := len(out)
+	out = append(out, chunkType...)
+	out = append(out, chunkData...)
+	return binary.BigEndian.AppendUint32(out, crc32.ChecksumIEEE(out[start:]))
+}
@@ -0,0 +1,323 @@
+package usecase
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+const (
+	testXMP     = "http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta>" + testPrivateMetadata + "</x:xmpmeta>"
+	testComment = "shot at " + testPrivateMetadata
+)
+
+func newTestJPEGSegment(marker byte, payload []byte) []byte {
+	segment := []byte{0xFF, marker}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(2+len(payload)))
+	return append(segment, payload...)
+}
+
+// newTestJPEG returns a JPEG whose segments are inserted right after the start of image
+func newTestJPEG(t *testing.T, segments ...[]byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4)), nil); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+
+	data := append([]byte{}, encoded[:2]...)
+	for _, segment := range segments {
+		data = append(data, segment...)
+	}
+	return append(data, encoded[2:]...)
+}
+
+// jpegSegments returns the payloads of the segments before the image data by their marker
+func jpegSegments(t *testing.T, data []byte) map[byte][][]byte {
+	t.Helper()
+
+	segments := make(map[byte][][]byte)
+	for i := 2; data[i+1] != 0xDA; {
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+		segments[data[i+1]] = append(segments[data[i+1]], data[i+4:end])
+		i = end
+	}
+	return segments
+}
+
+func TestStripJPEGMetadata(t *testing.T) {
+	iccProfile := newTestJPEGSegment(0xE2, []byte("ICC_PROFILE\x00\x01\x01profile"))
+
+	for _, orientation := range []imageOrientation{imageOrientationNormal, 6} {
+		data := newTestJPEG(t,
+			newTestJPEGSegment(0xE1, append(append([]byte{}, exifHeader...), newTestEXIF(binary.LittleEndian, uint16(orientation))...)),
+			newTestJPEGSegment(0xE1, []byte(testXMP)),
+			iccProfile,
+			// IPTC
+			newTestJPEGSegment(0xED, []byte("Photoshop 3.0\x00"+testPrivateMetadata)),
+			newTestJPEGSegment(0xFE, []byte(testComment)),
+		)
+
+		stripped, gotOrientation, err := stripImageMetadata(mimeJPEG, data)
+		if err != nil {
+			t.Fatalf("orientation %d: %v", orientation, err)
+		}
+		if gotOrientation != orientation {
+			t.Errorf("orientation %d: stripImageMetadata() returned the orientation %d", orientation, gotOrientation)
+		}
+		if bytes.Contains(stripped, []byte(testPrivateMetadata)) {
+			t.Errorf("orientation %d: the metadata is still in the image", orientation)
+		}
+		if _, err := jpeg.Decode(bytes.NewReader(stripped)); err != nil {
+			t.Errorf("orientation %d: the stripped image can't be decoded: %v", orientation, err)
+		}
+
+		segments := jpegSegments(t, stripped)
+		if len(segments[0xE2]) != 1 || !bytes.Equal(segments[0xE2][0], iccProfile[4:]) {
+			t.Errorf("orientation %d: the ICC profile isn't kept", orientation)
+		}
+		if len(segments[0xED]) != 0 || len(segments[0xFE]) != 0 {
+			t.Errorf("orientation %d: the IPTC or the comment is kept", orientation)
+		}
+
+		// the EXIF holds the orientation only, and is dropped with the normal one
+		var wantEXIF [][]byte
+		if orientation != imageOrientationNormal {
+			wantEXIF = [][]byte{append(append([]byte{}, exifHeader...), newOrientationEXIF(orientation)...)}
+		}
+		if len(segments[0xE1]) != len(wantEXIF) || (len(wantEXIF) > 0 && !bytes.Equal(segments[0xE1][0], wantEXIF[0])) {
+			t.Errorf("orientation %d: the APP1 segments are %q, want %q", orientation, segments[0xE1], wantEXIF)
+		}
+	}
+}
+
+// newTestPNG returns a PNG whose chunks are inserted right after the IHDR chunk
+func newTestPNG(t *testing.T, chunks ...[]byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+
+	// the signature then the IHDR chunk of 13 bytes
+	ihdrEnd := 8 + 12 + 13
+	data := append([]byte{}, encoded[:ihdrEnd]...)
+	for _, chunk := range chunks {
+		data = append(data, chunk...)
+	}
+	return append(data, encoded[ihdrEnd:]...)
+}
+
+// pngChunks returns the data of the chunks by their type
+func pngChunks(data []byte) map[string][][]byte {
+	chunks := make(map[string][][]byte)
+	for i := 8; i < len(data); {
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		chunkType := string(data[i+4 : i+8])
+		chunks[chunkType] = append(chunks[chunkType], data[i+8:i+8+length])
+		i += 12 + length
+	}
+	return chunks
+}
+
+func TestStripPNGMetadata(t *testing.T) {
+	physicalSize := appendPNGChunk(nil, "pHYs", []byte("\x00\x00\x0b\x13\x00\x00\x0b\x13\x01"))
+
+	for _, orientation := range []imageOrientation{imageOrientationNormal, 3} {
+		data := newTestPNG(t,
+			physicalSize,
+			appendPNGChunk(nil, "eXIf", newTestEXIF(binary.BigEndian, uint16(orientation))),
+			appendPNGChunk(nil, "tEXt", []byte("Comment\x00"+testComment)),
+			appendPNGChunk(nil, "iTXt", []byte("XML:com.adobe.xmp\x00\x00\x00\x00\x00"+testXMP)),
+			appendPNGChunk(nil, "zTXt", []byte("Author\x00\x00"+testPrivateMetadata)),
+			appendPNGChunk(nil, "tIME", []byte("\x07\xea\x0a\x13\x08\x00\x00")),
+		)
+
+		stripped, gotOrientation, err := stripImageMetadata(mimePNG, data)
+		if err != nil {
+			t.Fatalf("orientation %d: %v", orientation, err)
+		}
+		if gotOrientation != orientation {
+			t.Errorf("orientation %d: stripImageMetadata() returned the orientation %d", orientation, gotOrientation)
+		}
+		if bytes.Contains(stripped, []byte(testPrivateMetadata)) {
+			t.Errorf("orientation %d: the metadata is still in the image", orientation)
+		}
+		// the decoder checks the crc of every chunk
+		if _, err := png.Decode(bytes.NewReader(stripped)); err != nil {
+			t.Errorf("orientation %d: the stripped image can't be decoded: %v", orientation, err)
+		}
+
+		chunks := pngChunks(stripped)
+		for _, chunkType := range []string{"tEXt", "iTXt", "zTXt", "tIME"} {
+			if len(chunks[chunkType]) != 0 {
+				t.Errorf("orientation %d: the %s chunk is kept", orientation, chunkType)
+			}
+		}
+		if len(chunks["pHYs"]) != 1 {
+			t.Errorf("orientation %d: the pHYs chunk isn't kept", orientation)
+		}
+
+		var wantEXIF [][]byte
+		if orientation != imageOrientationNormal {
+			wantEXIF = [][]byte{newOrientationEXIF(orientation)}
+		}
+		if len(chunks["eXIf"]) != len(wantEXIF) || (len(wantEXIF) > 0 && !bytes.Equal(chunks["eXIf"][0], wantEXIF[0])) {
+			t.Errorf("orientation %d: the eXIf chunks are %q, want %q", orientation, chunks["eXIf"], wantEXIF)
+		}
+	}
+}
+
+const (
+	testVP8XFlagAlpha = 0x10
+	testVP8XFlagEXIF  = 0x08
+	testVP8XFlagXMP   = 0x04
+)
+
+func newTestWebPChunk(fourCC string, payload []byte) []byte {
+	chunk := append([]byte(fourCC), binary.LittleEndian.AppendUint32(nil, uint32(len(payload)))...)
+	chunk = append(chunk, payload...)
+	if len(payload)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+func newTestWebP(chunks ...[]byte) []byte {
+	var body []byte
+	for _, chunk := range chunks {
+		body = append(body, chunk...)
+	}
+	data := append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(4+len(body)))...)
+	data = append(data, "WEBP"...)
+	return append(data, body...)
+}
+
+// webpChunks returns the payloads of the chunks by their fourCC
+func webpChunks(t *testing.T, data []byte) map[string][][]byte {
+	t.Helper()
+
+	if riffSize := int(binary.LittleEndian.Uint32(data[4:])); riffSize != len(data)-8 {
+		t.Errorf("the RIFF size is %d, want %d", riffSize, len(data)-8)
+	}
+
+	chunks := make(map[string][][]byte)
+	for i := 12; i < len(data); {
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		chunks[string(data[i:i+4])] = append(chunks[string(data[i:i+4])], data[i+8:i+8+size])
+		i += 8 + size + size%2
+	}
+	return chunks
+}
+
+func TestStripWebPMetadata(t *testing.T) {
+	// a canvas of 1x1 with an alpha channel, an EXIF and an XMP chunk
+	vp8x := []byte{testVP8XFlagAlpha | testVP8XFlagEXIF | testVP8XFlagXMP, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	// the bitstream isn't decoded, an odd size checks the padding is kept
+	bitstream := []byte("\x2f\x00\x00\x00\x00")
+
+	tests := []struct {
+		name        string
+		exif        []byte
+		orientation imageOrientation
+	}{
+		{name: "normal", exif: newTestEXIF(binary.LittleEndian, 1), orientation: imageOrientationNormal},
+		{name: "rotated", exif: newTestEXIF(binary.LittleEndian, 6), orientation: 6},
+		{name: "rotated with the EXIF header", exif: append(append([]byte{}, exifHeader...), newTestEXIF(binary.BigEndian, 8)...), orientation: 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := newTestWebP(
+				newTestWebPChunk("VP8X", vp8x),
+				newTestWebPChunk("VP8L", bitstream),
+				newTestWebPChunk("EXIF", tt.exif),
+				newTestWebPChunk("XMP ", []byte(testXMP)),
+			)
+
+			stripped, orientation, err := stripImageMetadata(mimeWebP, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if orientation != tt.orientation {
+				t.Errorf("stripImageMetadata() returned the orientation %d, want %d", orientation, tt.orientation)
+			}
+			if bytes.Contains(stripped, []byte(testPrivateMetadata)) {
+				t.Error("the metadata is still in the image")
+			}
+
+			chunks := webpChunks(t, stripped)
+			if len(chunks["XMP "]) != 0 {
+				t.Error("the XMP chunk is kept")
+			}
+			if len(chunks["VP8L"]) != 1 || !bytes.Equal(chunks["VP8L"][0], bitstream) {
+				t.Error("the bitstream isn't kept as is")
+			}
+
+			// the flags tell the chunks left, the other ones are kept
+			wantFlags := byte(testVP8XFlagAlpha)
+			var wantEXIF [][]byte
+			if tt.orientation != imageOrientationNormal {
+				wantFlags |= testVP8XFlagEXIF
+				wantEXIF = [][]byte{newOrientationEXIF(tt.orientation)}
+			}
+			if len(chunks["VP8X"]) != 1 || chunks["VP8X"][0][0] != wantFlags {
+				t.Errorf("the VP8X chunks are %x, want the flags %x", chunks["VP8X"], wantFlags)
+			}
+			if len(chunks["EXIF"]) != len(wantEXIF) || (len(wantEXIF) > 0 && !bytes.Equal(chunks["EXIF"][0], wantEXIF[0])) {
+				t.Errorf("the EXIF chunks are %q, want %q", chunks["EXIF"], wantEXIF)
+			}
+		})
+	}
+}
+
+func TestStripImageMetadata_RejectsTheMalformedImages(t *testing.T) {
+	validJPEG := newTestJPEG(t)
+	validPNG := newTestPNG(t)
+	validWebP := newTestWebP(newTestWebPChunk("VP8L", []byte("\x2f\x00\x00\x00\x00")))
+
+	// the APP1 segment claims more bytes than the file has
+	oversizedJPEG := append(append([]byte{}, validJPEG[:2]...), 0xFF, 0xE1, 0xFF, 0xFF, 'E', 'x')
+	// a segment length can't be shorter than the length itself
+	undersizedJPEG := append(append([]byte{}, validJPEG[:2]...), 0xFF, 0xE1, 0x00, 0x01)
+	oversizedPNG := append(append([]byte{}, validPNG[:8]...), 0xFF, 0xFF, 0xFF, 0xFF, 't', 'E', 'X', 't')
+	oversizedWebPChunk := append(append([]byte{}, validWebP...), "EXIF\xff\xff\x00\x00"...)
+	binary.LittleEndian.PutUint32(oversizedWebPChunk[4:], uint32(len(oversizedWebPChunk)-8))
+	undersizedRIFF := append([]byte{}, validWebP...)
+	binary.LittleEndian.PutUint32(undersizedRIFF[4:], 2)
+
+	tests := []struct {
+		name        string
+		contentType string
+		data        []byte
+	}{
+		{name: "jpeg without the start of image", contentType: mimeJPEG, data: validJPEG[2:]},
+		{name: "jpeg segment past the end", contentType: mimeJPEG, data: oversizedJPEG},
+		{name: "jpeg segment shorter than its length", contentType: mimeJPEG, data: undersizedJPEG},
+		{name: "jpeg truncated before the image data", contentType: mimeJPEG, data: validJPEG[:2]},
+		{name: "jpeg truncated within a length", contentType: mimeJPEG, data: append(append([]byte{}, validJPEG[:2]...), 0xFF, 0xE1, 0x00)},
+		{name: "png without the signature", contentType: mimePNG, data: validPNG[1:]},
+		{name: "png chunk past the end", contentType: mimePNG, data: oversizedPNG},
+		{name: "png truncated before IEND", contentType: mimePNG, data: validPNG[:len(validPNG)-12]},
+		{name: "webp without the RIFF header", contentType: mimeWebP, data: validWebP[4:]},
+		{name: "webp RIFF past the end", contentType: mimeWebP, data: validWebP[:len(validWebP)-2]},
+		{name: "webp RIFF shorter than its header", contentType: mimeWebP, data: undersizedRIFF},
+		{name: "webp chunk past the RIFF", contentType: mimeWebP, data: oversizedWebPChunk},
+		{name: "webp truncated chunk header", contentType: mimeWebP, data: newTestWebP([]byte("VP8L\x05"))},
+		{name: "unsupported format", contentType: "image/gif", data: []byte("GIF89a")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := stripImageMetadata(tt.contentType, tt.data); !errors.Is(err, errMalformedImage) {
+				t.Errorf("stripImageMetadata() = %v, want %v", err, errMalformedImage)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package usecase
+
+import (
+	"encoding/binary"
+	"image"
+
+	"golang.org/x/image/draw"
+)
+
+// imageOrientation is the EXIF orientation of an image, the transformation displaying its pixels upright
+type imageOrientation uint16
+
+const (
+	imageOrientationNormal imageOrientation = 1
+	// imageOrientationRotate270 is the last of the 8 orientations, rotated 90 degrees counterclockwise
+	imageOrientationRotate270 imageOrientation = 8
+
+	exifTagOrientation = 0x0112
+	exifTypeShort      = 3
+)
+
+// readEXIFOrientation reads the orientation tag of the first IFD of an EXIF TIFF structure,
+// a missing or invalid orientation is the normal one
+func readEXIFOrientation(tiff []byte) imageOrientation {
+	if len(tiff) < 8 {
+		return imageOrientationNormal
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:4]) {
+	case "II*\x00":
+		order = binary.LittleEndian
+	case "MM\x00*":
+		order = binary.BigEndian
+	default:
+		return imageOrientationNormal
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return imageOrientationNormal
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:]) != exifTagOrientation || order.Uint16(tiff[entry+2:]) != exifTypeShort {
+			continue
+		}
+
+		orientation := imageOrientation(order.Uint16(tiff[entry+8:]))
+		if orientation < imageOrientationNormal || orientation > imageOrientationRotate270 {
+			return imageOrientationNormal
+		}
+		return orientation
+	}
+	return imageOrientationNormal
+}
+
+// newOrientationEXIF returns an EXIF TIFF structure holding the orientation only
+func newOrientationEXIF(orientation imageOrientation) []byte {
+	tiff := []byte("MM\x00*")
+	tiff = binary.BigEndian.AppendUint32(tiff, 8)
+	// a single entry, its short value left aligned in the value field, then no next IFD
+	tiff = binary.BigEndian.AppendUint16(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, exifTagOrientation)
+	tiff = binary.BigEndian.AppendUint16(tiff, exifTypeShort)
+	tiff = binary.BigEndian.AppendUint32(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, uint16(orientation))
+	tiff = binary.BigEndian.AppendUint16(tiff, 0)
+	return binary.BigEndian.AppendUint32(tiff, 0)
+}
+
+// orientImage lays the pixels of the image upright, the way a viewer displays it with its orientation
+func orientImage(img image.Image, orientation imageOrientation) image.Image {
+	if orientation <= imageOrientationNormal || orientation > imageOrientationRotate270 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	src := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	// the orientations from 5 on swap the width and the height
+	dstW, dstH := w, h
+	if orientation >= 5 {
+		dstW, dstH = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+
+	for y := 0; y < dstH; y++ {
+		for x := 0; x < dstW; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // flipped horizontally
+				sx, sy = w-1-x, y
+			case 3: // rotated 180 degrees
+				sx, sy = w-1-x, h-1-y
+			case 4: // flipped vertically
+				sx, sy = x, h-1-y
+			case 5: // transposed
+				sx, sy = y, x
+			case 6: // rotated 90 degrees clockwise
+				sx, sy = y, h-1-x
+			case 7: // transversed
+				sx, sy = w-1-y, h-1-x
+			default: // rotated 90 degrees counterclockwise
+				sx, sy = w-1-y, x
+			}
+			copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], src.Pix[src.PixOffset(sx, sy):src.PixOffset(sx, sy)+4])
+		}
+	}
+	return dst
+}
@@ -0,0 +1,133 @@
+package usecase
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// testPrivateMetadata stands for the metadata an upload must not leak, like the GPS position of the camera
+const testPrivateMetadata = "GPS 52.3676N 4.9041E"
+
+// newTestEXIF returns an EXIF TIFF structure with the orientation and a description holding testPrivateMetadata
+func newTestEXIF(order binary.AppendByteOrder, orientation uint16) []byte {
+	tiff := []byte("MM\x00*")
+	if order == binary.LittleEndian {
+		tiff = []byte("II*\x00")
+	}
+	tiff = order.AppendUint32(tiff, 8)
+
+	const entries = 2
+	tiff = order.AppendUint16(tiff, entries)
+	// the description, its value stored right after the IFD
+	tiff = order.AppendUint16(tiff, 0x010E)
+	tiff = order.AppendUint16(tiff, 2)
+	tiff = order.AppendUint32(tiff, uint32(len(testPrivateMetadata)+1))
+	tiff = order.AppendUint32(tiff, 8+2+entries*12+4)
+	// the orientation, its short value left aligned
+	tiff = order.AppendUint16(tiff, exifTagOrientation)
+	tiff = order.AppendUint16(tiff, exifTypeShort)
+	tiff = order.AppendUint32(tiff, 1)
+	tiff = order.AppendUint16(tiff, orientation)
+	tiff = order.AppendUint16(tiff, 0)
+	// no next IFD
+	tiff = order.AppendUint32(tiff, 0)
+
+	tiff = append(tiff, testPrivateMetadata...)
+	return append(tiff, 0)
+}
+
+func TestReadEXIFOrientation(t *testing.T) {
+	wrongType := newTestEXIF(binary.BigEndian, 6)
+	// the type of the orientation entry, the second one
+	binary.BigEndian.PutUint16(wrongType[8+2+12+2:], 4)
+
+	tests := []struct {
+		name string
+		tiff []byte
+		want imageOrientation
+	}{
+		{name: "big endian", tiff: newTestEXIF(binary.BigEndian, 6), want: 6},
+		{name: "little endian", tiff: newTestEXIF(binary.LittleEndian, 8), want: 8},
+		{name: "written by newOrientationEXIF", tiff: newOrientationEXIF(3), want: 3},
+		{name: "out of range", tiff: newTestEXIF(binary.BigEndian, 9), want: imageOrientationNormal},
+		{name: "zero", tiff: newTestEXIF(binary.LittleEndian, 0), want: imageOrientationNormal},
+		{name: "not a short", tiff: wrongType, want: imageOrientationNormal},
+		{name: "truncated IFD", tiff: newTestEXIF(binary.BigEndian, 6)[:8+2+12], want: imageOrientationNormal},
+		{name: "IFD past the end", tiff: append([]byte("MM\x00*"), 0, 0, 0xFF, 0xFF), want: imageOrientationNormal},
+		{name: "no byte order", tiff: []byte("Exif\x00\x00MM\x00*"), want: imageOrientationNormal},
+		{name: "empty", tiff: nil, want: imageOrientationNormal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := readEXIFOrientation(tt.tiff); got != tt.want {
+				t.Errorf("readEXIFOrientation() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrientImage(t *testing.T) {
+	// the source is 3 pixels wide and 2 high, labeled
+	//   A B C
+	//   D E F
+	const (
+		a uint8 = iota + 1
+		b
+		c
+		d
+		e
+		f
+	)
+	src := [][]uint8{
+		{a, b, c},
+		{d, e, f},
+	}
+
+	tests := []struct {
+		orientation imageOrientation
+		want        [][]uint8
+	}{
+		{orientation: 1, want: [][]uint8{{a, b, c}, {d, e, f}}},
+		// flipped horizontally
+		{orientation: 2, want: [][]uint8{{c, b, a}, {f, e, d}}},
+		// rotated 180 degrees
+		{orientation: 3, want: [][]uint8{{f, e, d}, {c, b, a}}},
+		// flipped vertically
+		{orientation: 4, want: [][]uint8{{d, e, f}, {a, b, c}}},
+		// transposed
+		{orientation: 5, want: [][]uint8{{a, d}, {b, e}, {c, f}}},
+		// rotated 90 degrees clockwise
+		{orientation: 6, want: [][]uint8{{d, a}, {e, b}, {f, c}}},
+		// transversed
+		{orientation: 7, want: [][]uint8{{f, c}, {e, b}, {d, a}}},
+		// rotated 90 degrees counterclockwise
+		{orientation: 8, want: [][]uint8{{c, f}, {b, e}, {a, d}}},
+	}
+
+	for _, tt := range tests {
+		// the source doesn't start at the origin, like a sub image
+		img := image.NewRGBA(image.Rect(10, 20, 13, 22))
+		for y, row := range src {
+			for x, label := range row {
+				img.Set(10+x, 20+y, color.RGBA{R: label, A: 0xFF})
+			}
+		}
+
+		got := orientImage(img, tt.orientation)
+		bounds := got.Bounds()
+		if bounds.Dx() != len(tt.want[0]) || bounds.Dy() != len(tt.want) {
+			t.Errorf("orientation %d: the image is %dx%d, want %dx%d", tt.orientation, bounds.Dx(), bounds.Dy(), len(tt.want[0]), len(tt.want))
+			continue
+		}
+		for y, row := range tt.want {
+			for x, label := range row {
+				r, _, _, _ := got.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+				if uint8(r>>8) != label {
+					t.Errorf("orientation %d: the pixel (%d, %d) is %d, want %d", tt.orientation, x, y, r>>8, label)
+				}
+			}
+		}
+	}
+}
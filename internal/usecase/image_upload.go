@@ -0,0 +1,114 @@
+package usecase
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/jpeg" // registers the decoders of the uploaded image formats
+	_ "image/png"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/binus-thesis-team/product-service/internal/config"
+	"github.com/binus-thesis-team/product-service/internal/model"
+	"github.com/google/uuid"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	mimeJPEG = "image/jpeg"
+	mimePNG  = "image/png"
+	mimeWebP = "image/webp"
+)
+
+// imageFormats are the formats of the uploaded images by their sniffed content type
+var imageFormats = map[string]struct {
+	// name is the name of the format registered by its decoder
+	name      string
+	extension string
+}{
+	mimeJPEG: {name: "jpeg", extension: ".jpg"},
+	mimePNG:  {name: "png", extension: ".png"},
+	mimeWebP: {name: "webp", extension: ".webp"},
+}
+
+// uploadedImage is an uploaded image that was checked and stripped of its metadata
+type uploadedImage struct {
+	data        []byte
+	contentType string
+	// image is decoded from data, laid upright with its orientation
+	image image.Image
+}
+
+// readUploadedImage reads and checks an uploaded image. The format is sniffed from the content, the name and
+// the content type sent by the client are never trusted. The image is decoded only after its header shows
+// dimensions within the limits, so a small file can't expand into a huge bitmap.
+func readUploadedImage(r io.Reader, maxSize int64, maxWidth, maxHeight int) (*uploadedImage, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, &model.FieldError{Field: "product_image", Message: "The file size exceeds the maximum limit"}
+	}
+
+	contentType := http.DetectContentType(data)
+	format, ok := imageFormats[contentType]
+	if !ok {
+		return nil, &model.FieldError{Field: "product_image", Message: "Only JPEG, PNG and WebP images are allowed"}
+	}
+
+	cfg, name, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || name != format.name {
+		return nil, &model.FieldError{Field: "product_image", Message: "The image is invalid"}
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width > maxWidth || cfg.Height > maxHeight {
+		return nil, &model.FieldError{Field: "product_image", Message: fmt.Sprintf("The image must be at most %dx%d pixels", maxWidth, maxHeight)}
+	}
+
+	// a header can be valid for a truncated or corrupted image
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, &model.FieldError{Field: "product_image", Message: "The image is invalid"}
+	}
+
+	data, orientation, err := stripImageMetadata(contentType, data)
+	if err != nil {
+		return nil, &model.FieldError{Field: "product_image", Message: "The image is invalid"}
+	}
+
+	// the derivatives are encoded without the orientation, their pixels must be upright
+	return &uploadedImage{data: data, contentType: contentType, image: orientImage(img, orientation)}, nil
+}
+
+// newUploadedImageKey returns a key generated for an uploaded image, the name sent by the client is never used
+func newUploadedImageKey(contentType string) string {
+	return config.ImageStoreKeyPrefix() + uuid.NewString() + imageFormats[contentType].extension
+}
+
+// uploadedImageKey returns the key of an image stored by UploadImage, false for any other url
+func (u *productUsecase) uploadedImageKey(url string) (string, bool) {
+	key, ok := u.imageStore.Key(url)
+	if !ok {
+		return "", false
+	}
+
+	name, ok := strings.CutPrefix(key, config.ImageStoreKeyPrefix())
+	if !ok {
+		return "", false
+	}
+
+	extension := path.Ext(name)
+	id, err := uuid.Parse(strings.TrimSuffix(name, extension))
+	if err != nil || id.String()+extension != name {
+		return "", false
+	}
+	for _, format := range imageFormats {
+		if format.extension == extension {
+			return key, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,166 @@
+package usecase
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/xuri/excelize/v2"
+)
+
+var (
+	// xlsx files are zip archives
+	xlsxMagic = []byte("PK\x03\x04")
+	// legacy xls files are OLE compound documents
+	xlsMagic = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+)
+
+// readImportRecords reads the records of an import file, xlsx, jsonl or csv detected from the content
+// whatever the file name says
+func readImportRecords(content []byte) ([][]string, error) {
+	switch {
+	case bytes.HasPrefix(content, xlsxMagic):
+		return readXLSXRecords(content)
+	case bytes.HasPrefix(content, xlsMagic):
+		return nil, errors.New("xls files are not supported, save the file as xlsx or csv")
+	case isJSONLContent(content):
+		return readJSONLRecords(content)
+	default:
+		return readCSVRecords(content)
+	}
+}
+
+func readCSVRecords(content []byte) ([][]string, error) {
+	csvReader := csv.NewReader(bytes.NewReader(content))
+	csvReader.Comma = ','
+	// short and long rows are reported per row instead of failing the whole file
+	csvReader.FieldsPerRecord = -1
+
+	return csvReader.ReadAll()
+}
+
+// readXLSXRecords reads the first sheet of a workbook. The cells are read raw, so a price
+// formatted as currency in the sheet is still read as a plain number.
+func readXLSXRecords(content []byte) ([][]string, error) {
+	f, err := excelize.OpenReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, errors.New("the workbook has no sheet")
+	}
+
+	records, err := f.GetRows(sheets[0], excelize.Options{RawCellValue: true})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return records, nil
+	}
+
+	// the trailing empty cells are left out by excelize, an empty cell must read as an empty value
+	// like in csv instead of a missing one
+	width := len(records[0])
+	for i, record := range records {
+		for len(record) < width {
+			record = append(record, "")
+		}
+		records[i] = record
+	}
+
+	return records, nil
+}
+
+// isJSONLContent returns true when the content starts with a json object, a csv header can't
+func isJSONLContent(content []byte) bool {
+	content = bytes.TrimLeft(content, " \t\r\n")
+	return len(content) > 0 && content[0] == '{'
+}
+
+// readJSONLRecords reads one object per line, like the jsonl export. Like in the other formats
+// the first record is the header, so the object on line n is reported as row n+1.
+func readJSONLRecords(content []byte) ([][]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+
+	var objects []map[string]any
+	for {
+		var object map[string]any
+		err := decoder.Decode(&object)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("object %d: %w", len(objects)+1, err)
+		}
+		objects = append(objects, object)
+	}
+
+	return jsonObjectsToRecords(objects), nil
+}
+
+// readJSONRecords converts an array of objects to import records
+func readJSONRecords(content []byte) ([][]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+
+	var objects []map[string]any
+	if err := decoder.Decode(&objects); err != nil {
+		return nil, err
+	}
+
+	return jsonObjectsToRecords(objects), nil
+}
+
+// jsonObjectsToRecords converts objects to import records, the header being every key found in the objects
+func jsonObjectsToRecords(objects []map[string]any) [][]string {
+	keys := make(map[string]bool)
+	for _, object := range objects {
+		for key := range object {
+			keys[key] = true
+		}
+	}
+
+	header := make([]string, 0, len(keys))
+	for key := range keys {
+		header = append(header, key)
+	}
+	sort.Strings(header)
+
+	records := make([][]string, 0, len(objects)+1)
+	records = append(records, header)
+	for _, object := range objects {
+		record := make([]string, len(header))
+		for i, key := range header {
+			record[i] = jsonValueToString(object[key])
+		}
+		records = append(records, record)
+	}
+
+	return records
+}
+
+func jsonValueToString(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
@@ -0,0 +1,95 @@
+package usecase
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/binus-thesis-team/product-service/internal/model"
+)
+
+// importHeader maps the import fields to their column index in the file
+type importHeader struct {
+	columns map[string]int
+}
+
+func (h *importHeader) has(field string) bool {
+	_, ok := h.columns[field]
+	return ok
+}
+
+// value returns the trimmed value of the field, false when the row is too short to hold it
+func (h *importHeader) value(row []string, field string) (string, bool) {
+	idx, ok := h.columns[field]
+	if !ok || idx >= len(row) {
+		return "", false
+	}
+	return strings.TrimSpace(row[idx]), true
+}
+
+// resolveImportHeader matches every header column to an import field, by the explicit mapping first,
+// then by the field name or one of its aliases. An explicit mapping to an empty field ignores the column.
+// Every unresolved column is reported at once in a *model.ImportHeaderError.
+func resolveImportHeader(header []string, mapping map[string]string) (*importHeader, error) {
+	fields := make(map[string]string)
+	for _, field := range model.ProductImportFields {
+		fields[field.Name] = field.Name
+		for _, alias := range field.Aliases {
+			fields[normalizeImportColumn(alias)] = field.Name
+		}
+	}
+
+	columnMapping := make(map[string]string, len(mapping))
+	for column, field := range mapping {
+		columnMapping[normalizeImportColumn(column)] = strings.TrimSpace(field)
+	}
+
+	headerErr := &model.ImportHeaderError{}
+	columns := make(map[string]int)
+	for i, column := range header {
+		if i == 0 {
+			column = strings.TrimPrefix(column, "\ufeff") // utf-8 BOM
+		}
+		if strings.TrimSpace(column) == "" {
+			column = fmt.Sprintf("#%d", i+1)
+		}
+
+		key := normalizeImportColumn(column)
+		field, mapped := columnMapping[key]
+		switch {
+		case mapped && (field == "" || field == "-"):
+			continue
+		case mapped:
+			field, mapped = fields[normalizeImportColumn(field)]
+		default:
+			field, mapped = fields[key]
+		}
+
+		if !mapped {
+			headerErr.UnknownColumns = append(headerErr.UnknownColumns, column)
+			continue
+		}
+
+		if _, ok := columns[field]; ok {
+			headerErr.DuplicateColumns = append(headerErr.DuplicateColumns, column)
+			continue
+		}
+		columns[field] = i
+	}
+
+	for _, field := range model.ProductImportFields {
+		if _, ok := columns[field.Name]; !ok && field.Required {
+			headerErr.MissingColumns = append(headerErr.MissingColumns, field.Name)
+		}
+	}
+
+	if headerErr.HasError() {
+		return nil, headerErr
+	}
+
+	return &importHeader{columns: columns}, nil
+}
+
+func normalizeImportColumn(column string) string {
+	column = strings.ToLower(strings.TrimSpace(column))
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(column)
+}
@@ -0,0 +1,144 @@
+package usecase
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/binus-thesis-team/product-service/internal/model"
+	"github.com/sirupsen/logrus"
+)
+
+// importJobTracker accumulates the progress of a running import job and saves it periodically,
+// so the status endpoint can report the progress before the job is finished
+type importJobTracker struct {
+	repository model.ProductImportJobRepository
+
+	mu        sync.Mutex
+	job       *model.ProductImportJob
+	rowErrors []*model.ProductImportRowError
+}
+
+func newImportJobTracker(repository model.ProductImportJobRepository, job *model.ProductImportJob) *importJobTracker {
+	return &importJobTracker{
+		repository: repository,
+		job:        job,
+	}
+}
+
+// start marks the job as running
+func (t *importJobTracker) start(ctx context.Context, totalRows int64) error {
+	now := time.Now()
+
+	t.mu.Lock()
+	t.job.Status = model.ProductImportJobStatusRunning
+	t.job.TotalRows = totalRows
+	t.job.StartedAt = &now
+	t.mu.Unlock()
+
+	return t.flush(ctx)
+}
+
+func (t *importJobTracker) recordCreated() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.job.ProcessedRows++
+	t.job.CreatedRows++
+}
+
+func (t *importJobTracker) recordUpdated() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.job.ProcessedRows++
+	t.job.UpdatedRows++
+}
+
+// recordSaved records the rows saved at once, e.g. by a committed transaction
+func (t *importJobTracker) recordSaved(created, updated int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.job.ProcessedRows += created + updated
+	t.job.CreatedRows += created
+	t.job.UpdatedRows += updated
+}
+
+func (t *importJobTracker) recordFailed(rowErr *model.ProductImportRowError) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.job.ProcessedRows++
+	t.job.FailedRows++
+
+	rowErr.JobID = t.job.ID
+	t.rowErrors = append(t.rowErrors, rowErr)
+}
+
+// flushEvery saves the progress every interval until the returned stop func is called
+func (t *importJobTracker) flushEvery(ctx context.Context, interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := t.flush(ctx); err != nil {
+					logrus.WithField("jobID", t.job.ID).Error(err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// finish marks the job as done, or as failed when err is not nil, then saves it
+func (t *importJobTracker) finish(ctx context.Context, err error) {
+	now := time.Now()
+
+	t.mu.Lock()
+	t.job.Status = model.ProductImportJobStatusDone
+	if err != nil {
+		t.job.Status = model.ProductImportJobStatusFailed
+		t.job.ErrorMessage = err.Error()
+	}
+	t.job.FinishedAt = &now
+	t.mu.Unlock()
+
+	if err := t.flush(ctx); err != nil {
+		logrus.WithField("jobID", t.job.ID).Error(err)
+	}
+}
+
+// flush saves the pending row errors and the job counters
+func (t *importJobTracker) flush(ctx context.Context) error {
+	t.mu.Lock()
+	job := *t.job
+	rowErrors := t.rowErrors
+	t.rowErrors = nil
+	t.mu.Unlock()
+
+	if err := t.repository.CreateRowErrors(ctx, rowErrors); err != nil {
+		// keep them for the next flush
+		t.mu.Lock()
+		t.rowErrors = append(rowErrors, t.rowErrors...)
+		t.mu.Unlock()
+		return err
+	}
+
+	return t.repository.Update(ctx, &job)
+}
@@ -0,0 +1,174 @@
+package usecase
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/binus-thesis-team/iam-service/rbac"
+	"github.com/binus-thesis-team/iam-service/utils"
+	"github.com/binus-thesis-team/product-service/internal/config"
+	"github.com/binus-thesis-team/product-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	mediaQueryExpires   = "expires"
+	mediaQuerySignature = "signature"
+)
+
+// OpenMedia opens the stored image of the key. With the signed urls enabled, an image that isn't the one of
+// a published product, e.g. of a deleted product or uploaded but not attached yet, is only served through a
+// valid signed url, and is not found otherwise.
+func (u *productUsecase) OpenMedia(ctx context.Context, input model.OpenMediaRequest) (*model.Media, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"ctx":   utils.DumpIncomingContext(ctx),
+		"input": utils.Dump(input),
+	})
+
+	if u.imageStore == nil {
+		return nil, ErrNotFound
+	}
+
+	media := &model.Media{}
+	if config.MediaSignedURLsEnabled() {
+		switch {
+		case input.Signature != "":
+			expiresAt, err := verifyMediaSignature(input.Key, input.Expires, input.Signature)
+			if err != nil {
+				return nil, err
+			}
+			media.ExpiresAt = expiresAt
+		default:
+			published, err := u.productRepository.IsAnyImagePublished(ctx, u.mediaSourceImageURLs(input.Key))
+			if err != nil {
+				logger.Error(err)
+				return nil, err
+			}
+			if !published {
+				return nil, ErrNotFound
+			}
+		}
+	}
+
+	object, err := u.imageStore.Get(ctx, input.Key)
+	switch {
+	case errors.Is(err, model.ErrImageNotFound), errors.Is(err, model.ErrInvalidImageKey):
+		return nil, ErrNotFound
+	case err != nil:
+		logger.Error(err)
+		return nil, err
+	}
+
+	media.ImageObject = object
+	return media, nil
+}
+
+// SignImageURL signs the url of an uploaded image and of its derivatives for MediaSignedURLTTL
+func (u *productUsecase) SignImageURL(ctx context.Context, user model.SessionUser, imageURL string) (*model.SignedImageURL, error) {
+	if !user.HasAccess(rbac.ResourceProduct, rbac.ActionViewAny) {
+		return nil, ErrPermissionDenied
+	}
+
+	if u.imageStore == nil {
+		return nil, ErrNotFound
+	}
+	key, ok := u.uploadedImageKey(imageURL)
+	if !ok {
+		return nil, &model.FieldError{Field: "image_url", Message: "Image URL isn't an uploaded image"}
+	}
+
+	if !config.MediaSignedURLsEnabled() {
+		return &model.SignedImageURL{ImageUrl: imageURL, Images: u.imageDerivativeURLs(imageURL)}, nil
+	}
+
+	expiresAt := time.Now().Add(config.MediaSignedURLTTL()).Truncate(time.Second)
+	signed := &model.SignedImageURL{
+		ImageUrl:  u.signedMediaURL(key, expiresAt),
+		Images:    make(map[string]string, len(config.ImageDerivativeSizes())),
+		ExpiresAt: &expiresAt,
+	}
+	for _, size := range config.ImageDerivativeSizes() {
+		signed.Images[strconv.Itoa(size)] = u.signedMediaURL(imageDerivativeKey(key, size), expiresAt)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"ctx":         utils.DumpIncomingContext(ctx),
+		"currentUser": utils.Dump(user),
+		"key":         key,
+	}).Info("signed image url")
+	return signed, nil
+}
+
+// mediaSourceImageURLs returns the urls a product may hold for the image of the key, the url of the key itself
+// and, for the derivative of an uploaded image, the urls of its original in every format, e.g. products/<id>.png
+// for products/<id>_150.jpg
+func (u *productUsecase) mediaSourceImageURLs(key string) []string {
+	urls := []string{u.imageStore.URL(key)}
+
+	name, ok := strings.CutPrefix(key, config.ImageStoreKeyPrefix())
+	if !ok || path.Ext(name) != imageFormats[mimeJPEG].extension {
+		return urls
+	}
+	id, size, ok := strings.Cut(strings.TrimSuffix(name, path.Ext(name)), "_")
+	if !ok {
+		return urls
+	}
+	// a derivative of a size that isn't configured anymore is still served
+	if n, err := strconv.Atoi(size); err != nil || n <= 0 || strconv.Itoa(n) != size {
+		return urls
+	}
+	if parsed, err := uuid.Parse(id); err != nil || parsed.String() != id {
+		return urls
+	}
+
+	for _, format := range imageFormats {
+		urls = append(urls, u.imageStore.URL(config.ImageStoreKeyPrefix()+id+format.extension))
+	}
+	return urls
+}
+
+// signedMediaURL returns the url of the key with the expiry and the signature in its query
+func (u *productUsecase) signedMediaURL(key string, expiresAt time.Time) string {
+	expires := expiresAt.Unix()
+	query := url.Values{}
+	query.Set(mediaQueryExpires, strconv.FormatInt(expires, 10))
+	query.Set(mediaQuerySignature, mediaSignature(key, expires))
+	return u.imageStore.URL(key) + "?" + query.Encode()
+}
+
+// verifyMediaSignature returns the expiry of a valid signed url of the key
+func verifyMediaSignature(key string, expires int64, signature string) (time.Time, error) {
+	expiresAt := time.Unix(expires, 0)
+	if !time.Now().Before(expiresAt) {
+		return time.Time{}, ErrInvalidSignature
+	}
+
+	actual, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(mediaMAC(key, expires), actual) {
+		return time.Time{}, ErrInvalidSignature
+	}
+
+	return expiresAt, nil
+}
+
+// mediaSignature is the hex of mediaMAC
+func mediaSignature(key string, expires int64) string {
+	return hex.EncodeToString(mediaMAC(key, expires))
+}
+
+// mediaMAC is the HMAC-SHA256 of the key and the expiry, the expiry is last and has no newline so the
+// message can't be read as another key and expiry
+func mediaMAC(key string, expires int64) []byte {
+	mac := hmac.New(sha256.New, []byte(config.MediaSigningKey()))
+	mac.Write([]byte(key + "\n" + strconv.FormatInt(expires, 10)))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,368 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/binus-thesis-team/iam-service/rbac"
+	"github.com/binus-thesis-team/iam-service/utils"
+	"github.com/binus-thesis-team/product-service/internal/config"
+	"github.com/binus-thesis-team/product-service/internal/model"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// errBulkRollback rolls back a stop on error bulk mutation whose statement left an operation out
+var errBulkRollback = errors.New("bulk mutation rolled back")
+
+// BulkMutate checks every operation against the stored products, then applies the valid ones in a single
+// statement. A product changed meanwhile fails its operation with ErrVersionConflict.
+func (u *productUsecase) BulkMutate(ctx context.Context, user model.SessionUser, input model.BulkMutateProductsRequest) (results []*model.ProductBulkResult, err error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"ctx":         utils.DumpIncomingContext(ctx),
+		"count":       len(input.Operations),
+		"stopOnError": input.StopOnError,
+	})
+
+	switch {
+	case len(input.Operations) == 0:
+		return nil, &model.FieldError{Field: "operations", Message: "Operations are required"}
+	case len(input.Operations) > config.ProductBulkMaxOperations():
+		return nil, &model.FieldError{Field: "operations", Message: fmt.Sprintf("At most %d operations are allowed", config.ProductBulkMaxOperations())}
+	}
+
+	for _, operation := range input.Operations {
+		if !hasBulkOperationAccess(user, operation.Type) {
+			return nil, ErrPermissionDenied
+		}
+	}
+
+	results, pending, err := u.checkBulkOperations(ctx, input.Operations)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	if input.StopOnError {
+		pending = pending.before(firstFailedBulkResult(results))
+		err = u.bulkMutateUntilFirstConflict(ctx, user, pending, results)
+	} else {
+		err = u.bulkMutate(ctx, user, pending, results)
+	}
+	switch {
+	case err == nil:
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		// the external ids were checked, another write took one meanwhile
+		return nil, ErrDuplicateProduct
+	default:
+		logger.Error(err)
+		return nil, err
+	}
+
+	if input.StopOnError {
+		skipBulkResultsAfter(results, firstFailedBulkResult(results))
+	}
+
+	if err := u.setBulkResultProducts(ctx, results); err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// pendingBulkOperations are the operations left to apply, their product ids are unique
+type pendingBulkOperations struct {
+	operations []model.ProductBulkOperation
+	// indexes are the operation indexes by product id
+	indexes map[int64]int
+}
+
+func (p pendingBulkOperations) add(index int, operation model.ProductBulkOperation) pendingBulkOperations {
+	p.operations = append(p.operations, operation)
+	p.indexes[operation.ProductID] = index
+	return p
+}
+
+// before returns the operations before index
+func (p pendingBulkOperations) before(index int) pendingBulkOperations {
+	before := pendingBulkOperations{indexes: map[int64]int{}}
+	for _, operation := range p.operations {
+		if p.indexes[operation.ProductID] < index {
+			before = before.add(p.indexes[operation.ProductID], operation)
+		}
+	}
+	return before
+}
+
+// checkBulkOperations returns a result for every operation, failed when the operation is invalid and
+// succeeded when there's nothing to change, and the operations left to apply
+func (u *productUsecase) checkBulkOperations(ctx context.Context, operations []model.ProductBulkOperation) ([]*model.ProductBulkResult, pendingBulkOperations, error) {
+	results := make([]*model.ProductBulkResult, len(operations))
+	seen := make(map[int64]bool, len(operations))
+	ids := make([]int64, 0, len(operations))
+	for i := range operations {
+		operation := &operations[i]
+		results[i] = &model.ProductBulkResult{Index: i, ProductID: operation.ProductID}
+
+		if err := operation.ValidateDTOProductBulkOperation(); err != nil {
+			failBulkResult(results[i], err)
+			continue
+		}
+		if seen[operation.ProductID] {
+			failBulkResult(results[i], &model.FieldError{Field: "product_id", Message: "Product ID is already used by another operation"})
+			continue
+		}
+		seen[operation.ProductID] = true
+		ids = append(ids, operation.ProductID)
+	}
+
+	pending := pendingBulkOperations{indexes: map[int64]int{}}
+	products, err := u.productRepository.FindAllByIDs(ctx, ids)
+	if err != nil {
+		return nil, pending, err
+	}
+	productByID := make(map[int64]*model.Product, len(products))
+	for _, product := range products {
+		productByID[product.ID] = product
+	}
+
+	for i, operation := range operations {
+		if results[i].Status == model.ProductBulkResultFailed {
+			continue
+		}
+
+		noop, err := checkBulkOperation(operation, productByID[operation.ProductID])
+		switch {
+		case err != nil:
+			failBulkResult(results[i], err)
+		case noop:
+			results[i].Status = model.ProductBulkResultSucceeded
+		}
+	}
+
+	if err := u.checkBulkExternalIDs(ctx, operations, results); err != nil {
+		return nil, pending, err
+	}
+
+	for i, operation := range operations {
+		if results[i].Status == "" {
+			pending = pending.add(i, operation)
+		}
+	}
+
+	return results, pending, nil
+}
+
+// checkBulkExternalIDs fails the updates setting an external id of another product, or of a previous update,
+// since a single one would fail the whole statement on the unique index
+func (u *productUsecase) checkBulkExternalIDs(ctx context.Context, operations []model.ProductBulkOperation, results []*model.ProductBulkResult) error {
+	var externalIDs []string
+	for i, operation := range operations {
+		if results[i].Status == "" && bulkOperationExternalID(operation) != "" {
+			externalIDs = append(externalIDs, bulkOperationExternalID(operation))
+		}
+	}
+	if len(externalIDs) == 0 {
+		return nil
+	}
+
+	// soft deleted products included, they keep their external id
+	existing, err := u.productRepository.FindAllByExternalIDs(ctx, externalIDs)
+	if err != nil {
+		return err
+	}
+	ownerByExternalID := make(map[string]int64, len(existing))
+	for _, product := range existing {
+		ownerByExternalID[product.GetExternalID()] = product.ID
+	}
+
+	claimed := make(map[string]bool, len(externalIDs))
+	for i, operation := range operations {
+		externalID := bulkOperationExternalID(operation)
+		if results[i].Status != "" || externalID == "" {
+			continue
+		}
+
+		ownerID, owned := ownerByExternalID[externalID]
+		switch {
+		case owned && ownerID != operation.ProductID:
+			failBulkResult(results[i], &model.FieldError{Field: "external_id", Message: "External ID is already used by another product"})
+		case claimed[externalID]:
+			failBulkResult(results[i], &model.FieldError{Field: "external_id", Message: "External ID is already used by another operation"})
+		default:
+			claimed[externalID] = true
+		}
+	}
+
+	return nil
+}
+
+// bulkOperationExternalID returns the external id set by an update, empty when it sets none
+func bulkOperationExternalID(operation model.ProductBulkOperation) string {
+	if operation.Type != model.ProductBulkOperationUpdate || operation.Fields.ExternalID == nil {
+		return ""
+	}
+	return *operation.Fields.ExternalID
+}
+
+// checkBulkOperation returns true when the operation changes nothing, i.e. deleting a deleted product
+// or restoring a live one
+func checkBulkOperation(operation model.ProductBulkOperation, product *model.Product) (noop bool, err error) {
+	if product == nil {
+		return false, ErrNotFound
+	}
+	if !product.HasVersion(operation.ExpectedVersion) {
+		return false, ErrVersionConflict
+	}
+
+	switch operation.Type {
+	case model.ProductBulkOperationDelete:
+		return product.DeletedAt.Valid, nil
+	case model.ProductBulkOperationRestore:
+		return !product.DeletedAt.Valid, nil
+	}
+
+	if product.DeletedAt.Valid {
+		return false, ErrNotFound
+	}
+
+	// same rounding as the statement
+	if operation.Type == model.ProductBulkOperationAdjustPrice && math.Round(product.Price*(100+operation.PricePercent))/100 <= 0 {
+		return false, &model.FieldError{Field: "price_percent", Message: "The adjusted price must be greater than 0"}
+	}
+
+	return false, nil
+}
+
+// bulkMutate applies the operations, the ones left out by the statement have been changed meanwhile
+func (u *productUsecase) bulkMutate(ctx context.Context, user model.SessionUser, pending pendingBulkOperations, results []*model.ProductBulkResult) error {
+	mutatedIDs, err := u.productRepository.BulkMutate(ctx, user.GetUserID(), pending.operations)
+	if err != nil {
+		return err
+	}
+
+	missing := missingBulkOperations(pending.operations, mutatedIDs)
+	for productID, index := range pending.indexes {
+		if missing[productID] {
+			failBulkResult(results[index], ErrVersionConflict)
+			continue
+		}
+		results[index].Status = model.ProductBulkResultSucceeded
+	}
+
+	return nil
+}
+
+// bulkMutateUntilFirstConflict applies the operations in a transaction, rolled back when the statement
+// leaves an operation out. The operations before the first one left out are applied again until none is.
+func (u *productUsecase) bulkMutateUntilFirstConflict(ctx context.Context, user model.SessionUser, pending pendingBulkOperations, results []*model.ProductBulkResult) error {
+	for len(pending.operations) > 0 {
+		var missing map[int64]bool
+		err := u.productRepository.Transaction(ctx, func(repo model.ProductRepository) error {
+			mutatedIDs, err := repo.BulkMutate(ctx, user.GetUserID(), pending.operations)
+			if err != nil {
+				return err
+			}
+
+			missing = missingBulkOperations(pending.operations, mutatedIDs)
+			if len(missing) > 0 {
+				return errBulkRollback
+			}
+			return nil
+		})
+		switch {
+		case err == nil:
+			for _, index := range pending.indexes {
+				results[index].Status = model.ProductBulkResultSucceeded
+			}
+			return nil
+		case !errors.Is(err, errBulkRollback):
+			return err
+		}
+
+		firstConflict := len(results)
+		for productID := range missing {
+			firstConflict = min(firstConflict, pending.indexes[productID])
+		}
+		failBulkResult(results[firstConflict], ErrVersionConflict)
+		pending = pending.before(firstConflict)
+	}
+
+	return nil
+}
+
+// setBulkResultProducts reads the products of the succeeded operations
+func (u *productUsecase) setBulkResultProducts(ctx context.Context, results []*model.ProductBulkResult) error {
+	var ids []int64
+	for _, result := range results {
+		if result.Status == model.ProductBulkResultSucceeded {
+			ids = append(ids, result.ProductID)
+		}
+	}
+
+	products, err := u.productRepository.FindAllByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	productByID := make(map[int64]*model.Product, len(products))
+	for _, product := range products {
+		productByID[product.ID] = product
+	}
+	for _, result := range results {
+		if result.Status == model.ProductBulkResultSucceeded {
+			result.Product = productByID[result.ProductID]
+			if result.Product != nil {
+				result.Product.Images = u.imageDerivativeURLs(result.Product.ImageUrl)
+			}
+		}
+	}
+	return nil
+}
+
+func hasBulkOperationAccess(user model.SessionUser, operationType model.ProductBulkOperationType) bool {
+	switch operationType {
+	case model.ProductBulkOperationDelete, model.ProductBulkOperationRestore:
+		return user.HasAccess(rbac.ResourceProduct, rbac.ActionDeleteAny)
+	default:
+		return user.HasAccess(rbac.ResourceProduct, rbac.ActionCreateAny)
+	}
+}
+
+func failBulkResult(result *model.ProductBulkResult, err error) {
+	result.Status = model.ProductBulkResultFailed
+	result.Err = err
+}
+
+// firstFailedBulkResult returns the index of the first failed result, len(results) when none failed
+func firstFailedBulkResult(results []*model.ProductBulkResult) int {
+	for _, result := range results {
+		if result.Status == model.ProductBulkResultFailed {
+			return result.Index
+		}
+	}
+	return len(results)
+}
+
+// skipBulkResultsAfter skips the results after index, the failed ones are kept to report every invalid operation
+func skipBulkResultsAfter(results []*model.ProductBulkResult, index int) {
+	for _, result := range results[min(index+1, len(results)):] {
+		if result.Status != model.ProductBulkResultFailed {
+			result.Status = model.ProductBulkResultSkipped
+		}
+	}
+}
+
+func missingBulkOperations(operations []model.ProductBulkOperation, mutatedIDs []int64) map[int64]bool {
+	missing := make(map[int64]bool, len(operations))
+	for _, operation := range operations {
+		missing[operation.ProductID] = true
+	}
+	for _, id := range mutatedIDs {
+		delete(missing, id)
+	}
+	return missing
+}
@@ -0,0 +1,49 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/binus-thesis-team/iam-service/rbac"
+	"github.com/binus-thesis-team/iam-service/utils"
+	"github.com/binus-thesis-team/product-service/internal/model"
+	"github.com/sirupsen/logrus"
+)
+
+// exportPageSize is the number of products read per query, only one page is held in memory
+const exportPageSize = 500
+
+// Export calls fn for every product matching the criteria, ordered by id, stopping at the first error
+func (u *productUsecase) Export(ctx context.Context, user model.SessionUser, criteria model.ProductExportCriteria, fn func(product *model.Product) error) (err error) {
+	if !user.HasAccess(rbac.ResourceProduct, rbac.ActionViewAny) {
+		return ErrPermissionDenied
+	}
+
+	return u.ExportWithoutSession(ctx, criteria, fn)
+}
+
+// ExportWithoutSession is Export without the access check, e.g. for the console
+func (u *productUsecase) ExportWithoutSession(ctx context.Context, criteria model.ProductExportCriteria, fn func(product *model.Product) error) (err error) {
+	var cursorAfter int64
+	for {
+		products, err := u.productRepository.FindAllProductsByQuery(ctx, criteria.Query, exportPageSize, cursorAfter)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"ctx":         utils.DumpIncomingContext(ctx),
+				"criteria":    utils.Dump(criteria),
+				"cursorAfter": cursorAfter,
+			}).Error(err)
+			return err
+		}
+
+		for _, product := range products {
+			if err := fn(product); err != nil {
+				return err
+			}
+		}
+
+		if len(products) < exportPageSize {
+			return nil
+		}
+		cursorAfter = products[len(products)-1].ID
+	}
+}
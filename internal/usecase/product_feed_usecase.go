@@ -0,0 +1,107 @@
+package usecase
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/binus-thesis-team/iam-service/utils"
+	"github.com/binus-thesis-team/product-service/internal/config"
+	"github.com/binus-thesis-team/product-service/internal/model"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	feedPageSize = 500
+	// feedClockSkew widens the incremental read, a product saved by another host with a late clock isn't missed
+	feedClockSkew = time.Minute
+)
+
+// WriteFeed renders the merchant feed, the products are read incrementally since the previous call
+func (u *productUsecase) WriteFeed(ctx context.Context, w io.Writer, format model.ProductFeedFormat) (err error) {
+	u.feedMutex.Lock()
+	snapshot, err := u.regenerateFeed(ctx, u.feedSnapshot)
+	if err == nil {
+		u.feedSnapshot = snapshot
+	}
+	u.feedMutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return model.WriteProductFeed(w, format, newProductFeedChannel(), snapshot.SortedItems())
+}
+
+func (u *productUsecase) GenerateFeed(ctx context.Context, previous *model.ProductFeedSnapshot, w io.Writer, format model.ProductFeedFormat) (snapshot *model.ProductFeedSnapshot, err error) {
+	snapshot, err = u.regenerateFeed(ctx, previous)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := model.WriteProductFeed(w, format, newProductFeedChannel(), snapshot.SortedItems()); err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// regenerateFeed returns a new snapshot, the previous one is left untouched.
+// Without a previous snapshot every published product is read, otherwise only the changed ones.
+func (u *productUsecase) regenerateFeed(ctx context.Context, previous *model.ProductFeedSnapshot) (*model.ProductFeedSnapshot, error) {
+	snapshot := &model.ProductFeedSnapshot{
+		GeneratedAt: time.Now(),
+		Items:       make(map[int64]*model.ProductFeedItem),
+	}
+
+	if previous == nil {
+		err := u.ExportWithoutSession(ctx, model.ProductExportCriteria{}, func(product *model.Product) error {
+			snapshot.Items[product.ID] = model.NewProductFeedItem(product)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return snapshot, nil
+	}
+
+	for id, item := range previous.Items {
+		snapshot.Items[id] = item
+	}
+
+	since := previous.GeneratedAt.Add(-feedClockSkew)
+	var cursorAfter int64
+	for {
+		products, err := u.productRepository.FindAllProductsChangedSince(ctx, since, feedPageSize, cursorAfter)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"ctx":         utils.DumpIncomingContext(ctx),
+				"since":       since,
+				"cursorAfter": cursorAfter,
+			}).Error(err)
+			return nil, err
+		}
+
+		for _, product := range products {
+			if product.DeletedAt.Valid {
+				delete(snapshot.Items, product.ID)
+				continue
+			}
+			snapshot.Items[product.ID] = model.NewProductFeedItem(product)
+		}
+
+		if len(products) < feedPageSize {
+			return snapshot, nil
+		}
+		cursorAfter = products[len(products)-1].ID
+	}
+}
+
+func newProductFeedChannel() model.ProductFeedChannel {
+	return model.ProductFeedChannel{
+		Title:             config.ProductFeedTitle(),
+		Link:              config.ProductFeedLink(),
+		Description:       config.ProductFeedDescription(),
+		Currency:          config.ProductFeedCurrency(),
+		ProductLinkFormat: config.ProductFeedProductLinkFormat(),
+	}
+}
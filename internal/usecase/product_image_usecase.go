@@ -0,0 +1,241 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/binus-thesis-team/iam-service/rbac"
+	"github.com/binus-thesis-team/iam-service/utils"
+	"github.com/binus-thesis-team/product-service/internal/config"
+	"github.com/binus-thesis-team/product-service/internal/model"
+	"github.com/sirupsen/logrus"
+)
+
+// galleryMutation changes the gallery of a locked product, it returns the gallery in its new order
+type galleryMutation func(repo model.ProductRepository, gallery []*model.ProductImage) ([]*model.ProductImage, error)
+
+func (u *productUsecase) FindImagesByProductID(ctx context.Context, productID int64) ([]*model.ProductImage, error) {
+	if _, err := u.findByID(ctx, productID); err != nil {
+		return nil, err
+	}
+
+	images, err := u.productRepository.FindAllImagesByProductID(ctx, productID)
+	if err != nil {
+		logrus.WithField("productID", productID).Error(err)
+		return nil, err
+	}
+
+	u.setGalleryImageDerivativeURLs(images)
+	return images, nil
+}
+
+// AttachImage appends an uploaded image to the gallery, it becomes the primary one when asked to
+// or when the gallery has none
+func (u *productUsecase) AttachImage(ctx context.Context, user model.SessionUser, input model.AttachProductImageRequest) (*model.Product, error) {
+	if !user.HasAccess(rbac.ResourceProduct, rbac.ActionCreateAny) {
+		return nil, ErrPermissionDenied
+	}
+
+	if err := input.ValidateDTOAttachProductImageRequest(); err != nil {
+		logrus.WithField("input", utils.Dump(input)).Error(err)
+		return nil, err
+	}
+	if _, ok := u.uploadedImageKey(input.ImageUrl); !ok {
+		return nil, &model.FieldError{Field: "image_url", Message: "Image URL isn't an uploaded image"}
+	}
+
+	return u.mutateGallery(ctx, user, input.ProductID, input.ExpectedVersion, func(repo model.ProductRepository, gallery []*model.ProductImage) ([]*model.ProductImage, error) {
+		if len(gallery) >= config.ProductImagesMaxPerProduct() {
+			return nil, &model.FieldError{Field: "image_url", Message: fmt.Sprintf("A product has at most %d images", config.ProductImagesMaxPerProduct())}
+		}
+		for _, image := range gallery {
+			if image.ImageUrl == input.ImageUrl {
+				return nil, &model.FieldError{Field: "image_url", Message: "The image is already in the gallery"}
+			}
+		}
+
+		// created as a secondary image, the primary flags are saved together once the gallery is settled
+		image := &model.ProductImage{
+			ProductID: input.ProductID,
+			ImageUrl:  input.ImageUrl,
+			AltText:   input.AltText,
+			Position:  len(gallery),
+		}
+		if err := repo.CreateImage(ctx, image); err != nil {
+			return nil, err
+		}
+
+		gallery = append(gallery, image)
+		if input.IsPrimary || primaryGalleryImage(gallery) == nil {
+			setPrimaryGalleryImage(gallery, image.ID)
+		}
+		return gallery, nil
+	})
+}
+
+// ReorderImages orders the gallery as the ids of the input
+func (u *productUsecase) ReorderImages(ctx context.Context, user model.SessionUser, input model.ReorderProductImagesRequest) (*model.Product, error) {
+	if !user.HasAccess(rbac.ResourceProduct, rbac.ActionCreateAny) {
+		return nil, ErrPermissionDenied
+	}
+
+	return u.mutateGallery(ctx, user, input.ProductID, input.ExpectedVersion, func(_ model.ProductRepository, gallery []*model.ProductImage) ([]*model.ProductImage, error) {
+		if err := input.ValidateDTOReorderProductImagesRequest(gallery); err != nil {
+			return nil, err
+		}
+
+		imageByID := make(map[int64]*model.ProductImage, len(gallery))
+		for _, image := range gallery {
+			imageByID[image.ID] = image
+		}
+
+		reordered := make([]*model.ProductImage, 0, len(gallery))
+		for _, id := range input.ImageIDs {
+			reordered = append(reordered, imageByID[id])
+		}
+		return reordered, nil
+	})
+}
+
+func (u *productUsecase) SetPrimaryImage(ctx context.Context, user model.SessionUser, productID, imageID, expectedVersion int64) (*model.Product, error) {
+	if !user.HasAccess(rbac.ResourceProduct, rbac.ActionCreateAny) {
+		return nil, ErrPermissionDenied
+	}
+
+	return u.mutateGallery(ctx, user, productID, expectedVersion, func(_ model.ProductRepository, gallery []*model.ProductImage) ([]*model.ProductImage, error) {
+		if findGalleryImage(gallery, imageID) == nil {
+			return nil, ErrNotFound
+		}
+
+		setPrimaryGalleryImage(gallery, imageID)
+		return gallery, nil
+	})
+}
+
+// DetachImage removes the image from the gallery, the next one becomes primary when it was the primary one.
+// The stored image is left as is.
+func (u *productUsecase) DetachImage(ctx context.Context, user model.SessionUser, productID, imageID, expectedVersion int64) (*model.Product, error) {
+	if !user.HasAccess(rbac.ResourceProduct, rbac.ActionCreateAny) {
+		return nil, ErrPermissionDenied
+	}
+
+	return u.mutateGallery(ctx, user, productID, expectedVersion, func(repo model.ProductRepository, gallery []*model.ProductImage) ([]*model.ProductImage, error) {
+		image := findGalleryImage(gallery, imageID)
+		if image == nil {
+			return nil, ErrNotFound
+		}
+
+		if err := repo.DeleteImage(ctx, productID, imageID); err != nil {
+			return nil, err
+		}
+
+		remaining := make([]*model.ProductImage, 0, len(gallery)-1)
+		for _, other := range gallery {
+			if other.ID != imageID {
+				remaining = append(remaining, other)
+			}
+		}
+		if image.IsPrimary && len(remaining) > 0 {
+			setPrimaryGalleryImage(remaining, remaining[0].ID)
+		}
+		return remaining, nil
+	})
+}
+
+// mutateGallery runs the mutation in a transaction holding the lock of the product row, so the gallery
+// changes of a product are serialized. The positions are renumbered, then the ImageUrl of the product is
+// set to the primary image and its version is bumped.
+func (u *productUsecase) mutateGallery(ctx context.Context, user model.SessionUser, productID, expectedVersion int64, mutate galleryMutation) (*model.Product, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"ctx":             utils.DumpIncomingContext(ctx),
+		"currentUser":     utils.Dump(user),
+		"productID":       productID,
+		"expectedVersion": expectedVersion,
+	})
+
+	var gallery []*model.ProductImage
+	err := u.productRepository.Transaction(ctx, func(repo model.ProductRepository) error {
+		product, err := repo.LockByID(ctx, productID)
+		switch {
+		case err != nil:
+			return err
+		case product == nil:
+			return ErrNotFound
+		case !product.HasVersion(expectedVersion):
+			return ErrVersionConflict
+		}
+
+		gallery, err = repo.FindAllImagesByProductID(ctx, productID)
+		if err != nil {
+			return err
+		}
+
+		gallery, err = mutate(repo, gallery)
+		if err != nil {
+			return err
+		}
+
+		primaryURL := ""
+		for position, image := range gallery {
+			image.Position = position
+			if image.IsPrimary {
+				primaryURL = image.ImageUrl
+			}
+		}
+		if err := repo.UpdateImages(ctx, productID, gallery); err != nil {
+			return err
+		}
+
+		return repo.PatchByID(ctx, user.GetUserID(), productID, map[string]any{"image_url": primaryURL}, product.Version)
+	})
+	var fieldErr *model.FieldError
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrVersionConflict), errors.As(err, &fieldErr):
+		return nil, err
+	default:
+		logger.Error(err)
+		return nil, err
+	}
+
+	product, err := u.findByID(ctx, productID)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	u.setGalleryImageDerivativeURLs(gallery)
+	product.Gallery = gallery
+	return product, nil
+}
+
+func (u *productUsecase) setGalleryImageDerivativeURLs(images []*model.ProductImage) {
+	for _, image := range images {
+		image.Images = u.imageDerivativeURLs(image.ImageUrl)
+	}
+}
+
+func primaryGalleryImage(gallery []*model.ProductImage) *model.ProductImage {
+	for _, image := range gallery {
+		if image.IsPrimary {
+			return image
+		}
+	}
+	return nil
+}
+
+func findGalleryImage(gallery []*model.ProductImage, id int64) *model.ProductImage {
+	for _, image := range gallery {
+		if image.ID == id {
+			return image
+		}
+	}
+	return nil
+}
+
+func setPrimaryGalleryImage(gallery []*model.ProductImage, id int64) {
+	for _, image := range gallery {
+		image.IsPrimary = image.ID == id
+	}
+}
@@ -0,0 +1,151 @@
+package usecase
+
+import (
+	"context"
+	"sort"
+
+	"github.com/binus-thesis-team/iam-service/rbac"
+	"github.com/binus-thesis-team/iam-service/utils"
+	"github.com/binus-thesis-team/product-service/internal/model"
+	"github.com/sirupsen/logrus"
+)
+
+// DryRunUploadFile validates every row of a product file and reports what the import would do,
+// nothing is written to the database or the cache
+func (u *productUsecase) DryRunUploadFile(ctx context.Context, user model.SessionUser, input model.UploadFileProductRequest) (report *model.ProductImportReport, err error) {
+	if !user.HasAccess(rbac.ResourceProduct, rbac.ActionCreateAny) {
+		return nil, ErrPermissionDenied
+	}
+
+	return u.dryRunImport(ctx, user.GetUserID(), input)
+}
+
+// DryRunUploadFileWithoutSession is DryRunUploadFile on behalf of the system user
+func (u *productUsecase) DryRunUploadFileWithoutSession(ctx context.Context, input model.UploadFileProductRequest) (report *model.ProductImportReport, err error) {
+	return u.dryRunImport(ctx, systemUserID, input)
+}
+
+func (u *productUsecase) dryRunImport(ctx context.Context, requesterID int64, input model.UploadFileProductRequest) (*model.ProductImportReport, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"ctx":         utils.DumpIncomingContext(ctx),
+		"requesterID": requesterID,
+		"filename":    input.Filename,
+	})
+
+	header, rows, err := readImportFile(&input)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	report := &model.ProductImportReport{
+		Mode:      input.Mode,
+		DryRun:    true,
+		TotalRows: int64(len(rows)),
+		RowErrors: []*model.ProductImportRowError{},
+	}
+
+	seen := make(map[string]int64)
+	batch := make([]importBatchRow, 0, importBatchSize)
+	for i, v := range rows {
+		rowNumber := int64(i + 2) // the header is row 1
+
+		req, rowErr := parseProductImportRow(header, v, report.Mode)
+		if rowErr != nil {
+			rowErr.RowNumber = rowNumber
+			rejectDryRunRow(report, rowErr)
+			continue
+		}
+
+		if rowErr := checkImportExternalID(report.Mode, seen, rowNumber, req.ExternalID); rowErr != nil {
+			rejectDryRunRow(report, rowErr)
+			continue
+		}
+
+		batch = append(batch, newImportBatchRow(rowNumber, req))
+		if len(batch) == importBatchSize {
+			if err := u.dryRunImportBatch(ctx, report, batch); err != nil {
+				logger.Error(err)
+				return nil, err
+			}
+			batch = batch[:0]
+		}
+	}
+
+	if len(batch) > 0 {
+		if err := u.dryRunImportBatch(ctx, report, batch); err != nil {
+			logger.Error(err)
+			return nil, err
+		}
+	}
+
+	// the rows rejected by their batch come after the rows rejected while parsing
+	sort.SliceStable(report.RowErrors, func(i, j int) bool {
+		return report.RowErrors[i].RowNumber < report.RowErrors[j].RowNumber
+	})
+
+	return report, nil
+}
+
+// dryRunImportBatch looks up the existing products of the batch by external id
+// to tell the rows that would be created from the ones that would be updated or rejected
+func (u *productUsecase) dryRunImportBatch(ctx context.Context, report *model.ProductImportReport, batch []importBatchRow) error {
+	externalIDs := make([]string, 0, len(batch))
+	for _, row := range batch {
+		if externalID := row.product.GetExternalID(); externalID != "" {
+			externalIDs = append(externalIDs, externalID)
+		}
+	}
+
+	products, err := u.productRepository.FindAllByExternalIDs(ctx, externalIDs)
+	if err != nil {
+		return err
+	}
+
+	existing := make(map[string]*model.Product, len(products))
+	for _, product := range products {
+		existing[product.GetExternalID()] = product
+	}
+
+	for _, row := range batch {
+		product, ok := existing[row.product.GetExternalID()]
+
+		switch report.Mode {
+		case model.ProductImportModeUpdateOnly:
+			// the update only touches the products that aren't deleted
+			if !ok || product.DeletedAt.Valid {
+				rejectDryRunRow(report, newImportRowNotSavedError(report.Mode, row.rowNumber))
+				continue
+			}
+			report.UpdatedRows++
+		case model.ProductImportModeUpsert:
+			switch {
+			case !ok && row.createErr != nil:
+				rejectDryRunRow(report, row.createErr)
+			case !ok:
+				report.CreatedRows++
+			case product.DeletedAt.Valid:
+				rejectDryRunRow(report, newImportRowNotSavedError(report.Mode, row.rowNumber))
+			default:
+				report.UpdatedRows++
+			}
+		default:
+			if ok {
+				rejectDryRunRow(report, &model.ProductImportRowError{
+					RowNumber:  row.rowNumber,
+					ColumnName: "external_id",
+					Reason:     "Product with the same external_id already exists",
+				})
+				continue
+			}
+			report.CreatedRows++
+		}
+	}
+
+	return nil
+}
+
+func rejectDryRunRow(report *model.ProductImportReport, rowErr *model.ProductImportRowError) {
+	report.FailedRows++
+	report.RowErrors = append(report.RowErrors, rowErr)
+}
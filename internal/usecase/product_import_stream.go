@@ -0,0 +1,167 @@
+package usecase
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/binus-thesis-team/iam-service/utils"
+	"github.com/binus-thesis-team/product-service/internal/model"
+	"github.com/sirupsen/logrus"
+)
+
+// UploadStreamWithoutSession imports the rows on behalf of the system user while they are uploaded,
+// a batch is saved before the next one is read so a slow database slows the upload down
+func (u *productUsecase) UploadStreamWithoutSession(ctx context.Context, input model.UploadStreamProductRequest) (report *model.ProductImportReport, err error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"ctx":      utils.DumpIncomingContext(ctx),
+		"filename": input.Filename,
+	})
+
+	if input.Mode == "" {
+		input.Mode = model.ProductImportModeCreateOnly
+	}
+	if !input.Mode.IsValid() {
+		return nil, ErrInvalidImportMode
+	}
+
+	var next importRowReader
+	if input.NextProduct != nil {
+		next = newImportProductStreamReader(input.NextProduct, input.Mode)
+	} else {
+		next, err = newImportCSVStreamReader(input.Content, input.Mode, input.ColumnMapping)
+		if err != nil {
+			logger.Error(err)
+			return nil, err
+		}
+	}
+
+	recorder := &importReportRecorder{report: &model.ProductImportReport{
+		Mode:      input.Mode,
+		RowErrors: []*model.ProductImportRowError{},
+	}}
+	if err := u.importRows(ctx, systemUserID, input.Mode, next, recorder); err != nil {
+		logger.WithField("report", utils.Dump(recorder.report)).Error(err)
+		return nil, err
+	}
+
+	// the rows of a batch are saved concurrently
+	sort.SliceStable(recorder.report.RowErrors, func(i, j int) bool {
+		return recorder.report.RowErrors[i].RowNumber < recorder.report.RowErrors[j].RowNumber
+	})
+
+	return recorder.report, nil
+}
+
+// newImportCSVStreamReader resolves the header of the csv content, then reads a row on every call.
+// The row number is the line the row starts at, a malformed line is reported as a row error.
+func newImportCSVStreamReader(content io.Reader, mode model.ProductImportMode, mapping map[string]string) (importRowReader, error) {
+	br := bufio.NewReader(content)
+	if magic, _ := br.Peek(len(xlsxMagic)); bytes.Equal(magic, xlsxMagic) {
+		return nil, fmt.Errorf("%w: xlsx files can't be streamed, upload them whole", ErrInvalidImportFile)
+	}
+	if start, _ := br.Peek(br.Buffered()); isJSONLContent(start) {
+		return nil, fmt.Errorf("%w: jsonl files can't be streamed, upload them whole", ErrInvalidImportFile)
+	}
+
+	csvReader := csv.NewReader(br)
+	csvReader.Comma = ','
+	// short and long rows are reported per row instead of failing the whole file
+	csvReader.FieldsPerRecord = -1
+
+	headerRecord, err := csvReader.Read()
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImportFile, err)
+	}
+
+	header, err := resolveImportHeader(headerRecord, mapping)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkImportHeaderMode(header, mode); err != nil {
+		return nil, err
+	}
+
+	return func() (*importRow, error) {
+		record, err := csvReader.Read()
+		var parseErr *csv.ParseError
+		switch {
+		case errors.As(err, &parseErr):
+			return &importRow{
+				rowNumber: int64(parseErr.StartLine),
+				rowErr:    &model.ProductImportRowError{Reason: parseErr.Err.Error()},
+			}, nil
+		case err != nil:
+			return nil, err
+		}
+
+		line, _ := csvReader.FieldPos(0)
+		row := &importRow{rowNumber: int64(line)}
+		row.input, row.rowErr = parseProductImportRow(header, record, mode)
+		return row, nil
+	}, nil
+}
+
+// newImportProductStreamReader validates every streamed product like an import row,
+// its row number is its position in the stream starting at 1
+func newImportProductStreamReader(next func() (*model.Product, error), mode model.ProductImportMode) importRowReader {
+	var rowNumber int64
+	return func() (*importRow, error) {
+		product, err := next()
+		if err != nil {
+			return nil, err
+		}
+		rowNumber++
+
+		input := &model.CreateProductRequest{
+			ExternalID:  product.GetExternalID(),
+			Name:        product.Name,
+			Price:       product.Price,
+			Stock:       product.Stock,
+			Description: product.Description,
+			ImageUrl:    product.ImageUrl,
+		}
+		if err := validateImportRow(input, mode); err != nil {
+			return &importRow{rowNumber: rowNumber, rowErr: newProductImportRowError(err)}, nil
+		}
+
+		return &importRow{rowNumber: rowNumber, input: input}, nil
+	}
+}
+
+// importReportRecorder records the outcome of the rows into a report
+type importReportRecorder struct {
+	mu     sync.Mutex
+	report *model.ProductImportReport
+}
+
+func (r *importReportRecorder) recordCreated() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.report.TotalRows++
+	r.report.CreatedRows++
+}
+
+func (r *importReportRecorder) recordUpdated() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.report.TotalRows++
+	r.report.UpdatedRows++
+}
+
+func (r *importReportRecorder) recordFailed(rowErr *model.ProductImportRowError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.report.TotalRows++
+	r.report.FailedRows++
+	r.report.RowErrors = append(r.report.RowErrors, rowErr)
+}
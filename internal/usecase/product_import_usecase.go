@@ -0,0 +1,754 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/binus-thesis-team/iam-service/rbac"
+	"github.com/binus-thesis-team/iam-service/utils"
+	"github.com/binus-thesis-team/product-service/internal/config"
+	"github.com/binus-thesis-team/product-service/internal/model"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// systemUserID is the requester of the imports that come without a session, e.g. through gRPC
+const systemUserID int64 = 1
+
+// importJobFlushInterval is how often the progress of a running import job is saved
+const importJobFlushInterval = 2 * time.Second
+
+// importBatchSize is the number of rows saved per statement by the imports keyed by external id
+const importBatchSize = 500
+
+// errImportJobInterrupted fails the jobs stopped by a shutdown or a crash before they were finished
+var errImportJobInterrupted = errors.New("the import was interrupted by a restart of the service, upload the file again")
+
+// UploadFile queues the import of a product file, the returned job can be polled for its progress
+func (u *productUsecase) UploadFile(ctx context.Context, user model.SessionUser, input model.UploadFileProductRequest) (job *model.ProductImportJob, err error) {
+	if !user.HasAccess(rbac.ResourceProduct, rbac.ActionCreateAny) {
+		return nil, ErrPermissionDenied
+	}
+
+	return u.createImportJob(ctx, user.GetUserID(), input)
+}
+
+// UploadFileWithoutSession queues the import of a product file on behalf of the system user
+func (u *productUsecase) UploadFileWithoutSession(ctx context.Context, input model.UploadFileProductRequest) (job *model.ProductImportJob, err error) {
+	return u.createImportJob(ctx, systemUserID, input)
+}
+
+func (u *productUsecase) FindImportJobByID(ctx context.Context, user model.SessionUser, id int64) (job *model.ProductImportJob, err error) {
+	if !user.HasAccess(rbac.ResourceProduct, rbac.ActionViewAny) {
+		return nil, ErrPermissionDenied
+	}
+
+	job, err = u.productImportJobRepository.FindByID(ctx, id)
+	if err != nil {
+		logrus.WithField("id", id).Error(err)
+		return nil, err
+	}
+
+	if job == nil {
+		return nil, ErrNotFound
+	}
+
+	return job, nil
+}
+
+func (u *productUsecase) FindImportJobRowErrors(ctx context.Context, user model.SessionUser, id int64) (rowErrors []*model.ProductImportRowError, err error) {
+	job, err := u.FindImportJobByID(ctx, user, id)
+	if err != nil {
+		return nil, err
+	}
+
+	rowErrors, err = u.productImportJobRepository.FindRowErrorsByJobID(ctx, job.ID)
+	if err != nil {
+		logrus.WithField("id", id).Error(err)
+		return nil, err
+	}
+
+	return rowErrors, nil
+}
+
+func (u *productUsecase) createImportJob(ctx context.Context, requesterID int64, input model.UploadFileProductRequest) (*model.ProductImportJob, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"ctx":         utils.DumpIncomingContext(ctx),
+		"requesterID": requesterID,
+		"filename":    input.Filename,
+	})
+
+	// the slot is taken before the file is read, it's released once the job is finished
+	select {
+	case u.importJobSlots <- struct{}{}:
+	default:
+		return nil, ErrImportQueueFull
+	}
+	started := false
+	defer func() {
+		if !started {
+			<-u.importJobSlots
+		}
+	}()
+
+	header, rows, err := readImportFile(&input)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	job := &model.ProductImportJob{
+		Filename:  input.Filename,
+		Mode:      input.Mode,
+		Atomic:    input.Atomic,
+		Status:    model.ProductImportJobStatusQueued,
+		CreatedBy: requesterID,
+	}
+
+	if err := u.productImportJobRepository.Create(ctx, job); err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	// the job outlives the request, so it gets its own copy and context
+	runningJob := *job
+	started = true
+	u.importJobs.Add(1)
+	go func() {
+		defer u.importJobs.Done()
+		defer func() { <-u.importJobSlots }()
+
+		u.runImportJob(context.Background(), requesterID, &runningJob, header, rows)
+	}()
+
+	return job, nil
+}
+
+// FailStaleImportJobs fails the jobs whose process is gone, e.g. killed by a deploy. A live job is saved at
+// least every importJobFlushInterval, queued ones included, so a job not saved for ImportJobStaleAfter is stale.
+func (u *productUsecase) FailStaleImportJobs(ctx context.Context) (int64, error) {
+	count, err := u.productImportJobRepository.FailStale(ctx, time.Now().Add(-config.ImportJobStaleAfter()), errImportJobInterrupted.Error())
+	if err != nil {
+		logrus.WithField("ctx", utils.DumpIncomingContext(ctx)).Error(err)
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// DrainImportJobs fails the queued jobs and waits for the running ones until ctx is done, a job still running
+// then is failed by FailStaleImportJobs once the process is gone
+func (u *productUsecase) DrainImportJobs(ctx context.Context) error {
+	u.closeImportJobsOnce.Do(func() { close(u.importJobsClosed) })
+
+	done := make(chan struct{})
+	go func() {
+		u.importJobs.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// readImportFile resolves the mode, the header and the rows of an import file,
+// the unresolved columns are reported before any row is processed
+func readImportFile(input *model.UploadFileProductRequest) (*importHeader, [][]string, error) {
+	if input.Mode == "" {
+		input.Mode = model.ProductImportModeCreateOnly
+	}
+	if !input.Mode.IsValid() {
+		return nil, nil, ErrInvalidImportMode
+	}
+
+	records, err := readImportRecords(input.ProductFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidImportFile, err)
+	}
+
+	return resolveImportRecords(records, input.ColumnMapping, input.Mode)
+}
+
+// resolveImportRecords splits the header record from the rows and resolves it
+func resolveImportRecords(records [][]string, mapping map[string]string, mode model.ProductImportMode) (*importHeader, [][]string, error) {
+	var headerRecord []string
+	var rows [][]string
+	if len(records) > 0 {
+		headerRecord, rows = records[0], records[1:]
+	}
+
+	header, err := resolveImportHeader(headerRecord, mapping)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := checkImportHeaderMode(header, mode); err != nil {
+		return nil, nil, err
+	}
+
+	return header, rows, nil
+}
+
+// checkImportHeaderMode requires the external_id column in the modes keyed by it
+func checkImportHeaderMode(header *importHeader, mode model.ProductImportMode) error {
+	if mode.IsKeyedByExternalID() && !header.has("external_id") {
+		return &model.ImportHeaderError{MissingColumns: []string{"external_id"}}
+	}
+	return nil
+}
+
+func (u *productUsecase) runImportJob(ctx context.Context, requesterID int64, job *model.ProductImportJob, header *importHeader, rows [][]string) {
+	logger := logrus.WithField("jobID", job.ID)
+
+	tracker := newImportJobTracker(u.productImportJobRepository, job)
+
+	// Acquire semaphore, the job stays queued until then
+	if err := u.waitImportJobTurn(ctx, tracker); err != nil {
+		logger.Error(err)
+		tracker.finish(ctx, err)
+		return
+	}
+	defer func() { <-u.importJobSemaphore }() // Release semaphore
+
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("import job panic: ", r)
+			tracker.finish(ctx, fmt.Errorf("unexpected error: %v", r))
+		}
+	}()
+
+	if err := tracker.start(ctx, int64(len(rows))); err != nil {
+		logger.Error(err)
+		tracker.finish(ctx, err)
+		return
+	}
+
+	stopFlush := tracker.flushEvery(ctx, importJobFlushInterval)
+	var err error
+	switch {
+	case job.Atomic:
+		err = u.importRowsAtomically(ctx, requesterID, job.Mode, header, rows, tracker)
+	default:
+		err = u.importRows(ctx, requesterID, job.Mode, newImportRecordsReader(header, rows, job.Mode), tracker)
+	}
+	stopFlush()
+
+	if err != nil {
+		logger.Error(err)
+	}
+	tracker.finish(ctx, err)
+}
+
+// waitImportJobTurn acquires the semaphore, the queued job is saved every importJobFlushInterval meanwhile
+// so it isn't taken for a stale one. It fails once the jobs are drained.
+func (u *productUsecase) waitImportJobTurn(ctx context.Context, tracker *importJobTracker) error {
+	ticker := time.NewTicker(importJobFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case u.importJobSemaphore <- struct{}{}:
+			// both may be ready, a drained job never starts
+			select {
+			case <-u.importJobsClosed:
+				<-u.importJobSemaphore
+				return errImportJobInterrupted
+			default:
+				return nil
+			}
+		case <-u.importJobsClosed:
+			return errImportJobInterrupted
+		case <-ticker.C:
+			if err := tracker.flush(ctx); err != nil {
+				logrus.WithField("jobID", tracker.job.ID).Error(err)
+			}
+		}
+	}
+}
+
+// importRecorder receives the outcome of every imported row
+type importRecorder interface {
+	recordCreated()
+	recordUpdated()
+	recordFailed(rowErr *model.ProductImportRowError)
+}
+
+// importRow is a row read from an import source, rowErr is set when the row can't be parsed
+type importRow struct {
+	rowNumber int64
+	input     *model.CreateProductRequest
+	rowErr    *model.ProductImportRowError
+}
+
+// importRowReader returns the next row of an import source, io.EOF once every row is read
+type importRowReader func() (*importRow, error)
+
+// newImportRecordsReader reads the rows of a file already read in memory
+func newImportRecordsReader(header *importHeader, rows [][]string, mode model.ProductImportMode) importRowReader {
+	i := 0
+	return func() (*importRow, error) {
+		if i >= len(rows) {
+			return nil, io.EOF
+		}
+		row := &importRow{rowNumber: int64(i + 2)} // the header is row 1
+		row.input, row.rowErr = parseProductImportRow(header, rows[i], mode)
+		i++
+		return row, nil
+	}
+}
+
+// importBatchRow is a parsed row waiting for its batch to be saved
+type importBatchRow struct {
+	rowNumber int64
+	product   *model.Product
+	// createErr rejects the row once it turns out to create a product, nil when it follows the create rules
+	createErr *model.ProductImportRowError
+}
+
+func newImportBatchRow(rowNumber int64, input *model.CreateProductRequest) importBatchRow {
+	row := importBatchRow{rowNumber: rowNumber, product: input.ToProduct()}
+	if err := input.ValidateDTOCreateProductRequest(); err != nil {
+		row.createErr = newProductImportRowError(err)
+		row.createErr.RowNumber = rowNumber
+	}
+	return row
+}
+
+// importRows saves the rows in batches of importBatchSize as they are read,
+// the next batch isn't read before the current one is saved.
+// The batches keyed by external id are saved with one statement, the others with concurrent inserts.
+func (u *productUsecase) importRows(ctx context.Context, requesterID int64, mode model.ProductImportMode, next importRowReader, recorder importRecorder) error {
+	// the first row of an external id wins, a single statement can't touch the same product twice
+	seen := make(map[string]int64)
+	batch := make([]importBatchRow, 0, importBatchSize)
+
+	for {
+		row, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if row.rowErr != nil {
+			row.rowErr.RowNumber = row.rowNumber
+			recorder.recordFailed(row.rowErr)
+			continue
+		}
+
+		if rowErr := checkImportExternalID(mode, seen, row.rowNumber, row.input.ExternalID); rowErr != nil {
+			recorder.recordFailed(rowErr)
+			continue
+		}
+
+		batch = append(batch, newImportBatchRow(row.rowNumber, row.input))
+		if len(batch) == importBatchSize {
+			u.saveImportRows(ctx, requesterID, mode, batch, recorder)
+			batch = batch[:0]
+		}
+	}
+
+	if len(batch) > 0 {
+		u.saveImportRows(ctx, requesterID, mode, batch, recorder)
+	}
+
+	return nil
+}
+
+func (u *productUsecase) saveImportRows(ctx context.Context, requesterID int64, mode model.ProductImportMode, batch []importBatchRow, recorder importRecorder) {
+	if mode.IsKeyedByExternalID() {
+		u.saveImportBatch(ctx, requesterID, mode, batch, recorder)
+		return
+	}
+
+	semaphore := make(chan struct{}, config.WorkerConcurrency())
+
+	var wg sync.WaitGroup
+	for _, row := range batch {
+		wg.Add(1)
+
+		// Acquire semaphore
+		semaphore <- struct{}{}
+
+		go func(row importBatchRow) {
+			defer wg.Done()
+			defer func() { <-semaphore }() // Release semaphore
+
+			err := u.productRepository.Create(ctx, requesterID, row.product)
+			switch {
+			case err == nil:
+				recorder.recordCreated()
+			case errors.Is(err, gorm.ErrDuplicatedKey):
+				recorder.recordFailed(&model.ProductImportRowError{
+					RowNumber:  row.rowNumber,
+					ColumnName: "external_id",
+					Reason:     "Product with the same external_id already exists",
+				})
+			default:
+				logrus.WithField("rowNumber", row.rowNumber).Error(err)
+				recorder.recordFailed(&model.ProductImportRowError{
+					RowNumber: row.rowNumber,
+					Reason:    "failed to save product",
+				})
+			}
+		}(row)
+	}
+
+	// Wait for all goroutines to finish
+	wg.Wait()
+}
+
+// saveImportBatch saves a batch keyed by external id with a single statement
+func (u *productUsecase) saveImportBatch(ctx context.Context, requesterID int64, mode model.ProductImportMode, batch []importBatchRow, recorder importRecorder) {
+	if mode == model.ProductImportModeUpsert {
+		valid, rejected, err := filterInvalidImportCreates(ctx, u.productRepository, batch)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"firstRowNumber": batch[0].rowNumber,
+				"lastRowNumber":  batch[len(batch)-1].rowNumber,
+			}).Error(err)
+			for _, row := range batch {
+				recorder.recordFailed(&model.ProductImportRowError{RowNumber: row.rowNumber, Reason: "failed to save product"})
+			}
+			return
+		}
+
+		for _, rowErr := range rejected {
+			recorder.recordFailed(rowErr)
+		}
+		if len(valid) == 0 {
+			return
+		}
+		batch = valid
+	}
+
+	products := make([]*model.Product, 0, len(batch))
+	for _, row := range batch {
+		products = append(products, row.product)
+	}
+
+	var results []model.ProductUpsertResult
+	var err error
+	if mode == model.ProductImportModeUpdateOnly {
+		results, err = u.productRepository.UpdateByExternalIDs(ctx, requesterID, products)
+	} else {
+		results, err = u.productRepository.UpsertByExternalIDs(ctx, requesterID, products)
+	}
+
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"firstRowNumber": batch[0].rowNumber,
+			"lastRowNumber":  batch[len(batch)-1].rowNumber,
+		}).Error(err)
+		for _, row := range batch {
+			recorder.recordFailed(&model.ProductImportRowError{RowNumber: row.rowNumber, Reason: "failed to save product"})
+		}
+		return
+	}
+
+	resultsByExternalID := make(map[string]model.ProductUpsertResult, len(results))
+	for _, result := range results {
+		resultsByExternalID[result.ExternalID] = result
+	}
+
+	for _, row := range batch {
+		result, ok := resultsByExternalID[row.product.GetExternalID()]
+		switch {
+		case !ok:
+			recorder.recordFailed(newImportRowNotSavedError(mode, row.rowNumber))
+		case result.Created:
+			recorder.recordCreated()
+		default:
+			recorder.recordUpdated()
+		}
+	}
+}
+
+// filterInvalidImportCreates leaves out of an upsert batch the rows that would create a product
+// against the create rules, the rows updating a product, even a deleted one, only follow the import rules
+func filterInvalidImportCreates(ctx context.Context, repo model.ProductRepository, batch []importBatchRow) (valid []importBatchRow, rejected []*model.ProductImportRowError, err error) {
+	externalIDs := make([]string, 0, len(batch))
+	for _, row := range batch {
+		if row.createErr != nil {
+			externalIDs = append(externalIDs, row.product.GetExternalID())
+		}
+	}
+	if len(externalIDs) == 0 {
+		return batch, nil, nil
+	}
+
+	existing, err := repo.FindAllByExternalIDs(ctx, externalIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	exists := make(map[string]bool, len(existing))
+	for _, product := range existing {
+		exists[product.GetExternalID()] = true
+	}
+
+	valid = make([]importBatchRow, 0, len(batch))
+	for _, row := range batch {
+		if row.createErr != nil && !exists[row.product.GetExternalID()] {
+			rejected = append(rejected, row.createErr)
+			continue
+		}
+		valid = append(valid, row)
+	}
+
+	return valid, rejected, nil
+}
+
+// newImportRowNotSavedError is the error of a keyed row left out of the results of its batch, an upsert
+// leaves out the soft deleted products only
+func newImportRowNotSavedError(mode model.ProductImportMode, rowNumber int64) *model.ProductImportRowError {
+	rowErr := &model.ProductImportRowError{RowNumber: rowNumber, ColumnName: "external_id", Reason: "Product not found"}
+	if mode == model.ProductImportModeUpsert {
+		rowErr.Reason = "Product is deleted, restore it before importing it"
+	}
+	return rowErr
+}
+
+// importRowRejection is the invalid row rolling back an atomic import
+type importRowRejection struct {
+	rowErr *model.ProductImportRowError
+}
+
+func (e *importRowRejection) Error() string {
+	return fmt.Sprintf("import rolled back, row %d is invalid: %s", e.rowErr.RowNumber, e.rowErr.Reason)
+}
+
+// importRowsAtomically saves every row in a single transaction with batched statements,
+// the first invalid row rolls back the whole import
+func (u *productUsecase) importRowsAtomically(ctx context.Context, requesterID int64, mode model.ProductImportMode, header *importHeader, rows [][]string, tracker *importJobTracker) error {
+	var created, updated int64
+	err := u.productRepository.Transaction(ctx, func(repo model.ProductRepository) error {
+		seen := make(map[string]int64)
+		batch := make([]importBatchRow, 0, importBatchSize)
+		for i, v := range rows {
+			rowNumber := int64(i + 2) // the header is row 1
+
+			input, rowErr := parseProductImportRow(header, v, mode)
+			if rowErr != nil {
+				rowErr.RowNumber = rowNumber
+				return &importRowRejection{rowErr: rowErr}
+			}
+
+			if rowErr := checkImportExternalID(mode, seen, rowNumber, input.ExternalID); rowErr != nil {
+				return &importRowRejection{rowErr: rowErr}
+			}
+
+			batch = append(batch, newImportBatchRow(rowNumber, input))
+			if len(batch) < importBatchSize && i < len(rows)-1 {
+				continue
+			}
+
+			batchCreated, batchUpdated, err := saveAtomicImportBatch(ctx, repo, requesterID, mode, batch)
+			if err != nil {
+				return err
+			}
+			created += batchCreated
+			updated += batchUpdated
+			batch = batch[:0]
+		}
+
+		return nil
+	})
+
+	var rejection *importRowRejection
+	switch {
+	case err == nil:
+		tracker.recordSaved(created, updated)
+		return nil
+	case errors.As(err, &rejection):
+		tracker.recordFailed(rejection.rowErr)
+		return err
+	default:
+		return err
+	}
+}
+
+// saveAtomicImportBatch saves a batch within the transaction of repo,
+// a row the batch can't be saved for is returned as an *importRowRejection
+func saveAtomicImportBatch(ctx context.Context, repo model.ProductRepository, requesterID int64, mode model.ProductImportMode, batch []importBatchRow) (created, updated int64, err error) {
+	products := make([]*model.Product, 0, len(batch))
+	externalIDs := make([]string, 0, len(batch))
+	for _, row := range batch {
+		products = append(products, row.product)
+		if externalID := row.product.GetExternalID(); externalID != "" {
+			externalIDs = append(externalIDs, externalID)
+		}
+	}
+
+	switch mode {
+	case model.ProductImportModeUpdateOnly:
+		results, err := repo.UpdateByExternalIDs(ctx, requesterID, products)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		found := make(map[string]bool, len(results))
+		for _, result := range results {
+			found[result.ExternalID] = true
+		}
+		for _, row := range batch {
+			if !found[row.product.GetExternalID()] {
+				return 0, 0, &importRowRejection{rowErr: newImportRowNotSavedError(mode, row.rowNumber)}
+			}
+		}
+
+		return 0, int64(len(results)), nil
+	case model.ProductImportModeUpsert:
+		_, rejected, err := filterInvalidImportCreates(ctx, repo, batch)
+		if err != nil {
+			return 0, 0, err
+		}
+		if len(rejected) > 0 {
+			return 0, 0, &importRowRejection{rowErr: rejected[0]}
+		}
+
+		results, err := repo.UpsertByExternalIDs(ctx, requesterID, products)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		found := make(map[string]bool, len(results))
+		for _, result := range results {
+			found[result.ExternalID] = true
+		}
+		for _, row := range batch {
+			if !found[row.product.GetExternalID()] {
+				return 0, 0, &importRowRejection{rowErr: newImportRowNotSavedError(mode, row.rowNumber)}
+			}
+		}
+
+		for _, result := range results {
+			if result.Created {
+				created++
+			} else {
+				updated++
+			}
+		}
+
+		return created, updated, nil
+	default:
+		existing, err := repo.FindAllByExternalIDs(ctx, externalIDs)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		if len(existing) > 0 {
+			exists := make(map[string]bool, len(existing))
+			for _, product := range existing {
+				exists[product.GetExternalID()] = true
+			}
+			for _, row := range batch {
+				if exists[row.product.GetExternalID()] {
+					return 0, 0, &importRowRejection{rowErr: &model.ProductImportRowError{
+						RowNumber:  row.rowNumber,
+						ColumnName: "external_id",
+						Reason:     "Product with the same external_id already exists",
+					}}
+				}
+			}
+		}
+
+		if err := repo.CreateMany(ctx, requesterID, products); err != nil {
+			return 0, 0, err
+		}
+
+		return int64(len(products)), 0, nil
+	}
+}
+
+// checkImportExternalID rejects a row without an external id in the modes keyed by it,
+// and a row repeating the external id of a previous row, seen being the row of every external id so far
+func checkImportExternalID(mode model.ProductImportMode, seen map[string]int64, rowNumber int64, externalID string) *model.ProductImportRowError {
+	if externalID == "" {
+		if mode.IsKeyedByExternalID() {
+			return &model.ProductImportRowError{
+				RowNumber:  rowNumber,
+				ColumnName: "external_id",
+				Reason:     fmt.Sprintf("External ID is required in %s mode", mode),
+			}
+		}
+		return nil
+	}
+
+	if firstRowNumber, ok := seen[externalID]; ok {
+		return &model.ProductImportRowError{
+			RowNumber:  rowNumber,
+			ColumnName: "external_id",
+			Reason:     fmt.Sprintf("Duplicate external_id, already in row %d", firstRowNumber),
+		}
+	}
+	seen[externalID] = rowNumber
+
+	return nil
+}
+
+// parseProductImportRow converts an import row into a create request, see validateImportRow
+func parseProductImportRow(header *importHeader, v []string, mode model.ProductImportMode) (*model.CreateProductRequest, *model.ProductImportRowError) {
+	values := make(map[string]string, len(model.ProductImportFields))
+	for _, field := range model.ProductImportFields {
+		value, ok := header.value(v, field.Name)
+		if !ok && field.Required {
+			return nil, &model.ProductImportRowError{ColumnName: field.Name, Reason: "Value is missing"}
+		}
+		values[field.Name] = value
+	}
+
+	price, err := strconv.ParseFloat(values["price"], 64)
+	if err != nil {
+		return nil, &model.ProductImportRowError{ColumnName: "price", Reason: "Price must be a number"}
+	}
+
+	stock, err := strconv.ParseInt(values["stock"], 10, 64)
+	if err != nil {
+		return nil, &model.ProductImportRowError{ColumnName: "stock", Reason: "Stock must be an integer"}
+	}
+
+	input := &model.CreateProductRequest{
+		ExternalID:  values["external_id"],
+		Name:        values["name"],
+		Price:       price,
+		Stock:       stock,
+		Description: values["description"],
+		ImageUrl:    values["image_url"],
+	}
+
+	if err := validateImportRow(input, mode); err != nil {
+		return nil, newProductImportRowError(err)
+	}
+
+	return input, nil
+}
+
+// validateImportRow validates a row with the create rules. The rows of the modes keyed by external id
+// may update a product, they are validated with the import rules, and with the create rules once they
+// turn out to create one, see filterInvalidImportCreates.
+func validateImportRow(input *model.CreateProductRequest, mode model.ProductImportMode) error {
+	if mode.IsKeyedByExternalID() {
+		return input.ValidateDTOImportProductRequest()
+	}
+	return input.ValidateDTOCreateProductRequest()
+}
+
+func newProductImportRowError(err error) *model.ProductImportRowError {
+	var fieldErr *model.FieldError
+	if errors.As(err, &fieldErr) {
+		return &model.ProductImportRowError{ColumnName: fieldErr.Field, Reason: fieldErr.Message}
+	}
+
+	return &model.ProductImportRowError{Reason: err.Error()}
+}
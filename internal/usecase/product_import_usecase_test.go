@@ -0,0 +1,95 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+
+	"github.com/binus-thesis-team/product-service/internal/model"
+)
+
+func TestParseProductImportRow_ValidatesTheRowsCreatingAProductWithTheCreateRules(t *testing.T) {
+	header, err := resolveImportHeader(model.ProductImportHeader(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		row        []string
+		mode       model.ProductImportMode
+		wantColumn string
+	}{
+		{name: "valid", row: []string{"", "Kopi", "25000", "10", "Kopi tubruk", "https://example.com/kopi.png"}, mode: model.ProductImportModeCreateOnly},
+		{name: "sold out created", row: []string{"", "Kopi", "25000", "0", "Kopi tubruk", "https://example.com/kopi.png"}, mode: model.ProductImportModeCreateOnly, wantColumn: "stock"},
+		{name: "no description created", row: []string{"", "Kopi", "25000", "10", "", "https://example.com/kopi.png"}, mode: model.ProductImportModeCreateOnly, wantColumn: "description"},
+		{name: "no image created", row: []string{"", "Kopi", "25000", "10", "Kopi tubruk", ""}, mode: model.ProductImportModeCreateOnly, wantColumn: "image_url"},
+		{name: "sold out updated", row: []string{"KOPI-1", "Kopi", "25000", "0", "", ""}, mode: model.ProductImportModeUpdateOnly},
+		{name: "sold out upserted", row: []string{"KOPI-1", "Kopi", "25000", "0", "", ""}, mode: model.ProductImportModeUpsert},
+		{name: "negative stock updated", row: []string{"KOPI-1", "Kopi", "25000", "-1", "", ""}, mode: model.ProductImportModeUpdateOnly, wantColumn: "stock"},
+		{name: "no price upserted", row: []string{"KOPI-1", "Kopi", "0", "10", "", ""}, mode: model.ProductImportModeUpsert, wantColumn: "price"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, rowErr := parseProductImportRow(header, tt.row, tt.mode)
+			switch {
+			case tt.wantColumn == "" && rowErr != nil:
+				t.Errorf("parseProductImportRow() = %+v, want the row accepted", rowErr)
+			case tt.wantColumn != "" && (rowErr == nil || rowErr.ColumnName != tt.wantColumn):
+				t.Errorf("parseProductImportRow() = %+v, want the row rejected on %s", rowErr, tt.wantColumn)
+			}
+		})
+	}
+}
+
+func TestProductUsecase_UpsertImportRejectsTheCreatedRowsBreakingTheCreateRules(t *testing.T) {
+	externalID := "KOPI-1"
+	productRepository := &fakeProductRepository{products: []*model.Product{
+		{ID: 1, ExternalID: &externalID, Name: "Kopi", Price: 25000, Stock: 10, Description: "Kopi tubruk", ImageUrl: "https://example.com/kopi.png"},
+	}}
+	u := &productUsecase{productRepository: productRepository}
+
+	// an exported sold out product updates its product, the same row can't create a new one
+	batch := []importBatchRow{
+		newImportBatchRow(2, &model.CreateProductRequest{ExternalID: "KOPI-1", Name: "Kopi", Price: 25000, Stock: 0}),
+		newImportBatchRow(3, &model.CreateProductRequest{ExternalID: "TEH-1", Name: "Teh", Price: 15000, Stock: 0}),
+		newImportBatchRow(4, &model.CreateProductRequest{ExternalID: "GULA-1", Name: "Gula", Price: 12000, Stock: 5, Description: "Gula aren", ImageUrl: "https://example.com/gula.png"}),
+	}
+
+	assertReport := func(t *testing.T, report *model.ProductImportReport) {
+		t.Helper()
+		if report.CreatedRows != 1 || report.UpdatedRows != 1 || report.FailedRows != 1 {
+			t.Errorf("report = %d created, %d updated, %d failed, want 1 of each", report.CreatedRows, report.UpdatedRows, report.FailedRows)
+		}
+		if len(report.RowErrors) != 1 || report.RowErrors[0].RowNumber != 3 || report.RowErrors[0].ColumnName != "stock" {
+			t.Errorf("row errors = %+v, want the row 3 rejected on the stock", report.RowErrors)
+		}
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		report := &model.ProductImportReport{Mode: model.ProductImportModeUpsert}
+		if err := u.dryRunImportBatch(context.Background(), report, batch); err != nil {
+			t.Fatal(err)
+		}
+		assertReport(t, report)
+	})
+
+	t.Run("import", func(t *testing.T) {
+		recorder := &importReportRecorder{report: &model.ProductImportReport{Mode: model.ProductImportModeUpsert}}
+		u.saveImportBatch(context.Background(), systemUserID, model.ProductImportModeUpsert, batch, recorder)
+		assertReport(t, recorder.report)
+
+		if got := productRepository.names(); len(got) != 2 || got[0] != "Gula" || got[1] != "Kopi" {
+			t.Errorf("products = %v, want Gula and Kopi", got)
+		}
+		if productRepository.products[0].Stock != 0 {
+			t.Error("the sold out row didn't update its product")
+		}
+	})
+
+	t.Run("atomic import", func(t *testing.T) {
+		_, _, err := saveAtomicImportBatch(context.Background(), productRepository, systemUserID, model.ProductImportModeUpsert, batch)
+		if rejection, ok := err.(*importRowRejection); !ok || rejection.rowErr.RowNumber != 3 {
+			t.Errorf("saveAtomicImportBatch() = %v, want the row 3 rejected", err)
+		}
+	})
+}
@@ -3,26 +3,48 @@ package usecase
 import (
 	"bytes"
 	"context"
-	"encoding/csv"
-	"io"
-	"os"
-	"strconv"
-	"strings"
+	"errors"
+	"path"
 	"sync"
 
 	"github.com/binus-thesis-team/iam-service/rbac"
 	"github.com/binus-thesis-team/iam-service/utils"
+	"github.com/binus-thesis-team/product-service/internal/config"
 	"github.com/binus-thesis-team/product-service/internal/model"
 	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 )
 
 type productUsecase struct {
-	productRepository model.ProductRepository
+	productRepository          model.ProductRepository
+	productImportJobRepository model.ProductImportJobRepository
+	imageStore                 model.ImageStore
+	requestCounter             model.ProductRequestCounter
+	importJobSemaphore         chan struct{}
+
+	// importJobSlots bounds the jobs of createImportJob queued or running, they hold their file in memory
+	importJobSlots chan struct{}
+	importJobs     sync.WaitGroup
+	// importJobsClosed is closed by DrainImportJobs, the queued jobs are failed instead of started
+	importJobsClosed    chan struct{}
+	closeImportJobsOnce sync.Once
+
+	// feedSnapshot is the last merchant feed generated by WriteFeed
+	feedSnapshot *model.ProductFeedSnapshot
+	feedMutex    sync.Mutex
 }
 
-func NewProductUsecase(productRepository model.ProductRepository) model.ProductUsecase {
+// NewProductUsecase :nodoc:, imageStore is only used by the image uploads and /media,
+// requestCounter is optional and counts the products read by FindByID, FindByProductIDs and SearchByCriteria
+func NewProductUsecase(productRepository model.ProductRepository, productImportJobRepository model.ProductImportJobRepository, imageStore model.ImageStore, requestCounter model.ProductRequestCounter) model.ProductUsecase {
 	return &productUsecase{
-		productRepository: productRepository,
+		productRepository:          productRepository,
+		productImportJobRepository: productImportJobRepository,
+		imageStore:                 imageStore,
+		requestCounter:             requestCounter,
+		importJobSemaphore:         make(chan struct{}, config.ImportMaxConcurrentJobs()),
+		importJobSlots:             make(chan struct{}, config.ImportMaxConcurrentJobs()+config.ImportMaxQueuedJobs()),
+		importJobsClosed:           make(chan struct{}),
 	}
 }
 
@@ -47,23 +69,27 @@ func (u *productUsecase) Create(ctx context.Context, user model.SessionUser, inp
 		return nil, err
 	}
 
-	product = &model.Product{
-		Name:        input.Name,
-		Price:       input.Price,
-		Stock:       input.Stock,
-		Description: input.Description,
-		ImageUrl:    input.ImageUrl,
-	}
-
-	if err := u.productRepository.Create(ctx, user.GetUserID(), product); err != nil {
+	product = input.ToProduct()
+	err = u.productRepository.Create(ctx, user.GetUserID(), product)
+	switch {
+	case err == nil:
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return nil, ErrDuplicateProduct
+	default:
 		logger.Error(err)
 		return nil, err
 	}
 
-	return u.FindByID(ctx, product.ID)
+	return u.findByID(ctx, product.ID)
 }
 
 func (u *productUsecase) FindByID(ctx context.Context, id int64) (product *model.Product, err error) {
+	u.countRequests(id)
+	return u.findByID(ctx, id)
+}
+
+// findByID reads the product without counting the read, for the reads done on behalf of another method
+func (u *productUsecase) findByID(ctx context.Context, id int64) (product *model.Product, err error) {
 	product, err = u.productRepository.FindByID(ctx, id)
 	if err != nil {
 		logrus.WithField("id", id).Error(err)
@@ -74,10 +100,12 @@ func (u *productUsecase) FindByID(ctx context.Context, id int64) (product *model
 		return nil, ErrNotFound
 	}
 
+	product.Images = u.imageDerivativeURLs(product.ImageUrl)
 	return product, nil
 }
 
 func (u *productUsecase) FindByProductIDs(ctx context.Context, productIDs []int64) (products []*model.Product, err error) {
+	u.countRequests(productIDs...)
 	products = u.FindAllByIDs(ctx, productIDs)
 
 	if products == nil {
@@ -107,12 +135,21 @@ func (u *productUsecase) Update(ctx context.Context, user model.SessionUser, inp
 		return nil, err
 	}
 
-	product, err = u.productRepository.FindByID(ctx, input.ID)
+	product, err = u.findByID(ctx, input.ID)
 	if err != nil {
 		logger.Error(err)
 		return nil, err
 	}
 
+	// a soft deleted product is read to be restored, it can't be updated
+	if product.DeletedAt.Valid {
+		return nil, ErrNotFound
+	}
+
+	if !product.HasVersion(input.ExpectedVersion) {
+		return nil, ErrVersionConflict
+	}
+
 	product = &model.Product{
 		ID:          product.ID,
 		Name:        input.Name,
@@ -122,15 +159,70 @@ func (u *productUsecase) Update(ctx context.Context, user model.SessionUser, inp
 		ImageUrl:    input.ImageUrl,
 	}
 
-	if err := u.productRepository.UpdateByID(ctx, user.GetUserID(), product); err != nil {
+	err = u.productRepository.UpdateByID(ctx, user.GetUserID(), product, input.ExpectedVersion)
+	switch {
+	case err == nil:
+	case errors.Is(err, model.ErrVersionConflict):
+		return nil, ErrVersionConflict
+	default:
 		logger.Error(err)
 		return nil, err
 	}
 
-	return product, nil
+	return u.findByID(ctx, product.ID)
 }
 
-func (u *productUsecase) DeleteByProductID(ctx context.Context, user model.SessionUser, productID int64) (err error) {
+// Patch updates the fields set in the input only, an empty patch returns the product as is
+func (u *productUsecase) Patch(ctx context.Context, user model.SessionUser, input model.PatchProductRequest) (product *model.Product, err error) {
+	if !user.HasAccess(rbac.ResourceProduct, rbac.ActionCreateAny) {
+		return nil, ErrPermissionDenied
+	}
+
+	logger := logrus.WithFields(logrus.Fields{
+		"ctx":   utils.DumpIncomingContext(ctx),
+		"input": utils.Dump(input),
+	})
+
+	if err := input.ValidateDTOPatchProductRequest(); err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	product, err = u.findByID(ctx, input.ID)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	// a soft deleted product is read to be restored, it can't be updated
+	if product.DeletedAt.Valid {
+		return nil, ErrNotFound
+	}
+
+	if !product.HasVersion(input.ExpectedVersion) {
+		return nil, ErrVersionConflict
+	}
+
+	if input.IsEmpty() {
+		return product, nil
+	}
+
+	err = u.productRepository.PatchByID(ctx, user.GetUserID(), input.ID, input.Columns(), input.ExpectedVersion)
+	switch {
+	case err == nil:
+	case errors.Is(err, model.ErrVersionConflict):
+		return nil, ErrVersionConflict
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return nil, ErrDuplicateProduct
+	default:
+		logger.Error(err)
+		return nil, err
+	}
+
+	return u.findByID(ctx, input.ID)
+}
+
+func (u *productUsecase) DeleteByProductID(ctx context.Context, user model.SessionUser, productID, expectedVersion int64) (err error) {
 	if !user.HasAccess(rbac.ResourceProduct, rbac.ActionDeleteAny) {
 		return ErrPermissionDenied
 	}
@@ -141,13 +233,26 @@ func (u *productUsecase) DeleteByProductID(ctx context.Context, user model.Sessi
 		"productID": productID,
 	})
 
-	product, err := u.FindByID(ctx, productID)
+	product, err := u.findByID(ctx, productID)
 	if err != nil {
 		logger.Error(err)
 		return err
 	}
 
-	if err := u.productRepository.DeleteByID(ctx, product.ID); err != nil {
+	if !product.HasVersion(expectedVersion) {
+		return ErrVersionConflict
+	}
+
+	if product.DeletedAt.Valid {
+		return nil
+	}
+
+	err = u.productRepository.DeleteByID(ctx, product.ID, expectedVersion)
+	switch {
+	case err == nil:
+	case errors.Is(err, model.ErrVersionConflict):
+		return ErrVersionConflict
+	default:
 		logger.Error(err)
 		return err
 	}
@@ -155,6 +260,44 @@ func (u *productUsecase) DeleteByProductID(ctx context.Context, user model.Sessi
 	return nil
 }
 
+func (u *productUsecase) RestoreByProductID(ctx context.Context, user model.SessionUser, productID, expectedVersion int64) (product *model.Product, err error) {
+	if !user.HasAccess(rbac.ResourceProduct, rbac.ActionDeleteAny) {
+		return nil, ErrPermissionDenied
+	}
+
+	logger := logrus.WithFields(logrus.Fields{
+		"ctx":       utils.DumpIncomingContext(ctx),
+		"user":      utils.Dump(user),
+		"productID": productID,
+	})
+
+	product, err = u.findByID(ctx, productID)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	if !product.HasVersion(expectedVersion) {
+		return nil, ErrVersionConflict
+	}
+
+	if !product.DeletedAt.Valid {
+		return product, nil
+	}
+
+	err = u.productRepository.RestoreByID(ctx, product.ID, expectedVersion)
+	switch {
+	case err == nil:
+	case errors.Is(err, model.ErrVersionConflict):
+		return nil, ErrVersionConflict
+	default:
+		logger.Error(err)
+		return nil, err
+	}
+
+	return u.findByID(ctx, product.ID)
+}
+
 func (u *productUsecase) SearchByPage(ctx context.Context, searchCriteria model.ProductSearchCriteria) (ids []int64, count int64, err error) {
 	logger := logrus.WithFields(logrus.Fields{
 		"ctx":            utils.DumpIncomingContext(ctx),
@@ -193,6 +336,7 @@ func (u *productUsecase) SearchByCriteria(ctx context.Context, user model.Sessio
 		return
 	}
 
+	u.countRequests(ids...)
 	products = u.FindAllByIDs(ctx, ids)
 	if len(products) <= 0 {
 		logger.Error(ErrNotFound)
@@ -255,7 +399,7 @@ func (u *productUsecase) FindAllByIDs(ctx context.Context, ids []int64) (product
 		go func(id int64) {
 			defer wg.Done()
 
-			product, err := u.FindByID(ctx, id)
+			product, err := u.findByID(ctx, id)
 			if err != nil {
 				logger.Error(err)
 				return
@@ -286,9 +430,9 @@ func (u *productUsecase) FindAllByIDs(ctx context.Context, ids []int64) (product
 	return
 }
 
-func (u *productUsecase) UploadImage(ctx context.Context, user model.SessionUser, input model.UploadImageProductRequest) error {
+func (u *productUsecase) UploadImage(ctx context.Context, user model.SessionUser, input model.UploadImageProductRequest) (*model.UploadImageProductResponse, error) {
 	if !user.HasAccess(rbac.ResourceProduct, rbac.ActionCreateAny) {
-		return ErrPermissionDenied
+		return nil, ErrPermissionDenied
 	}
 
 	logger := logrus.WithFields(logrus.Fields{
@@ -296,47 +440,45 @@ func (u *productUsecase) UploadImage(ctx context.Context, user model.SessionUser
 		"currentUser": utils.Dump(user),
 	})
 
-	if err := input.ValidateDTOUploadImageProductRequest(); err != nil {
+	if err := input.ValidateDTOUploadImageProductRequest(config.ImageUploadMaxSizeBytes()); err != nil {
 		logger.Error(err)
-		return err
-	}
-
-	split := "products/"
-	dirPath := strings.Split(input.Path, split)[0]
-
-	// Check if the directory exists
-	if _, err := os.Stat(dirPath + split); os.IsNotExist(err) {
-		// Directory does not exist, attempt to create it
-		if err := os.MkdirAll(dirPath+split, os.ModePerm); err != nil {
-			return err
-		}
-	} else if err != nil {
-		// An error occurred that is not related to the existence of the directory
-		return err
+		return nil, err
 	}
 
 	src, err := input.ProductImage.Open()
 	if err != nil {
 		logger.Error(err)
-		return err
+		return nil, err
 	}
 	defer src.Close()
 
-	// Destination
-	dst, err := os.Create(input.Path)
+	img, err := readUploadedImage(src, config.ImageUploadMaxSizeBytes(), config.ImageUploadMaxWidth(), config.ImageUploadMaxHeight())
 	if err != nil {
 		logger.Error(err)
-		return err
+		return nil, err
 	}
-	defer dst.Close()
 
-	// Copy
-	if _, err = io.Copy(dst, src); err != nil {
+	key := newUploadedImageKey(img.contentType)
+	if err := u.imageStore.Put(ctx, key, bytes.NewReader(img.data), int64(len(img.data)), img.contentType); err != nil {
 		logger.Error(err)
-		return err
+		return nil, err
 	}
 
-	return nil
+	if err := u.putImageDerivatives(ctx, key, img.image); err != nil {
+		logger.Error(err)
+		// the image is only usable with its derivatives
+		cleanupCtx := context.WithoutCancel(ctx)
+		_ = u.imageStore.Delete(cleanupCtx, key)
+		_ = u.deleteImageDerivatives(cleanupCtx, key)
+		return nil, err
+	}
+
+	imageURL := u.imageStore.URL(key)
+	return &model.UploadImageProductResponse{
+		FileName: path.Base(key),
+		ImageUrl: imageURL,
+		Images:   u.imageDerivativeURLs(imageURL),
+	}, nil
 }
 
 func (u *productUsecase) RemoveImage(ctx context.Context, user model.SessionUser, input model.RemoveImageProductRequest) error {
@@ -354,148 +496,51 @@ func (u *productUsecase) RemoveImage(ctx context.Context, user model.SessionUser
 		return err
 	}
 
-	// Check if file exists
-	if _, err := os.Stat(input.ImageUrl); os.IsNotExist(err) {
-		logger.Error(err)
-		return err
+	// only the images stored by UploadImage can be deleted
+	key, ok := u.uploadedImageKey(input.ImageUrl)
+	if !ok {
+		return &model.FieldError{Field: "image_url", Message: "Image URL isn't an uploaded image"}
 	}
 
-	// Delete the file
-	if err := os.Remove(input.ImageUrl); err != nil {
+	// a product still showing the image, or showing it once restored, would point at a missing file
+	imageURL := u.imageStore.URL(key)
+	imageURLs := []string{imageURL}
+	for _, derivativeURL := range u.imageDerivativeURLs(imageURL) {
+		imageURLs = append(imageURLs, derivativeURL)
+	}
+	referenced, err := u.productRepository.IsAnyImageReferenced(ctx, imageURLs)
+	if err != nil {
 		logger.Error(err)
 		return err
 	}
-
-	return nil
-}
-
-func (u *productUsecase) UploadFile(ctx context.Context, user model.SessionUser, input model.UploadFileProductRequest) error {
-	if !user.HasAccess(rbac.ResourceProduct, rbac.ActionCreateAny) {
-		return ErrPermissionDenied
+	if referenced {
+		return ErrImageInUse
 	}
 
-	logger := logrus.WithFields(logrus.Fields{
-		"ctx":         utils.DumpIncomingContext(ctx),
-		"currentUser": utils.Dump(user),
-	})
-
-	reader := bytes.NewReader(input.ProductFile)
-	csvReader := csv.NewReader(reader)
-	csvReader.Comma = ','
-	rows, err := csvReader.ReadAll()
-	if err != nil {
+	err = u.imageStore.Delete(ctx, key)
+	switch {
+	case err == nil:
+	case errors.Is(err, model.ErrImageNotFound):
+		return ErrNotFound
+	default:
 		logger.Error(err)
 		return err
 	}
 
-	semaphore := make(chan struct{}, 10)
-
-	var wg sync.WaitGroup
-	for i, row := range rows {
-		wg.Add(1)
-
-		// Acquire semaphore
-		semaphore <- struct{}{}
-
-		go func(idx int, v []string) {
-			defer wg.Done()
-			defer func() { <-semaphore }() // Release semaphore
-
-			if idx == 0 {
-				return
-			}
-
-			price, err := strconv.ParseFloat(v[1], 64)
-			if err != nil {
-				logger.Error(err)
-				return
-			}
-			stock, err := strconv.ParseInt(v[2], 10, 64)
-			if err != nil {
-				logger.Error(err)
-				return
-			}
-
-			product := &model.Product{
-				Name:        v[0],
-				Price:       price,
-				Stock:       stock,
-				Description: v[3],
-				ImageUrl:    v[4],
-			}
-
-			if err = u.productRepository.Create(ctx, user.GetUserID(), product); err != nil {
-				logger.Error(err)
-				return
-			}
-		}(i, row)
+	if err := u.deleteImageDerivatives(ctx, key); err != nil {
+		logger.Error(err)
+		return err
 	}
 
-	// Wait for all goroutines to finish
-	wg.Wait()
-
 	return nil
 }
 
-func (u *productUsecase) UploadFileWithoutSession(ctx context.Context, input model.UploadFileProductRequest) error {
-	logger := logrus.WithFields(logrus.Fields{
-		"ctx": utils.DumpIncomingContext(ctx),
-	})
-
-	reader := bytes.NewReader(input.ProductFile)
-	csvReader := csv.NewReader(reader)
-	csvReader.Comma = ','
-	rows, err := csvReader.ReadAll()
-	if err != nil {
-		logger.Error(err)
-		return err
+// countRequests counts the reads of the products requested by a client
+func (u *productUsecase) countRequests(ids ...int64) {
+	if u.requestCounter == nil {
+		return
 	}
-
-	semaphore := make(chan struct{}, 10)
-
-	var wg sync.WaitGroup
-	for i, row := range rows {
-		wg.Add(1)
-
-		// Acquire semaphore
-		semaphore <- struct{}{}
-
-		go func(idx int, v []string) {
-			defer wg.Done()
-			defer func() { <-semaphore }() // Release semaphore
-
-			if idx == 0 {
-				return
-			}
-
-			price, err := strconv.ParseFloat(v[1], 64)
-			if err != nil {
-				logger.Error(err)
-				return
-			}
-			stock, err := strconv.ParseInt(v[2], 10, 64)
-			if err != nil {
-				logger.Error(err)
-				return
-			}
-
-			product := &model.Product{
-				Name:        v[0],
-				Price:       price,
-				Stock:       stock,
-				Description: v[3],
-				ImageUrl:    v[4],
-			}
-
-			if err = u.productRepository.Create(context.Background(), 1, product); err != nil {
-				logger.Error(err)
-				return
-			}
-		}(i, row)
+	for _, id := range ids {
+		u.requestCounter.Incr(id)
 	}
-
-	// Wait for all goroutines to finish
-	wg.Wait()
-
-	return nil
 }
@@ -0,0 +1,100 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/binus-thesis-team/iam-service/auth"
+	"github.com/binus-thesis-team/product-service/internal/model"
+	"gorm.io/gorm"
+)
+
+func TestProductUsecase_UpdateAndPatchRejectTheDeletedProducts(t *testing.T) {
+	productRepository := &fakeProductRepository{products: []*model.Product{
+		{ID: 1, Name: "Kopi", Price: 25000, Stock: 10, Version: 3, DeletedAt: gorm.DeletedAt{Time: time.Now(), Valid: true}},
+	}}
+	u := NewProductUsecase(productRepository, nil, nil, nil)
+	user := model.SessionUser{User: &auth.User{ID: 1}}
+	name := "Teh"
+
+	// the matching version of the deleted product isn't a precondition failure, without it nothing is updated
+	for _, expectedVersion := range []int64{0, 3} {
+		_, err := u.Update(context.Background(), user, model.UpdateProductRequest{
+			ID:              1,
+			Name:            name,
+			Price:           15000,
+			Stock:           1,
+			Description:     "Teh melati",
+			ImageUrl:        "https://example.com/teh.png",
+			ExpectedVersion: expectedVersion,
+		})
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("Update() with the version %d = %v, want %v", expectedVersion, err, ErrNotFound)
+		}
+
+		_, err = u.Patch(context.Background(), user, model.PatchProductRequest{ID: 1, Name: &name, ExpectedVersion: expectedVersion})
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("Patch() with the version %d = %v, want %v", expectedVersion, err, ErrNotFound)
+		}
+	}
+}
+
+// fakeImageStore keeps the images in memory under https://cdn.example.com/<key>
+type fakeImageStore struct {
+	model.ImageStore
+
+	mu     sync.Mutex
+	images map[string]bool
+}
+
+func (s *fakeImageStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.images[key] {
+		return model.ErrImageNotFound
+	}
+	delete(s.images, key)
+	return nil
+}
+
+func (s *fakeImageStore) URL(key string) string {
+	return "https://cdn.example.com/" + key
+}
+
+func (s *fakeImageStore) Key(url string) (string, bool) {
+	return strings.CutPrefix(url, "https://cdn.example.com/")
+}
+
+func TestProductUsecase_RemoveImageKeepsTheImagesOfTheProducts(t *testing.T) {
+	const (
+		usedKey   = "products/0b9e5d6c-4a0e-4b8e-9a57-1f3c2d4e5f60.png"
+		unusedKey = "products/7c1d2e3f-5a6b-4c7d-8e9f-0a1b2c3d4e5f.png"
+	)
+	imageStore := &fakeImageStore{images: map[string]bool{usedKey: true, unusedKey: true}}
+	productRepository := &fakeProductRepository{products: []*model.Product{
+		// a deleted product shows its image again once restored
+		{ID: 1, ImageUrl: imageStore.URL(usedKey), DeletedAt: gorm.DeletedAt{Time: time.Now(), Valid: true}},
+	}}
+	u := NewProductUsecase(productRepository, nil, imageStore, nil)
+	user := model.SessionUser{User: &auth.User{ID: 1}}
+
+	err := u.RemoveImage(context.Background(), user, model.RemoveImageProductRequest{ImageUrl: imageStore.URL(usedKey)})
+	if !errors.Is(err, ErrImageInUse) {
+		t.Errorf("RemoveImage() of a used image = %v, want %v", err, ErrImageInUse)
+	}
+	if !imageStore.images[usedKey] {
+		t.Error("the used image was deleted")
+	}
+
+	if err := u.RemoveImage(context.Background(), user, model.RemoveImageProductRequest{ImageUrl: imageStore.URL(unusedKey)}); err != nil {
+		t.Fatal(err)
+	}
+	if imageStore.images[unusedKey] {
+		t.Error("the unused image wasn't deleted")
+	}
+}
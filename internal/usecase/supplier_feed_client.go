@@ -0,0 +1,74 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+var (
+	// errSupplierFeedRunInterrupted fails the runs stopped by a shutdown or a crash before they were finished
+	errSupplierFeedRunInterrupted = errors.New("the run was interrupted by a restart of the service")
+
+	errSupplierFeedAddressNotPublic = errors.New("the feed host must have a public address, it resolved to")
+)
+
+// nonPublicNetworks are the reserved networks not covered by the net.IP predicates checked by isPublicIP
+var nonPublicNetworks = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),
+	// carrier grade nat, the metadata service of some clouds lives there
+	mustParseCIDR("100.64.0.0/10"),
+	mustParseCIDR("198.18.0.0/15"),
+}
+
+// newSupplierFeedHTTPClient returns a client that only connects to public addresses, so a feed url can't
+// reach the services of the private network or the metadata of the cloud. The address is checked once
+// resolved, a host name resolving to a private address or a redirect to one is rejected as well.
+func newSupplierFeedHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return fmt.Errorf("%w %s", errSupplierFeedAddressNotPublic, host)
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// a proxy from the environment would be dialed instead of the feed host
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+
+	for _, network := range nonPublicNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
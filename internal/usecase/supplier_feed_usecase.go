@@ -0,0 +1,360 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/binus-thesis-team/iam-service/rbac"
+	"github.com/binus-thesis-team/iam-service/utils"
+	"github.com/binus-thesis-team/product-service/internal/config"
+	"github.com/binus-thesis-team/product-service/internal/model"
+	"github.com/sirupsen/logrus"
+)
+
+// supplierFeedRunsLimit is the number of latest runs listed per feed
+const supplierFeedRunsLimit = 50
+
+type supplierFeedUsecase struct {
+	supplierFeedRepository model.SupplierFeedRepository
+	httpClient             *http.Client
+	// importer runs the feed imports through the same path as the uploaded files
+	importer *productUsecase
+}
+
+// NewSupplierFeedUsecase :nodoc:
+// httpClient fetches the feeds, nil uses a client with the supplier_feed.fetch_timeout config
+// that only connects to public addresses
+func NewSupplierFeedUsecase(
+	supplierFeedRepository model.SupplierFeedRepository,
+	productRepository model.ProductRepository,
+	productImportJobRepository model.ProductImportJobRepository,
+	httpClient *http.Client,
+) model.SupplierFeedUsecase {
+	if httpClient == nil {
+		httpClient = newSupplierFeedHTTPClient(config.SupplierFeedFetchTimeout())
+	}
+
+	return &supplierFeedUsecase{
+		supplierFeedRepository: supplierFeedRepository,
+		httpClient:             httpClient,
+		importer: &productUsecase{
+			productRepository:          productRepository,
+			productImportJobRepository: productImportJobRepository,
+			// the feeds run one after another
+			importJobSemaphore: make(chan struct{}, 1),
+		},
+	}
+}
+
+func (u *supplierFeedUsecase) Create(ctx context.Context, user model.SessionUser, input model.CreateSupplierFeedRequest) (feed *model.SupplierFeed, err error) {
+	if !user.HasAccess(rbac.ResourceProduct, rbac.ActionCreateAny) {
+		return nil, ErrPermissionDenied
+	}
+
+	if err := input.ValidateDTOCreateSupplierFeedRequest(); err != nil {
+		return nil, err
+	}
+
+	feed = &model.SupplierFeed{
+		Name:          input.Name,
+		URL:           input.URL,
+		Format:        input.Format,
+		ColumnMapping: input.ColumnMapping,
+		Schedule:      input.Schedule,
+		UpsertKey:     input.UpsertKey,
+		Enabled:       input.IsEnabled(),
+		// the first run is right away
+		NextRunAt: time.Now(),
+		CreatedBy: user.GetUserID(),
+	}
+
+	if err := u.supplierFeedRepository.Create(ctx, feed); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"ctx":   utils.DumpIncomingContext(ctx),
+			"input": utils.Dump(input),
+		}).Error(err)
+		return nil, err
+	}
+
+	return feed, nil
+}
+
+func (u *supplierFeedUsecase) FindByID(ctx context.Context, user model.SessionUser, id int64) (feed *model.SupplierFeed, err error) {
+	if !user.HasAccess(rbac.ResourceProduct, rbac.ActionViewAny) {
+		return nil, ErrPermissionDenied
+	}
+
+	return u.findByID(ctx, id)
+}
+
+func (u *supplierFeedUsecase) FindAll(ctx context.Context, user model.SessionUser) (feeds []*model.SupplierFeed, err error) {
+	if !user.HasAccess(rbac.ResourceProduct, rbac.ActionViewAny) {
+		return nil, ErrPermissionDenied
+	}
+
+	feeds, err = u.supplierFeedRepository.FindAll(ctx)
+	if err != nil {
+		logrus.WithField("ctx", utils.DumpIncomingContext(ctx)).Error(err)
+		return nil, err
+	}
+
+	return feeds, nil
+}
+
+func (u *supplierFeedUsecase) Update(ctx context.Context, user model.SessionUser, input model.UpdateSupplierFeedRequest) (feed *model.SupplierFeed, err error) {
+	if !user.HasAccess(rbac.ResourceProduct, rbac.ActionCreateAny) {
+		return nil, ErrPermissionDenied
+	}
+
+	if err := input.ValidateDTOCreateSupplierFeedRequest(); err != nil {
+		return nil, err
+	}
+
+	feed, err = u.findByID(ctx, input.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	previousSchedule := feed.GetSchedule()
+	feed.Name = input.Name
+	feed.URL = input.URL
+	feed.Format = input.Format
+	feed.ColumnMapping = input.ColumnMapping
+	feed.Schedule = input.Schedule
+	feed.UpsertKey = input.UpsertKey
+	feed.Enabled = input.IsEnabled()
+
+	// the run schedule is left to ClaimDue, unless the new schedule moves the next run, a feed never run is due already
+	columns := []string{"name", "url", "format", "column_mapping", "schedule", "upsert_key", "enabled"}
+	if feed.GetSchedule() != previousSchedule && feed.LastRunAt != nil {
+		feed.NextRunAt = feed.LastRunAt.Add(feed.GetSchedule())
+		columns = append(columns, "next_run_at")
+	}
+
+	if err := u.supplierFeedRepository.Update(ctx, feed, columns...); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"ctx":   utils.DumpIncomingContext(ctx),
+			"input": utils.Dump(input),
+		}).Error(err)
+		return nil, err
+	}
+
+	return feed, nil
+}
+
+func (u *supplierFeedUsecase) DeleteByID(ctx context.Context, user model.SessionUser, id int64) (err error) {
+	if !user.HasAccess(rbac.ResourceProduct, rbac.ActionDeleteAny) {
+		return ErrPermissionDenied
+	}
+
+	if _, err := u.findByID(ctx, id); err != nil {
+		return err
+	}
+
+	if err := u.supplierFeedRepository.DeleteByID(ctx, id); err != nil {
+		logrus.WithField("id", id).Error(err)
+		return err
+	}
+
+	return nil
+}
+
+// TriggerByID makes the feed due, it's run by the next poll of the scheduler
+func (u *supplierFeedUsecase) TriggerByID(ctx context.Context, user model.SessionUser, id int64) (feed *model.SupplierFeed, err error) {
+	if !user.HasAccess(rbac.ResourceProduct, rbac.ActionCreateAny) {
+		return nil, ErrPermissionDenied
+	}
+
+	feed, err = u.findByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !feed.Enabled {
+		return nil, ErrSupplierFeedDisabled
+	}
+
+	feed.NextRunAt = time.Now()
+	if err := u.supplierFeedRepository.Update(ctx, feed, "next_run_at"); err != nil {
+		logrus.WithField("id", id).Error(err)
+		return nil, err
+	}
+
+	return feed, nil
+}
+
+func (u *supplierFeedUsecase) FindRunsByFeedID(ctx context.Context, user model.SessionUser, feedID int64) (runs []*model.SupplierFeedRun, err error) {
+	if _, err := u.FindByID(ctx, user, feedID); err != nil {
+		return nil, err
+	}
+
+	runs, err = u.supplierFeedRepository.FindRunsByFeedID(ctx, feedID, supplierFeedRunsLimit)
+	if err != nil {
+		logrus.WithField("feedID", feedID).Error(err)
+		return nil, err
+	}
+
+	return runs, nil
+}
+
+func (u *supplierFeedUsecase) RunDueFeeds(ctx context.Context) (count int, err error) {
+	for ctx.Err() == nil {
+		feed, err := u.supplierFeedRepository.ClaimDue(ctx, time.Now())
+		if err != nil {
+			logrus.WithField("ctx", utils.DumpIncomingContext(ctx)).Error(err)
+			return count, err
+		}
+
+		if feed == nil {
+			break
+		}
+
+		u.runFeed(ctx, feed)
+		count++
+	}
+
+	return count, nil
+}
+
+// FailStaleRuns fails the runs whose process is gone. A run fetching its feed is stale once the fetch
+// timeout is over, an importing one once its import job is failed by FailStaleImportJobs.
+func (u *supplierFeedUsecase) FailStaleRuns(ctx context.Context) (int64, error) {
+	staleBefore := time.Now().Add(-max(config.SupplierFeedFetchTimeout(), config.ImportJobStaleAfter()))
+	count, err := u.supplierFeedRepository.FailStaleRuns(ctx, staleBefore, errSupplierFeedRunInterrupted.Error())
+	if err != nil {
+		logrus.WithField("ctx", utils.DumpIncomingContext(ctx)).Error(err)
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (u *supplierFeedUsecase) findByID(ctx context.Context, id int64) (*model.SupplierFeed, error) {
+	feed, err := u.supplierFeedRepository.FindByID(ctx, id)
+	if err != nil {
+		logrus.WithField("id", id).Error(err)
+		return nil, err
+	}
+
+	if feed == nil {
+		return nil, ErrNotFound
+	}
+
+	return feed, nil
+}
+
+// runFeed fetches the feed and imports it as an import job, the outcome is saved as a feed run
+func (u *supplierFeedUsecase) runFeed(ctx context.Context, feed *model.SupplierFeed) {
+	logger := logrus.WithFields(logrus.Fields{
+		"feedID": feed.ID,
+		"url":    feed.URL,
+	})
+
+	run := &model.SupplierFeedRun{
+		FeedID:    feed.ID,
+		Status:    model.SupplierFeedRunStatusRunning,
+		StartedAt: time.Now(),
+	}
+	if err := u.supplierFeedRepository.CreateRun(ctx, run); err != nil {
+		logger.Error(err)
+		return
+	}
+
+	if err := u.importFeed(ctx, feed, run); err != nil {
+		logger.Error(err)
+		run.Status = model.SupplierFeedRunStatusFailed
+		run.ErrorMessage = err.Error()
+	}
+
+	now := time.Now()
+	run.FinishedAt = &now
+	if err := u.supplierFeedRepository.UpdateRun(ctx, run); err != nil {
+		logger.Error(err)
+	}
+}
+
+func (u *supplierFeedUsecase) importFeed(ctx context.Context, feed *model.SupplierFeed, run *model.SupplierFeedRun) error {
+	content, err := u.fetchFeed(ctx, feed, run)
+	if err != nil {
+		return err
+	}
+
+	var records [][]string
+	switch feed.Format {
+	case model.SupplierFeedFormatJSON:
+		records, err = readJSONRecords(content)
+	default:
+		records, err = readImportRecords(content)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidImportFile, err)
+	}
+
+	mode := feed.ImportMode()
+	header, rows, err := resolveImportRecords(records, feed.ImportColumnMapping(), mode)
+	if err != nil {
+		return err
+	}
+
+	job := &model.ProductImportJob{
+		Filename:  feed.URL,
+		Mode:      mode,
+		Status:    model.ProductImportJobStatusQueued,
+		CreatedBy: feed.CreatedBy,
+	}
+	if err := u.importer.productImportJobRepository.Create(ctx, job); err != nil {
+		return err
+	}
+	// the run is stale only once its job is, see FailStaleRuns
+	run.ImportJobID = &job.ID
+	if err := u.supplierFeedRepository.UpdateRun(ctx, run); err != nil {
+		return err
+	}
+
+	u.importer.runImportJob(ctx, feed.CreatedBy, job, header, rows)
+
+	run.TotalRows = job.TotalRows
+	run.CreatedRows = job.CreatedRows
+	run.UpdatedRows = job.UpdatedRows
+	run.FailedRows = job.FailedRows
+	run.Status = model.SupplierFeedRunStatusDone
+	if job.Status == model.ProductImportJobStatusFailed {
+		return errors.New(job.ErrorMessage)
+	}
+
+	return nil
+}
+
+// fetchFeed downloads the feed up to the supplier_feed.max_size_bytes config
+func (u *supplierFeedUsecase) fetchFeed(ctx context.Context, feed *model.SupplierFeed, run *model.SupplierFeedRun) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feed.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := u.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	run.HTTPStatus = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("unexpected http status %s", resp.Status)
+	}
+
+	maxSize := config.SupplierFeedMaxSizeBytes()
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > maxSize {
+		return nil, fmt.Errorf("the feed is larger than %d bytes", maxSize)
+	}
+
+	return content, nil
+}
@@ -0,0 +1,456 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/binus-thesis-team/iam-service/auth"
+	"github.com/binus-thesis-team/product-service/internal/model"
+	"github.com/spf13/viper"
+)
+
+// fakeSupplierFeedRepository keeps the feeds and the runs in memory, ClaimDue claims like the gorm repository
+type fakeSupplierFeedRepository struct {
+	model.SupplierFeedRepository
+
+	mu      sync.Mutex
+	feeds   []*model.SupplierFeed
+	runs    []*model.SupplierFeedRun
+	updated []fakeSupplierFeedUpdate
+}
+
+type fakeSupplierFeedUpdate struct {
+	feed    model.SupplierFeed
+	columns []string
+}
+
+func (r *fakeSupplierFeedRepository) ClaimDue(_ context.Context, now time.Time) (*model.SupplierFeed, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var due *model.SupplierFeed
+	for _, feed := range r.feeds {
+		if !feed.Enabled || feed.NextRunAt.After(now) {
+			continue
+		}
+		if due == nil || feed.NextRunAt.Before(due.NextRunAt) {
+			due = feed
+		}
+	}
+	if due == nil {
+		return nil, nil
+	}
+
+	due.LastRunAt = &now
+	due.NextRunAt = now.Add(due.GetSchedule())
+	claimed := *due
+	return &claimed, nil
+}
+
+func (r *fakeSupplierFeedRepository) FindByID(_ context.Context, id int64) (*model.SupplierFeed, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, feed := range r.feeds {
+		if feed.ID == id {
+			found := *feed
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+// Update records the saved columns
+func (r *fakeSupplierFeedRepository) Update(_ context.Context, feed *model.SupplierFeed, columns ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.updated = append(r.updated, fakeSupplierFeedUpdate{feed: *feed, columns: columns})
+	return nil
+}
+
+func (r *fakeSupplierFeedRepository) CreateRun(_ context.Context, run *model.SupplierFeedRun) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	run.ID = int64(len(r.runs) + 1)
+	r.runs = append(r.runs, run)
+	return nil
+}
+
+func (r *fakeSupplierFeedRepository) UpdateRun(context.Context, *model.SupplierFeedRun) error {
+	return nil
+}
+
+func (r *fakeSupplierFeedRepository) findRunsByFeedID(feedID int64) []*model.SupplierFeedRun {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var runs []*model.SupplierFeedRun
+	for _, run := range r.runs {
+		if run.FeedID == feedID {
+			runs = append(runs, run)
+		}
+	}
+	return runs
+}
+
+type fakeProductImportJobRepository struct {
+	model.ProductImportJobRepository
+
+	mu   sync.Mutex
+	jobs []*model.ProductImportJob
+}
+
+func (r *fakeProductImportJobRepository) Create(_ context.Context, job *model.ProductImportJob) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job.ID = int64(len(r.jobs) + 1)
+	r.jobs = append(r.jobs, job)
+	return nil
+}
+
+func (r *fakeProductImportJobRepository) Update(context.Context, *model.ProductImportJob) error {
+	return nil
+}
+
+func (r *fakeProductImportJobRepository) CreateRowErrors(context.Context, []*model.ProductImportRowError) error {
+	return nil
+}
+
+// fakeProductRepository keeps the products in memory, the methods it doesn't implement panic
+type fakeProductRepository struct {
+	model.ProductRepository
+
+	mu       sync.Mutex
+	products []*model.Product
+}
+
+// FindByID reads the soft deleted products too, like the gorm repository
+func (r *fakeProductRepository) FindByID(_ context.Context, id int64) (*model.Product, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, product := range r.products {
+		if product.ID == id {
+			found := *product
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeProductRepository) Create(_ context.Context, _ int64, product *model.Product) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	product.ID = int64(len(r.products) + 1)
+	r.products = append(r.products, product)
+	return nil
+}
+
+// FindAllByExternalIDs reads the soft deleted products too, like the gorm repository
+func (r *fakeProductRepository) FindAllByExternalIDs(_ context.Context, externalIDs []string) ([]*model.Product, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var products []*model.Product
+	for _, product := range r.products {
+		for _, externalID := range externalIDs {
+			if product.GetExternalID() == externalID {
+				products = append(products, product)
+			}
+		}
+	}
+	return products, nil
+}
+
+// UpsertByExternalIDs leaves out the soft deleted products, like the gorm repository
+func (r *fakeProductRepository) UpsertByExternalIDs(_ context.Context, _ int64, products []*model.Product) ([]model.ProductUpsertResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	results := make([]model.ProductUpsertResult, 0, len(products))
+	for _, product := range products {
+		var existing *model.Product
+		for _, p := range r.products {
+			if p.GetExternalID() == product.GetExternalID() {
+				existing = p
+			}
+		}
+
+		switch {
+		case existing == nil:
+			product.ID = int64(len(r.products) + 1)
+			r.products = append(r.products, product)
+			results = append(results, model.ProductUpsertResult{ID: product.ID, ExternalID: product.GetExternalID(), Created: true})
+		case !existing.DeletedAt.Valid:
+			existing.Name, existing.Price, existing.Stock = product.Name, product.Price, product.Stock
+			existing.Description, existing.ImageUrl = product.Description, product.ImageUrl
+			results = append(results, model.ProductUpsertResult{ID: existing.ID, ExternalID: existing.GetExternalID()})
+		}
+	}
+	return results, nil
+}
+
+// IsAnyImageReferenced checks the images of the products only, soft deleted ones included
+func (r *fakeProductRepository) IsAnyImageReferenced(_ context.Context, imageURLs []string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, product := range r.products {
+		for _, imageURL := range imageURLs {
+			if product.ImageUrl == imageURL {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeProductRepository) names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.products))
+	for _, product := range r.products {
+		names = append(names, product.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func newTestSupplierFeedServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/catalog.csv", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("nama,harga,stok,desc,gambar\nKopi,25000,10,Kopi tubruk,https://example.com/kopi.png\nTeh,15000,3,Teh melati,https://example.com/teh.png\n"))
+	})
+	mux.HandleFunc("/catalog.json", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`[{"title":"Gula","price":12000,"stock":5,"description":"Gula aren","image_url":"https://example.com/gula.png"}]`))
+	})
+	mux.HandleFunc("/broken", func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "broken", http.StatusInternalServerError)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSupplierFeedUsecase_RunDueFeedsImportsTheDueFeeds(t *testing.T) {
+	server := newTestSupplierFeedServer(t)
+	now := time.Now()
+
+	feedRepository := &fakeSupplierFeedRepository{feeds: []*model.SupplierFeed{
+		{ID: 1, URL: server.URL + "/catalog.csv", Format: model.SupplierFeedFormatCSV, Schedule: "1h", Enabled: true, NextRunAt: now.Add(-time.Minute)},
+		{ID: 2, URL: server.URL + "/catalog.json", Format: model.SupplierFeedFormatJSON, Schedule: "1h", Enabled: true, NextRunAt: now.Add(-time.Hour)},
+		{ID: 3, URL: server.URL + "/catalog.csv", Format: model.SupplierFeedFormatCSV, Schedule: "1h", Enabled: true, NextRunAt: now.Add(time.Hour)},
+		{ID: 4, URL: server.URL + "/catalog.csv", Format: model.SupplierFeedFormatCSV, Schedule: "1h", Enabled: false, NextRunAt: now.Add(-time.Hour)},
+	}}
+	productRepository := &fakeProductRepository{}
+	jobRepository := &fakeProductImportJobRepository{}
+	u := NewSupplierFeedUsecase(feedRepository, productRepository, jobRepository, server.Client())
+
+	count, err := u.RunDueFeeds(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Fatalf("RunDueFeeds() = %d, want 2", count)
+	}
+
+	wantRows := map[int64]int64{1: 2, 2: 1}
+	for feedID, rows := range wantRows {
+		runs := feedRepository.findRunsByFeedID(feedID)
+		if len(runs) != 1 {
+			t.Fatalf("feed %d has %d runs, want 1", feedID, len(runs))
+		}
+		run := runs[0]
+		if run.Status != model.SupplierFeedRunStatusDone || run.ErrorMessage != "" {
+			t.Errorf("feed %d run is %s (%s), want done", feedID, run.Status, run.ErrorMessage)
+		}
+		if run.HTTPStatus != http.StatusOK || run.TotalRows != rows || run.CreatedRows != rows || run.FailedRows != 0 {
+			t.Errorf("feed %d run = %+v, want %d created rows with a 200", feedID, run, rows)
+		}
+		if run.ImportJobID == nil || run.FinishedAt == nil {
+			t.Errorf("feed %d run has no import job or isn't finished", feedID)
+		}
+	}
+	for _, feedID := range []int64{3, 4} {
+		if runs := feedRepository.findRunsByFeedID(feedID); len(runs) != 0 {
+			t.Errorf("feed %d was run, it isn't due", feedID)
+		}
+	}
+
+	if got, want := strings.Join(productRepository.names(), ","), "Gula,Kopi,Teh"; got != want {
+		t.Errorf("created products = %s, want %s", got, want)
+	}
+
+	for _, feed := range feedRepository.feeds[:2] {
+		if !feed.NextRunAt.After(now) || feed.LastRunAt == nil {
+			t.Errorf("feed %d isn't rescheduled, next run at %s", feed.ID, feed.NextRunAt)
+		}
+	}
+
+	// the claimed feeds aren't due anymore
+	count, err = u.RunDueFeeds(context.Background())
+	if err != nil || count != 0 {
+		t.Errorf("RunDueFeeds() = %d, %v on the second poll, want 0", count, err)
+	}
+}
+
+func TestSupplierFeedUsecase_RunDueFeedsFailsTheRunOfABrokenFeed(t *testing.T) {
+	server := newTestSupplierFeedServer(t)
+
+	feedRepository := &fakeSupplierFeedRepository{feeds: []*model.SupplierFeed{
+		{ID: 1, URL: server.URL + "/broken", Format: model.SupplierFeedFormatCSV, Enabled: true, NextRunAt: time.Now()},
+	}}
+	jobRepository := &fakeProductImportJobRepository{}
+	u := NewSupplierFeedUsecase(feedRepository, &fakeProductRepository{}, jobRepository, server.Client())
+
+	count, err := u.RunDueFeeds(context.Background())
+	if err != nil || count != 1 {
+		t.Fatalf("RunDueFeeds() = %d, %v, want 1", count, err)
+	}
+
+	run := feedRepository.findRunsByFeedID(1)[0]
+	if run.Status != model.SupplierFeedRunStatusFailed || run.HTTPStatus != http.StatusInternalServerError {
+		t.Errorf("run is %s with a %d, want failed with a 500", run.Status, run.HTTPStatus)
+	}
+	if !strings.Contains(run.ErrorMessage, "unexpected http status 500") {
+		t.Errorf("run error message = %q", run.ErrorMessage)
+	}
+	if run.FinishedAt == nil {
+		t.Error("the failed run isn't finished")
+	}
+	if len(jobRepository.jobs) != 0 {
+		t.Error("an import job was created for a feed that wasn't fetched")
+	}
+}
+
+func TestSupplierFeedUsecase_UpdateLeavesTheRunScheduleToClaimDue(t *testing.T) {
+	lastRunAt := time.Now().Add(-time.Hour)
+	newFeedRepository := func() *fakeSupplierFeedRepository {
+		return &fakeSupplierFeedRepository{feeds: []*model.SupplierFeed{
+			{ID: 1, Name: "supplier", URL: "https://example.com/catalog.csv", Format: model.SupplierFeedFormatCSV, Schedule: "24h", Enabled: true, LastRunAt: &lastRunAt, NextRunAt: lastRunAt.Add(24 * time.Hour)},
+		}}
+	}
+	user := model.SessionUser{User: &auth.User{ID: 1}}
+	input := func(schedule string) model.UpdateSupplierFeedRequest {
+		return model.UpdateSupplierFeedRequest{ID: 1, CreateSupplierFeedRequest: model.CreateSupplierFeedRequest{
+			Name:     "renamed",
+			URL:      "https://example.com/catalog.csv",
+			Format:   model.SupplierFeedFormatCSV,
+			Schedule: schedule,
+		}}
+	}
+
+	t.Run("same schedule", func(t *testing.T) {
+		feedRepository := newFeedRepository()
+		u := NewSupplierFeedUsecase(feedRepository, nil, nil, http.DefaultClient)
+		if _, err := u.Update(context.Background(), user, input("24h")); err != nil {
+			t.Fatal(err)
+		}
+
+		update := feedRepository.updated[0]
+		for _, column := range update.columns {
+			if column == "next_run_at" || column == "last_run_at" {
+				t.Errorf("the update saves %s", column)
+			}
+		}
+	})
+
+	t.Run("shorter schedule", func(t *testing.T) {
+		feedRepository := newFeedRepository()
+		u := NewSupplierFeedUsecase(feedRepository, nil, nil, http.DefaultClient)
+		feed, err := u.Update(context.Background(), user, input("10m"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the next run follows the new schedule from the last run, it's due right away
+		want := lastRunAt.Add(10 * time.Minute)
+		update := feedRepository.updated[0]
+		if !feed.NextRunAt.Equal(want) || !update.feed.NextRunAt.Equal(want) || update.columns[len(update.columns)-1] != "next_run_at" {
+			t.Errorf("the feed runs next at %s saving %v, want %s", update.feed.NextRunAt, update.columns, want)
+		}
+		for _, column := range update.columns {
+			if column == "last_run_at" {
+				t.Error("the update saves last_run_at")
+			}
+		}
+	})
+
+	t.Run("trigger", func(t *testing.T) {
+		feedRepository := newFeedRepository()
+		u := NewSupplierFeedUsecase(feedRepository, nil, nil, http.DefaultClient)
+		if _, err := u.TriggerByID(context.Background(), user, 1); err != nil {
+			t.Fatal(err)
+		}
+
+		update := feedRepository.updated[0]
+		if len(update.columns) != 1 || update.columns[0] != "next_run_at" || update.feed.NextRunAt.After(time.Now()) {
+			t.Errorf("the trigger saves %v with the next run at %s, want next_run_at only, now", update.columns, update.feed.NextRunAt)
+		}
+	})
+}
+
+func TestSupplierFeedUsecase_FetchFeedRejectsTheFeedsOverTheMaxSize(t *testing.T) {
+	server := newTestSupplierFeedServer(t)
+	viper.Set("supplier_feed.max_size_bytes", 16)
+	t.Cleanup(func() { viper.Set("supplier_feed.max_size_bytes", 0) })
+
+	u := NewSupplierFeedUsecase(&fakeSupplierFeedRepository{}, nil, nil, server.Client()).(*supplierFeedUsecase)
+	run := &model.SupplierFeedRun{}
+	_, err := u.fetchFeed(context.Background(), &model.SupplierFeed{URL: server.URL + "/catalog.csv"}, run)
+	if err == nil || !strings.Contains(err.Error(), "larger than 16 bytes") {
+		t.Errorf("fetchFeed() = %v, want the feed rejected as too large", err)
+	}
+	if run.HTTPStatus != http.StatusOK {
+		t.Errorf("run HTTP status = %d, want 200", run.HTTPStatus)
+	}
+}
+
+func TestSupplierFeedUsecase_FetchFeedRejectsThePrivateAddresses(t *testing.T) {
+	server := newTestSupplierFeedServer(t)
+
+	// the default client, the test server listens on the loopback
+	u := NewSupplierFeedUsecase(&fakeSupplierFeedRepository{}, nil, nil, nil).(*supplierFeedUsecase)
+	_, err := u.fetchFeed(context.Background(), &model.SupplierFeed{URL: server.URL + "/catalog.csv"}, &model.SupplierFeedRun{})
+	if !errors.Is(err, errSupplierFeedAddressNotPublic) {
+		t.Errorf("fetchFeed() = %v, want %v", err, errSupplierFeedAddressNotPublic)
+	}
+}
+
+func TestIsPublicIP(t *testing.T) {
+	tests := map[string]bool{
+		"8.8.8.8":         true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"::1":             false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"100.100.100.200": false,
+		"0.0.0.0":         false,
+		"fd00::1":         false,
+		"fe80::1":         false,
+	}
+	for address, want := range tests {
+		if got := isPublicIP(net.ParseIP(address)); got != want {
+			t.Errorf("isPublicIP(%s) = %v, want %v", address, got, want)
+		}
+	}
+}
@@ -30,7 +30,7 @@ var file_pb_product_service_product_service_proto_rawDesc = []byte{
 	0x63, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
 	0x1a, 0x20, 0x70, 0x62, 0x2f, 0x70, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x5f, 0x73, 0x65, 0x72,
 	0x76, 0x69, 0x63, 0x65, 0x2f, 0x67, 0x65, 0x6e, 0x65, 0x72, 0x61, 0x6c, 0x2e, 0x70, 0x72, 0x6f,
-	0x74, 0x6f, 0x32, 0xf8, 0x03, 0x0a, 0x0e, 0x50, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x53, 0x65,
+	0x74, 0x6f, 0x32, 0xa7, 0x09, 0x0a, 0x0e, 0x50, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x53, 0x65,
 	0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x5a, 0x0a, 0x14, 0x46, 0x69, 0x6e, 0x64, 0x41, 0x6c, 0x6c,
 	0x50, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x73, 0x42, 0x79, 0x49, 0x44, 0x73, 0x12, 0x24, 0x2e,
 	0x70, 0x62, 0x2e, 0x70, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x5f, 0x73, 0x65, 0x72, 0x76, 0x69,
@@ -61,38 +61,105 @@ var file_pb_product_service_product_service_proto_rawDesc = []byte{
 	0x50, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a,
 	0x2a, 0x2e, 0x70, 0x62, 0x2e, 0x70, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x5f, 0x73, 0x65, 0x72,
 	0x76, 0x69, 0x63, 0x65, 0x2e, 0x55, 0x70, 0x6c, 0x6f, 0x61, 0x64, 0x50, 0x72, 0x6f, 0x64, 0x75,
-	0x63, 0x74, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x42, 0x14, 0x5a,
-	0x12, 0x70, 0x62, 0x2f, 0x70, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x5f, 0x73, 0x65, 0x72, 0x76,
-	0x69, 0x63, 0x65, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x63, 0x74, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x7d, 0x0a,
+	0x14, 0x55, 0x70, 0x6c, 0x6f, 0x61, 0x64, 0x50, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x73, 0x53,
+	0x74, 0x72, 0x65, 0x61, 0x6d, 0x12, 0x2f, 0x2e, 0x70, 0x62, 0x2e, 0x70, 0x72, 0x6f, 0x64, 0x75,
+	0x63, 0x74, 0x5f, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x2e, 0x55, 0x70, 0x6c, 0x6f, 0x61,
+	0x64, 0x50, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x73, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x30, 0x2e, 0x70, 0x62, 0x2e, 0x70, 0x72, 0x6f, 0x64,
+	0x75, 0x63, 0x74, 0x5f, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x2e, 0x55, 0x70, 0x6c, 0x6f,
+	0x61, 0x64, 0x50, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x73, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x28, 0x01, 0x12, 0x58, 0x0a, 0x0d,
+	0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x50, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x12, 0x28, 0x2e,
+	0x70, 0x62, 0x2e, 0x70, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x5f, 0x73, 0x65, 0x72, 0x76, 0x69,
+	0x63, 0x65, 0x2e, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x50, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1b, 0x2e, 0x70, 0x62, 0x2e, 0x70, 0x72, 0x6f,
+	0x64, 0x75, 0x63, 0x74, 0x5f, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x2e, 0x50, 0x72, 0x6f,
+	0x64, 0x75, 0x63, 0x74, 0x22, 0x00, 0x12, 0x58, 0x0a, 0x0d, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65,
+	0x50, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x12, 0x28, 0x2e, 0x70, 0x62, 0x2e, 0x70, 0x72, 0x6f,
+	0x64, 0x75, 0x63, 0x74, 0x5f, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x2e, 0x55, 0x70, 0x64,
+	0x61, 0x74, 0x65, 0x50, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x1b, 0x2e, 0x70, 0x62, 0x2e, 0x70, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x5f, 0x73,
+	0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x2e, 0x50, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x22, 0x00,
+	0x12, 0x56, 0x0a, 0x0c, 0x50, 0x61, 0x74, 0x63, 0x68, 0x50, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74,
+	0x12, 0x27, 0x2e, 0x70, 0x62, 0x2e, 0x70, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x5f, 0x73, 0x65,
+	0x72, 0x76, 0x69, 0x63, 0x65, 0x2e, 0x50, 0x61, 0x74, 0x63, 0x68, 0x50, 0x72, 0x6f, 0x64, 0x75,
+	0x63, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1b, 0x2e, 0x70, 0x62, 0x2e, 0x70,
+	0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x5f, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x2e, 0x50,
+	0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x22, 0x00, 0x12, 0x53, 0x0a, 0x0d, 0x44, 0x65, 0x6c, 0x65,
+	0x74, 0x65, 0x50, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x12, 0x25, 0x2e, 0x70, 0x62, 0x2e, 0x70,
+	0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x5f, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x2e, 0x44,
+	0x65, 0x6c, 0x65, 0x74, 0x65, 0x42, 0x79, 0x49, 0x44, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x1a, 0x19, 0x2e, 0x70, 0x62, 0x2e, 0x70, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x5f, 0x73, 0x65,
+	0x72, 0x76, 0x69, 0x63, 0x65, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x22, 0x00, 0x12, 0x56, 0x0a,
+	0x0e, 0x52, 0x65, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x50, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x12,
+	0x25, 0x2e, 0x70, 0x62, 0x2e, 0x70, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x5f, 0x73, 0x65, 0x72,
+	0x76, 0x69, 0x63, 0x65, 0x2e, 0x4d, 0x75, 0x74, 0x61, 0x74, 0x65, 0x42, 0x79, 0x49, 0x44, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1b, 0x2e, 0x70, 0x62, 0x2e, 0x70, 0x72, 0x6f, 0x64,
+	0x75, 0x63, 0x74, 0x5f, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x2e, 0x50, 0x72, 0x6f, 0x64,
+	0x75, 0x63, 0x74, 0x22, 0x00, 0x12, 0x75, 0x0a, 0x12, 0x42, 0x75, 0x6c, 0x6b, 0x4d, 0x75, 0x74,
+	0x61, 0x74, 0x65, 0x50, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x73, 0x12, 0x2d, 0x2e, 0x70, 0x62,
+	0x2e, 0x70, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x5f, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65,
+	0x2e, 0x42, 0x75, 0x6c, 0x6b, 0x4d, 0x75, 0x74, 0x61, 0x74, 0x65, 0x50, 0x72, 0x6f, 0x64, 0x75,
+	0x63, 0x74, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x2e, 0x2e, 0x70, 0x62, 0x2e,
+	0x70, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x5f, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x2e,
+	0x42, 0x75, 0x6c, 0x6b, 0x4d, 0x75, 0x74, 0x61, 0x74, 0x65, 0x50, 0x72, 0x6f, 0x64, 0x75, 0x63,
+	0x74, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x42, 0x14, 0x5a, 0x12,
+	0x70, 0x62, 0x2f, 0x70, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x5f, 0x73, 0x65, 0x72, 0x76, 0x69,
+	0x63, 0x65, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var file_pb_product_service_product_service_proto_goTypes = []interface{}{
-	(*FindByIDsRequest)(nil),       // 0: pb.product_service.FindByIDsRequest
-	(*FindByIDRequest)(nil),        // 1: pb.product_service.FindByIDRequest
-	(*ProductSearchRequest)(nil),   // 2: pb.product_service.ProductSearchRequest
-	(*FindByQueryRequest)(nil),     // 3: pb.product_service.FindByQueryRequest
-	(*UploadProductsRequest)(nil),  // 4: pb.product_service.UploadProductsRequest
-	(*Products)(nil),               // 5: pb.product_service.Products
-	(*Product)(nil),                // 6: pb.product_service.Product
-	(*SearchResponse)(nil),         // 7: pb.product_service.SearchResponse
-	(*UploadProductsResponse)(nil), // 8: pb.product_service.UploadProductsResponse
+	(*FindByIDsRequest)(nil),             // 0: pb.product_service.FindByIDsRequest
+	(*FindByIDRequest)(nil),              // 1: pb.product_service.FindByIDRequest
+	(*ProductSearchRequest)(nil),         // 2: pb.product_service.ProductSearchRequest
+	(*FindByQueryRequest)(nil),           // 3: pb.product_service.FindByQueryRequest
+	(*UploadProductsRequest)(nil),        // 4: pb.product_service.UploadProductsRequest
+	(*UploadProductsStreamRequest)(nil),  // 5: pb.product_service.UploadProductsStreamRequest
+	(*CreateProductRequest)(nil),         // 6: pb.product_service.CreateProductRequest
+	(*UpdateProductRequest)(nil),         // 7: pb.product_service.UpdateProductRequest
+	(*PatchProductRequest)(nil),          // 8: pb.product_service.PatchProductRequest
+	(*DeleteByIDRequest)(nil),            // 9: pb.product_service.DeleteByIDRequest
+	(*MutateByIDRequest)(nil),            // 10: pb.product_service.MutateByIDRequest
+	(*BulkMutateProductsRequest)(nil),    // 11: pb.product_service.BulkMutateProductsRequest
+	(*Products)(nil),                     // 12: pb.product_service.Products
+	(*Product)(nil),                      // 13: pb.product_service.Product
+	(*SearchResponse)(nil),               // 14: pb.product_service.SearchResponse
+	(*UploadProductsResponse)(nil),       // 15: pb.product_service.UploadProductsResponse
+	(*UploadProductsStreamResponse)(nil), // 16: pb.product_service.UploadProductsStreamResponse
+	(*Empty)(nil),                        // 17: pb.product_service.Empty
+	(*BulkMutateProductsResponse)(nil),   // 18: pb.product_service.BulkMutateProductsResponse
 }
 var file_pb_product_service_product_service_proto_depIdxs = []int32{
-	0, // 0: pb.product_service.ProductService.FindAllProductsByIDs:input_type -> pb.product_service.FindByIDsRequest
-	1, // 1: pb.product_service.ProductService.FindByProductID:input_type -> pb.product_service.FindByIDRequest
-	2, // 2: pb.product_service.ProductService.SearchAllProducts:input_type -> pb.product_service.ProductSearchRequest
-	3, // 3: pb.product_service.ProductService.FindProductIDsByQuery:input_type -> pb.product_service.FindByQueryRequest
-	4, // 4: pb.product_service.ProductService.UploadProducts:input_type -> pb.product_service.UploadProductsRequest
-	5, // 5: pb.product_service.ProductService.FindAllProductsByIDs:output_type -> pb.product_service.Products
-	6, // 6: pb.product_service.ProductService.FindByProductID:output_type -> pb.product_service.Product
-	7, // 7: pb.product_service.ProductService.SearchAllProducts:output_type -> pb.product_service.SearchResponse
-	7, // 8: pb.product_service.ProductService.FindProductIDsByQuery:output_type -> pb.product_service.SearchResponse
-	8, // 9: pb.product_service.ProductService.UploadProducts:output_type -> pb.product_service.UploadProductsResponse
-	5, // [5:10] is the sub-list for method output_type
-	0, // [0:5] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	0,  // 0: pb.product_service.ProductService.FindAllProductsByIDs:input_type -> pb.product_service.FindByIDsRequest
+	1,  // 1: pb.product_service.ProductService.FindByProductID:input_type -> pb.product_service.FindByIDRequest
+	2,  // 2: pb.product_service.ProductService.SearchAllProducts:input_type -> pb.product_service.ProductSearchRequest
+	3,  // 3: pb.product_service.ProductService.FindProductIDsByQuery:input_type -> pb.product_service.FindByQueryRequest
+	4,  // 4: pb.product_service.ProductService.UploadProducts:input_type -> pb.product_service.UploadProductsRequest
+	5,  // 5: pb.product_service.ProductService.UploadProductsStream:input_type -> pb.product_service.UploadProductsStreamRequest
+	6,  // 6: pb.product_service.ProductService.CreateProduct:input_type -> pb.product_service.CreateProductRequest
+	7,  // 7: pb.product_service.ProductService.UpdateProduct:input_type -> pb.product_service.UpdateProductRequest
+	8,  // 8: pb.product_service.ProductService.PatchProduct:input_type -> pb.product_service.PatchProductRequest
+	9,  // 9: pb.product_service.ProductService.DeleteProduct:input_type -> pb.product_service.DeleteByIDRequest
+	10, // 10: pb.product_service.ProductService.RestoreProduct:input_type -> pb.product_service.MutateByIDRequest
+	11, // 11: pb.product_service.ProductService.BulkMutateProducts:input_type -> pb.product_service.BulkMutateProductsRequest
+	12, // 12: pb.product_service.ProductService.FindAllProductsByIDs:output_type -> pb.product_service.Products
+	13, // 13: pb.product_service.ProductService.FindByProductID:output_type -> pb.product_service.Product
+	14, // 14: pb.product_service.ProductService.SearchAllProducts:output_type -> pb.product_service.SearchResponse
+	14, // 15: pb.product_service.ProductService.FindProductIDsByQuery:output_type -> pb.product_service.SearchResponse
+	15, // 16: pb.product_service.ProductService.UploadProducts:output_type -> pb.product_service.UploadProductsResponse
+	16, // 17: pb.product_service.ProductService.UploadProductsStream:output_type -> pb.product_service.UploadProductsStreamResponse
+	13, // 18: pb.product_service.ProductService.CreateProduct:output_type -> pb.product_service.Product
+	13, // 19: pb.product_service.ProductService.UpdateProduct:output_type -> pb.product_service.Product
+	13, // 20: pb.product_service.ProductService.PatchProduct:output_type -> pb.product_service.Product
+	17, // 21: pb.product_service.ProductService.DeleteProduct:output_type -> pb.product_service.Empty
+	13, // 22: pb.product_service.ProductService.RestoreProduct:output_type -> pb.product_service.Product
+	18, // 23: pb.product_service.ProductService.BulkMutateProducts:output_type -> pb.product_service.BulkMutateProductsResponse
+	12, // [12:24] is the sub-list for method output_type
+	0,  // [0:12] is the sub-list for method input_type
+	0,  // [0:0] is the sub-list for extension type_name
+	0,  // [0:0] is the sub-list for extension extendee
+	0,  // [0:0] is the sub-list for field type_name
 }
 
 func init() { file_pb_product_service_product_service_proto_init() }
@@ -24,6 +24,13 @@ const (
 	ProductService_SearchAllProducts_FullMethodName     = "/pb.product_service.ProductService/SearchAllProducts"
 	ProductService_FindProductIDsByQuery_FullMethodName = "/pb.product_service.ProductService/FindProductIDsByQuery"
 	ProductService_UploadProducts_FullMethodName        = "/pb.product_service.ProductService/UploadProducts"
+	ProductService_UploadProductsStream_FullMethodName  = "/pb.product_service.ProductService/UploadProductsStream"
+	ProductService_CreateProduct_FullMethodName         = "/pb.product_service.ProductService/CreateProduct"
+	ProductService_UpdateProduct_FullMethodName         = "/pb.product_service.ProductService/UpdateProduct"
+	ProductService_PatchProduct_FullMethodName          = "/pb.product_service.ProductService/PatchProduct"
+	ProductService_DeleteProduct_FullMethodName         = "/pb.product_service.ProductService/DeleteProduct"
+	ProductService_RestoreProduct_FullMethodName        = "/pb.product_service.ProductService/RestoreProduct"
+	ProductService_BulkMutateProducts_FullMethodName    = "/pb.product_service.ProductService/BulkMutateProducts"
 )
 
 // ProductServiceClient is the client API for ProductService service.
@@ -35,6 +42,14 @@ type ProductServiceClient interface {
 	SearchAllProducts(ctx context.Context, in *ProductSearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
 	FindProductIDsByQuery(ctx context.Context, in *FindByQueryRequest, opts ...grpc.CallOption) (*SearchResponse, error)
 	UploadProducts(ctx context.Context, in *UploadProductsRequest, opts ...grpc.CallOption) (*UploadProductsResponse, error)
+	UploadProductsStream(ctx context.Context, opts ...grpc.CallOption) (ProductService_UploadProductsStreamClient, error)
+	// The mutations are authorized with the session of the caller, the user_id of the requests is ignored
+	CreateProduct(ctx context.Context, in *CreateProductRequest, opts ...grpc.CallOption) (*Product, error)
+	UpdateProduct(ctx context.Context, in *UpdateProductRequest, opts ...grpc.CallOption) (*Product, error)
+	PatchProduct(ctx context.Context, in *PatchProductRequest, opts ...grpc.CallOption) (*Product, error)
+	DeleteProduct(ctx context.Context, in *DeleteByIDRequest, opts ...grpc.CallOption) (*Empty, error)
+	RestoreProduct(ctx context.Context, in *MutateByIDRequest, opts ...grpc.CallOption) (*Product, error)
+	BulkMutateProducts(ctx context.Context, in *BulkMutateProductsRequest, opts ...grpc.CallOption) (*BulkMutateProductsResponse, error)
 }
 
 type productServiceClient struct {
@@ -90,6 +105,94 @@ func (c *productServiceClient) UploadProducts(ctx context.Context, in *UploadPro
 	return out, nil
 }
 
+func (c *productServiceClient) UploadProductsStream(ctx context.Context, opts ...grpc.CallOption) (ProductService_UploadProductsStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ProductService_ServiceDesc.Streams[0], ProductService_UploadProductsStream_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &productServiceUploadProductsStreamClient{stream}
+	return x, nil
+}
+
+type ProductService_UploadProductsStreamClient interface {
+	Send(*UploadProductsStreamRequest) error
+	CloseAndRecv() (*UploadProductsStreamResponse, error)
+	grpc.ClientStream
+}
+
+type productServiceUploadProductsStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *productServiceUploadProductsStreamClient) Send(m *UploadProductsStreamRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *productServiceUploadProductsStreamClient) CloseAndRecv() (*UploadProductsStreamResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(UploadProductsStreamResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *productServiceClient) CreateProduct(ctx context.Context, in *CreateProductRequest, opts ...grpc.CallOption) (*Product, error) {
+	out := new(Product)
+	err := c.cc.Invoke(ctx, ProductService_CreateProduct_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productServiceClient) UpdateProduct(ctx context.Context, in *UpdateProductRequest, opts ...grpc.CallOption) (*Product, error) {
+	out := new(Product)
+	err := c.cc.Invoke(ctx, ProductService_UpdateProduct_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productServiceClient) PatchProduct(ctx context.Context, in *PatchProductRequest, opts ...grpc.CallOption) (*Product, error) {
+	out := new(Product)
+	err := c.cc.Invoke(ctx, ProductService_PatchProduct_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productServiceClient) DeleteProduct(ctx context.Context, in *DeleteByIDRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, ProductService_DeleteProduct_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productServiceClient) RestoreProduct(ctx context.Context, in *MutateByIDRequest, opts ...grpc.CallOption) (*Product, error) {
+	out := new(Product)
+	err := c.cc.Invoke(ctx, ProductService_RestoreProduct_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productServiceClient) BulkMutateProducts(ctx context.Context, in *BulkMutateProductsRequest, opts ...grpc.CallOption) (*BulkMutateProductsResponse, error) {
+	out := new(BulkMutateProductsResponse)
+	err := c.cc.Invoke(ctx, ProductService_BulkMutateProducts_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ProductServiceServer is the server API for ProductService service.
 // All implementations must embed UnimplementedProductServiceServer
 // for forward compatibility
@@ -99,6 +202,14 @@ type ProductServiceServer interface {
 	SearchAllProducts(context.Context, *ProductSearchRequest) (*SearchResponse, error)
 	FindProductIDsByQuery(context.Context, *FindByQueryRequest) (*SearchResponse, error)
 	UploadProducts(context.Context, *UploadProductsRequest) (*UploadProductsResponse, error)
+	UploadProductsStream(ProductService_UploadProductsStreamServer) error
+	// The mutations are authorized with the session of the caller, the user_id of the requests is ignored
+	CreateProduct(context.Context, *CreateProductRequest) (*Product, error)
+	UpdateProduct(context.Context, *UpdateProductRequest) (*Product, error)
+	PatchProduct(context.Context, *PatchProductRequest) (*Product, error)
+	DeleteProduct(context.Context, *DeleteByIDRequest) (*Empty, error)
+	RestoreProduct(context.Context, *MutateByIDRequest) (*Product, error)
+	BulkMutateProducts(context.Context, *BulkMutateProductsRequest) (*BulkMutateProductsResponse, error)
 	mustEmbedUnimplementedProductServiceServer()
 }
 
@@ -121,6 +232,27 @@ func (UnimplementedProductServiceServer) FindProductIDsByQuery(context.Context,
 func (UnimplementedProductServiceServer) UploadProducts(context.Context, *UploadProductsRequest) (*UploadProductsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method UploadProducts not implemented")
 }
+func (UnimplementedProductServiceServer) UploadProductsStream(ProductService_UploadProductsStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method UploadProductsStream not implemented")
+}
+func (UnimplementedProductServiceServer) CreateProduct(context.Context, *CreateProductRequest) (*Product, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateProduct not implemented")
+}
+func (UnimplementedProductServiceServer) UpdateProduct(context.Context, *UpdateProductRequest) (*Product, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateProduct not implemented")
+}
+func (UnimplementedProductServiceServer) PatchProduct(context.Context, *PatchProductRequest) (*Product, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PatchProduct not implemented")
+}
+func (UnimplementedProductServiceServer) DeleteProduct(context.Context, *DeleteByIDRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteProduct not implemented")
+}
+func (UnimplementedProductServiceServer) RestoreProduct(context.Context, *MutateByIDRequest) (*Product, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestoreProduct not implemented")
+}
+func (UnimplementedProductServiceServer) BulkMutateProducts(context.Context, *BulkMutateProductsRequest) (*BulkMutateProductsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BulkMutateProducts not implemented")
+}
 func (UnimplementedProductServiceServer) mustEmbedUnimplementedProductServiceServer() {}
 
 // UnsafeProductServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -224,6 +356,140 @@ func _ProductService_UploadProducts_Handler(srv interface{}, ctx context.Context
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ProductService_UploadProductsStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ProductServiceServer).UploadProductsStream(&productServiceUploadProductsStreamServer{stream})
+}
+
+type ProductService_UploadProductsStreamServer interface {
+	SendAndClose(*UploadProductsStreamResponse) error
+	Recv() (*UploadProductsStreamRequest, error)
+	grpc.ServerStream
+}
+
+type productServiceUploadProductsStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *productServiceUploadProductsStreamServer) SendAndClose(m *UploadProductsStreamResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *productServiceUploadProductsStreamServer) Recv() (*UploadProductsStreamRequest, error) {
+	m := new(UploadProductsStreamRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _ProductService_CreateProduct_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateProductRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductServiceServer).CreateProduct(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProductService_CreateProduct_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductServiceServer).CreateProduct(ctx, req.(*CreateProductRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductService_UpdateProduct_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateProductRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductServiceServer).UpdateProduct(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProductService_UpdateProduct_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductServiceServer).UpdateProduct(ctx, req.(*UpdateProductRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductService_PatchProduct_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PatchProductRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductServiceServer).PatchProduct(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProductService_PatchProduct_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductServiceServer).PatchProduct(ctx, req.(*PatchProductRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductService_DeleteProduct_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteByIDRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductServiceServer).DeleteProduct(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProductService_DeleteProduct_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductServiceServer).DeleteProduct(ctx, req.(*DeleteByIDRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductService_RestoreProduct_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MutateByIDRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductServiceServer).RestoreProduct(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProductService_RestoreProduct_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductServiceServer).RestoreProduct(ctx, req.(*MutateByIDRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProductService_BulkMutateProducts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BulkMutateProductsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductServiceServer).BulkMutateProducts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProductService_BulkMutateProducts_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductServiceServer).BulkMutateProducts(ctx, req.(*BulkMutateProductsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // ProductService_ServiceDesc is the grpc.ServiceDesc for ProductService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -251,7 +517,37 @@ var ProductService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "UploadProducts",
 			Handler:    _ProductService_UploadProducts_Handler,
 		},
+		{
+			MethodName: "CreateProduct",
+			Handler:    _ProductService_CreateProduct_Handler,
+		},
+		{
+			MethodName: "UpdateProduct",
+			Handler:    _ProductService_UpdateProduct_Handler,
+		},
+		{
+			MethodName: "PatchProduct",
+			Handler:    _ProductService_PatchProduct_Handler,
+		},
+		{
+			MethodName: "DeleteProduct",
+			Handler:    _ProductService_DeleteProduct_Handler,
+		},
+		{
+			MethodName: "RestoreProduct",
+			Handler:    _ProductService_RestoreProduct_Handler,
+		},
+		{
+			MethodName: "BulkMutateProducts",
+			Handler:    _ProductService_BulkMutateProducts_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "UploadProductsStream",
+			Handler:       _ProductService_UploadProductsStream_Handler,
+			ClientStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "pb/product_service/product_service.proto",
 }